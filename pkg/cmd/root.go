@@ -23,6 +23,9 @@ func NewGHWhatCmd() *cobra.Command {
 	metricCmd, _ := newUpdateMetricsCmd()
 	cmd.AddCommand(metricCmd)
 
+	webhooksCmd, _ := newServeWebhooksCmd()
+	cmd.AddCommand(webhooksCmd)
+
 	// Add flags from glog to valid flag set
 	flag.CommandLine.AddGoFlagSet(goflag.CommandLine)
 	return cmd
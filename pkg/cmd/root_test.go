@@ -9,7 +9,7 @@ import (
 
 func TestNewGHWhatCmd(t *testing.T) {
 	cmd := NewGHWhatCmd()
-	assert.Equal(t, 2, len(cmd.Commands()))
+	assert.Equal(t, 3, len(cmd.Commands()))
 
 	if found := findCommand(cmd.Commands(), "version"); !found {
 		assert.Fail(t, "Version Command Not Found")
@@ -17,6 +17,9 @@ func TestNewGHWhatCmd(t *testing.T) {
 	if found := findCommand(cmd.Commands(), "update-metrics"); !found {
 		assert.Fail(t, "Update Metrics Command Not Found")
 	}
+	if found := findCommand(cmd.Commands(), "serve-webhooks"); !found {
+		assert.Fail(t, "Serve Webhooks Command Not Found")
+	}
 }
 
 func findCommand(commands []*cobra.Command, use string) bool {
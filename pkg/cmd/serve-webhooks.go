@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/github"
+	"github.com/day2devops/ea-metric-extractor/pkg/metrics"
+	"github.com/day2devops/ea-metric-extractor/pkg/webhook"
+)
+
+const (
+	serveWebhooksExample = `  # Receive GitHub webhooks on port 8080, verifying them with the secret in GITHUB_WEBHOOK_SECRET
+  git-what serve-webhooks
+
+  # Receive webhooks on another address and path, updating repositories 5 minutes after their last event
+  git-what serve-webhooks --addr :9000 --path /github/events --debounce 5m
+  `
+
+	// time allowed for in flight requests to finish when shutting down
+	shutdownTimeout = 10 * time.Second
+)
+
+// ServeWebhooksCommand the serve webhooks command structure
+type ServeWebhooksCommand struct {
+	UpdateMetricsCommand
+	addr     string
+	path     string
+	debounce time.Duration
+}
+
+// returns a new initialized instance of the serve-webhooks sub command
+func newServeWebhooksCmd() (*cobra.Command, *ServeWebhooksCommand) {
+	swc := ServeWebhooksCommand{
+		UpdateMetricsCommand: UpdateMetricsCommand{
+			gitHubClientFactory: github.ClientFactory{},
+			processorFactory:    metrics.ProcessorFactory{},
+		},
+	}
+
+	serveWebhooksCmd := &cobra.Command{
+		Use:     "serve-webhooks",
+		Short:   "Update metrics for repositories as GitHub webhook events arrive.",
+		Long:    `Receive GitHub webhook events, updating the metrics of the repositories changed without scanning the organization.`,
+		Example: serveWebhooksExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return swc.ServeWebhooksCmd()
+		},
+	}
+
+	serveWebhooksCmd.Flags().StringVar(&swc.addr, "addr", ":8080", "Address to receive webhooks on")
+	serveWebhooksCmd.Flags().StringVar(&swc.path, "path", "/webhooks", "URL path to receive webhooks on")
+	serveWebhooksCmd.Flags().DurationVar(&swc.debounce, "debounce", 30*time.Second, "Delay updating a repository until no events arrive for the duration")
+	swc.addCollectionFlags(serveWebhooksCmd.Flags())
+	swc.addFilterFlags(serveWebhooksCmd.Flags())
+	return serveWebhooksCmd, &swc
+}
+
+// ServeWebhooksCmd performs the serve-webhooks sub command
+func (swc ServeWebhooksCommand) ServeWebhooksCmd() error {
+	secret, err := webhookSecret()
+	if err != nil {
+		return err
+	}
+
+	filter, err := swc.repositoryFilter()
+	if err != nil {
+		return err
+	}
+	processor, stats, options, err := swc.newProcessor()
+	if err != nil {
+		return err
+	}
+	options.Filter = filter
+
+	// stop cleanly when interrupted, updates in progress are cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	handler := webhook.NewHandler(ctx, secret, swc.selectedOrgs(), processor, options, swc.debounce)
+	mux := http.NewServeMux()
+	mux.Handle(swc.path, handler)
+	server := &http.Server{Addr: swc.addr, Handler: mux, ReadHeaderTimeout: shutdownTimeout}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		glog.Infof("Receiving webhooks on %s%s", swc.addr, swc.path)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		glog.Info("Shutting down webhook receiver")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+	}
+	handler.Close()
+
+	stats.log()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// retrieves the secret GitHub signs webhook events with
+func webhookSecret() ([]byte, error) {
+	secret := os.Getenv("GITHUB_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, errors.New("GitHub webhook secret not specified")
+	}
+	return []byte(secret), nil
+}
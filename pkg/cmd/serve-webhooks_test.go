@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	gogithub "github.com/google/go-github/v39/github"
+	"github.com/nyarly/spies"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewServeWebhooksCmd(t *testing.T) {
+	cmd, swc := newServeWebhooksCmd()
+
+	assert.Equal(t, "serve-webhooks", cmd.Use)
+	assert.Equal(t, ":8080", swc.addr)
+	assert.Equal(t, "/webhooks", swc.path)
+	assert.Equal(t, 30*time.Second, swc.debounce)
+	assert.Equal(t, []string{"day2devops"}, swc.orgs)
+	assert.Equal(t, []int{7, 30, 90}, swc.doraWindows)
+	assert.NotNil(t, swc.gitHubClientFactory)
+	assert.NotNil(t, swc.processorFactory)
+}
+
+func TestNewServeWebhooksCmd_FlagOverrides(t *testing.T) {
+	cmd, swc := newServeWebhooksCmd()
+	err := cmd.ParseFlags([]string{
+		"--addr", ":9000",
+		"--path", "/github/events",
+		"--debounce", "5m",
+		"--org", "myorg",
+		"--collector", "graphql",
+		"--repoTimeout", "2m",
+		"--topic", "production",
+		"--archived", "exclude",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, ":9000", swc.addr)
+	assert.Equal(t, "/github/events", swc.path)
+	assert.Equal(t, 5*time.Minute, swc.debounce)
+	assert.Equal(t, []string{"myorg"}, swc.orgs)
+	assert.Equal(t, "graphql", swc.collector)
+	assert.Equal(t, 2*time.Minute, swc.repoTimeout)
+	assert.Equal(t, []string{"production"}, swc.topics)
+	assert.Equal(t, "exclude", swc.archived)
+}
+
+func TestServeWebhooksCmd_NoSecret(t *testing.T) {
+	os.Unsetenv("GITHUB_WEBHOOK_SECRET")
+	cmd := ServeWebhooksCommand{}
+	err := cmd.ServeWebhooksCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "GitHub webhook secret not specified", err.Error())
+	}
+}
+
+func TestServeWebhooksCmd_InvalidFilter(t *testing.T) {
+	os.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	defer os.Unsetenv("GITHUB_WEBHOOK_SECRET")
+	cmd := ServeWebhooksCommand{UpdateMetricsCommand: UpdateMetricsCommand{archived: "sometimes"}}
+	err := cmd.ServeWebhooksCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "unknown repository selection: sometimes", err.Error())
+	}
+}
+
+func TestServeWebhooksCmd_ListenError(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-servewebhooks")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+	os.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	defer os.Unsetenv("GITHUB_WEBHOOK_SECRET")
+
+	cmd := ServeWebhooksCommand{
+		UpdateMetricsCommand: UpdateMetricsCommand{
+			baseURL:             "https://testgithub.edwardjones.com/",
+			orgs:                []string{"testorg"},
+			dataDir:             ".",
+			gitHubClientFactory: ghcSpy,
+			processorFactory:    mpfSpy,
+		},
+		addr: "invalid-address",
+		path: "/webhooks",
+	}
+	err := cmd.ServeWebhooksCmd()
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(mpfSpy.CallsTo("NewProcessor")))
+}
@@ -1,15 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/day2devops/ea-metric-extractor/pkg/github"
+	"github.com/day2devops/ea-metric-extractor/pkg/gitlab"
 	"github.com/day2devops/ea-metric-extractor/pkg/metrics"
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
 )
 
 const (
@@ -22,6 +31,21 @@ const (
   # Update metrics for ALL GitHub repositories on specified organization
   git-what update-metrics --org <org>
 
+  # Update metrics for ALL GitHub repositories on several organizations
+  git-what update-metrics --org <org>,<org>
+
+  # Update metrics for ALL GitHub repositories on every organization visible to the credential
+  git-what update-metrics --allOrgs
+
+  # Update metrics for ALL GitHub repositories owned by a user account
+  git-what update-metrics --user <user>
+
+  # Update metrics for the service repositories, skipping archived repositories, forks and sandboxes
+  git-what update-metrics --include '^svc-' --archived exclude --forks exclude --excludeTopic sandbox
+
+  # Update metrics for the internal and private repositories tagged as production
+  git-what update-metrics --visibility internal,private --topic production
+
   # Update the metrics for a particular GitHub repository on default organization
   git-what update-metrics --repo <repo>
 
@@ -30,18 +54,93 @@ const (
 
   # Override the base github url and data directories
   git-what update-metrics --baseURL <baseURL> --dataDir <dataDir>
+
+  # Authenticate as a GitHub App installed on the organization
+  git-what update-metrics --auth app --appID <appID> --appKeyFile <keyFile>
+
+  # Collect repository data using the GitHub GraphQL API
+  git-what update-metrics --collector graphql
+
+  # Find the repositories changed since the last update using the GitHub search API
+  git-what update-metrics --discovery search
+
+  # Record the GitHub responses of a run, then reproduce the run's metrics offline from the recording
+  git-what update-metrics --repo <repo> --forceUpdate --record ./fixtures
+  git-what update-metrics --repo <repo> --forceUpdate --replay ./fixtures --dataDir ./replayed
+
+  # Stop the update after an hour, giving up on any repository taking longer than 5 minutes
+  git-what update-metrics --timeout 1h --repoTimeout 5m
+
+  # Collect all pull requests and up to 20 pages (2000) of branches for each repository
+  git-what update-metrics --pullRequestPageLimit -1 --branchPageLimit 20
+
+  # Calculate DORA metrics over the last 14 and 60 days
+  git-what update-metrics --doraWindows 14,60
+
+  # Count issues labelled bug or defect as bugs
+  git-what update-metrics --bugLabels bug,defect
+
+  # Check the governance files required by each portfolio in a hygiene policy file
+  git-what update-metrics --hygienePolicy ./hygiene.json
+
+  # Update the metrics for the projects of a GitLab group (GITLAB_AUTH_TOKEN), gitlab.com unless a base url is supplied
+  git-what update-metrics --provider gitlab --org <group>
+
+  # Update the metrics for the projects of a GitLab group along with those of its subgroups
+  git-what update-metrics --provider gitlab --org <group> --subgroups
   `
+
+	defaultGitHubURL = "https://api.github.com/"
+
+	providerGitHub = "github"
+	providerGitLab = "gitlab"
+
+	authToken = "token"
+	authApp   = "app"
+
+	collectorREST    = "rest"
+	collectorGraphQL = "graphql"
+
+	discoveryList   = "list"
+	discoverySearch = "search"
 )
 
 // UpdateMetricsCommand the update metric command structure
 type UpdateMetricsCommand struct {
+	provider            string
+	subgroups           bool
 	baseURL             string
-	org                 string
+	orgs                []string
+	allOrgs             bool
+	user                string
 	dataDir             string
 	repo                string
 	forceUpdate         bool
 	forceEvalAll        bool
 	mongo               bool
+	httpCache           bool
+	auth                string
+	appID               int64
+	appKeyFile          string
+	appInstallationID   int64
+	collector           string
+	discovery           string
+	timeout             time.Duration
+	repoTimeout         time.Duration
+	pageLimits          scm.PageLimits
+	doraWindows         []int
+	bugLabels           []string
+	hygienePolicy       string
+	include             string
+	exclude             string
+	topics              []string
+	excludeTopics       []string
+	archived            string
+	forks               string
+	templates           string
+	visibilities        []string
+	record              string
+	replay              string
 	gitHubClientFactory github.ClientCreator
 	processorFactory    metrics.ProcessorCreator
 }
@@ -56,39 +155,162 @@ func newUpdateMetricsCmd() (*cobra.Command, *UpdateMetricsCommand) {
 	updateMetricsCmd := &cobra.Command{
 		Use:     "update-metrics",
 		Short:   "Update metrics for repositories.",
-		Long:    `Update all metrics for repositories from GitHub.`,
+		Long:    `Update all metrics for repositories from GitHub or GitLab.`,
 		Example: updateMetricsExample,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return umc.UpdateMetricsCmd()
 		},
 	}
 
-	updateMetricsCmd.Flags().StringVar(&umc.baseURL, "baseURL", "https://api.github.com/", "Override the default base url")
-	updateMetricsCmd.Flags().StringVar(&umc.org, "org", "day2devops", "Override the default organization of repositories")
+	updateMetricsCmd.Flags().StringVar(&umc.provider, "provider", providerGitHub, "Source control provider the repositories are collected from: github or gitlab (groups as organizations, GITLAB_AUTH_TOKEN)")
+	updateMetricsCmd.Flags().BoolVar(&umc.subgroups, "subgroups", false, "Include the projects of the subgroups of GitLab groups, named by their path within the group")
 	updateMetricsCmd.Flags().StringVar(&umc.repo, "repo", "", "Restrict update to the supplied repository name")
-	updateMetricsCmd.Flags().StringVar(&umc.dataDir, "dataDir", defaultDataDir(os.UserHomeDir), "Override the default data directory")
 	updateMetricsCmd.Flags().BoolVar(&umc.forceUpdate, "forceUpdate", false, "Force updates of repositories regardless of last update timestamp")
 	updateMetricsCmd.Flags().BoolVar(&umc.forceEvalAll, "forceEvalAll", false, "Force evaluation of all repositories regardless of cache statistics")
-	updateMetricsCmd.Flags().BoolVar(&umc.mongo, "mongo", false, "Leverage mongodb for metric persistence")
+	updateMetricsCmd.Flags().StringVar(&umc.discovery, "discovery", discoveryList, "How repositories changed since the last update are found: list (every repository) or search (GitHub search API, listing when the search fails)")
+	updateMetricsCmd.Flags().DurationVar(&umc.timeout, "timeout", 0, "Stop the update after the supplied duration (e.g. 1h), no limit when zero")
+	updateMetricsCmd.Flags().StringVar(&umc.record, "record", "", "Record every GitHub response to the fixture directory")
+	updateMetricsCmd.Flags().StringVar(&umc.replay, "replay", "", "Answer GitHub requests from the responses recorded in the fixture directory without using the network")
+	umc.addCollectionFlags(updateMetricsCmd.Flags())
+	umc.addFilterFlags(updateMetricsCmd.Flags())
 	return updateMetricsCmd, &umc
 }
 
+// register the flags selecting the repositories of an organization to update
+func (umc *UpdateMetricsCommand) addFilterFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&umc.include, "include", "", "Only update repositories with names matching the regular expression")
+	flags.StringVar(&umc.exclude, "exclude", "", "Skip repositories with names matching the regular expression")
+	flags.StringSliceVar(&umc.topics, "topic", nil, "Only update repositories having all of the topics")
+	flags.StringSliceVar(&umc.excludeTopics, "excludeTopic", nil, "Skip repositories having any of the topics")
+	flags.StringVar(&umc.archived, "archived", string(metrics.SelectInclude), "Archived repositories to update: include, exclude or only")
+	flags.StringVar(&umc.forks, "forks", string(metrics.SelectInclude), "Forked repositories to update: include, exclude or only")
+	flags.StringVar(&umc.templates, "templates", string(metrics.SelectInclude), "Template repositories to update: include, exclude or only")
+	flags.StringSliceVar(&umc.visibilities, "visibility", nil, "Only update repositories with the visibilities (public, private or internal), all when not supplied")
+}
+
+// register the flags controlling how repository metrics are collected and stored
+func (umc *UpdateMetricsCommand) addCollectionFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&umc.baseURL, "baseURL", defaultGitHubURL, "Override the default base url, the gitlab.com API being the default for the gitlab provider")
+	flags.StringSliceVar(&umc.orgs, "org", []string{"day2devops"}, "Override the default organization of repositories, comma separated or repeated for several organizations")
+	flags.BoolVar(&umc.allOrgs, "allOrgs", false, "Update repositories of every organization the credential belongs to, ignoring --org")
+	flags.StringVar(&umc.user, "user", "", "Update repositories owned by the user account instead of an organization, ignoring --org")
+	flags.StringVar(&umc.dataDir, "dataDir", defaultDataDir(os.UserHomeDir), "Override the default data directory")
+	flags.BoolVar(&umc.mongo, "mongo", false, "Leverage mongodb for metric persistence")
+	flags.BoolVar(&umc.httpCache, "httpCache", true, "Cache GitHub responses in the data directory and send conditional requests")
+	flags.StringVar(&umc.auth, "auth", authToken, "GitHub authentication mode: token (GITHUB_AUTH_TOKEN) or app (GitHub App installation)")
+	flags.Int64Var(&umc.appID, "appID", 0, "GitHub App ID used when authenticating as an app")
+	flags.StringVar(&umc.appKeyFile, "appKeyFile", "", "GitHub App private key (PEM) file used when authenticating as an app")
+	flags.Int64Var(&umc.appInstallationID, "appInstallationID", 0, "GitHub App installation ID, discovered for the organization when not supplied")
+	flags.StringVar(&umc.collector, "collector", collectorREST, "GitHub API used to collect repository data: rest or graphql")
+	flags.DurationVar(&umc.repoTimeout, "repoTimeout", 0, "Limit the time spent updating each repository (e.g. 5m), no limit when zero")
+	flags.IntVar(&umc.pageLimits.Branches, "branchPageLimit", 10, "Maximum pages (100 per page) of branches collected per repository, unlimited when negative")
+	flags.IntVar(&umc.pageLimits.Releases, "releasePageLimit", 10, "Maximum pages (100 per page) of releases collected per repository, unlimited when negative")
+	flags.IntVar(&umc.pageLimits.PullRequests, "pullRequestPageLimit", 10, "Maximum pages (100 per page) of pull requests collected per repository, unlimited when negative")
+	flags.IntVar(&umc.pageLimits.WorkflowRuns, "workflowRunPageLimit", 10, "Maximum pages (100 per page) of workflow runs collected per repository, unlimited when negative")
+	flags.IntVar(&umc.pageLimits.Deployments, "deploymentPageLimit", 10, "Maximum pages (100 per page) of deployments collected per repository, unlimited when negative")
+	flags.IntVar(&umc.pageLimits.Issues, "issuePageLimit", 10, "Maximum pages (100 per page) of issues and issue comments collected per repository, unlimited when negative")
+	flags.IntVar(&umc.pageLimits.SecurityAlerts, "securityAlertPageLimit", 10, "Maximum pages (100 per page) of code scanning and Dependabot alerts collected per repository, unlimited when negative")
+	flags.IntVar(&umc.pageLimits.Contributors, "contributorPageLimit", 10, "Maximum pages (100 per page) of contributors collected per GitLab project, unlimited when negative")
+	flags.IntSliceVar(&umc.doraWindows, "doraWindows", []int{7, 30, 90}, "Day windows to calculate DORA metrics over, not calculated when empty")
+	flags.StringSliceVar(&umc.bugLabels, "bugLabels", []string{"bug"}, "Issue labels identifying bugs, ignoring case")
+	flags.StringVar(&umc.hygienePolicy, "hygienePolicy", "", "JSON file defining the governance files looked for and required by portfolio, all default files required when not supplied")
+}
+
 // UpdateMetricsCmd performs the update-metrics sub command
 func (umc UpdateMetricsCommand) UpdateMetricsCmd() error {
-	// establish a client for the GitHub API interactions
-	token, err := githubToken()
+	filter, err := umc.repositoryFilter()
+	if err != nil {
+		return err
+	}
+	processor, stats, options, err := umc.newProcessor()
 	if err != nil {
 		return err
 	}
+	options.Filter = filter
+	if err = umc.validateOrgs(); err != nil {
+		return err
+	}
 
-	glog.V(2).Infof("Building github client with base url: %s, token: %s", umc.baseURL, token)
+	// stop cleanly when interrupted or the overall timeout expires
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if umc.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, umc.timeout)
+		defer cancel()
+	}
 
-	client, err := umc.gitHubClientFactory.NewGitHubClient(umc.baseURL, token)
+	orgs := umc.selectedOrgs()
+	switch {
+	case umc.repo != "":
+		err = processor.Repository(ctx, orgs[0], umc.repo, options)
+	case umc.allOrgs || len(orgs) > 1:
+		options.ForceAllRepoEval = umc.forceEvalAll || umc.forceUpdate
+		err = processor.RepositoriesForOrgs(ctx, orgs, options)
+	default:
+		options.ForceAllRepoEval = umc.forceEvalAll || umc.forceUpdate
+		err = processor.RepositoriesForOrg(ctx, orgs[0], options)
+	}
+
+	if ctx.Err() != nil {
+		glog.Warningf("Metric update stopped before completion: %s", ctx.Err())
+	}
+
+	stats.log()
+	return err
+}
+
+// github client features reporting statistics at the end of a run, nil when not enabled
+type clientStats struct {
+	cache    *github.ResponseCache
+	fixtures *github.Fixtures
+}
+
+// log the statistics of the enabled client features
+func (s clientStats) log() {
+	if s.cache != nil {
+		hits, misses := s.cache.Stats()
+		glog.Infof("GitHub response cache: %d hits, %d misses", hits, misses)
+	}
+	if s.fixtures != nil {
+		served, missing := s.fixtures.Stats()
+		if s.fixtures.Replay {
+			glog.Infof("GitHub fixtures: %d responses replayed, %d requests not recorded", served, missing)
+		} else {
+			glog.Infof("GitHub fixtures: %d responses recorded to %s", served, s.fixtures.Dir)
+		}
+	}
+}
+
+// build the metrics processor from the flags along with the options to update metrics with and the client
+// features reporting statistics
+func (umc UpdateMetricsCommand) newProcessor() (metrics.Processor, clientStats, metrics.Options, error) {
+	if err := umc.validateProvider(); err != nil {
+		return nil, clientStats{}, metrics.Options{}, err
+	}
+	if err := umc.validateCollector(); err != nil {
+		return nil, clientStats{}, metrics.Options{}, err
+	}
+	if err := umc.validateDiscovery(); err != nil {
+		return nil, clientStats{}, metrics.Options{}, err
+	}
+	hygiene, err := umc.readHygienePolicy()
 	if err != nil {
-		return err
+		return nil, clientStats{}, metrics.Options{}, err
+	}
+
+	var dataCollector scm.DataCollector
+	var stats clientStats
+	if umc.provider == providerGitLab {
+		dataCollector, err = umc.gitLabCollector()
+	} else {
+		dataCollector, stats, err = umc.gitHubCollector()
+	}
+	if err != nil {
+		return nil, clientStats{}, metrics.Options{}, err
 	}
 
-	// build metric manager and execute based on command args
+	// build metric manager based on command args
 	glog.V(2).Infof("Using metric data directory: %s", umc.dataDir)
 
 	var dataMgr metrics.DataManager
@@ -96,22 +318,247 @@ func (umc UpdateMetricsCommand) UpdateMetricsCmd() error {
 	if umc.mongo {
 		user, pwd, conn, err := mongoConnectionInfo()
 		if err != nil {
-			return err
+			return nil, clientStats{}, metrics.Options{}, err
 		}
 		dataMgr = metrics.MongoDataManager{User: user, Pwd: pwd, ConnectionString: conn}
 	}
 
-	dataCollector := github.RepositoryDataCollector{GitHubClient: client}
+	options := metrics.Options{
+		ForceMetricUpdate: umc.forceUpdate,
+		RepoTimeout:       umc.repoTimeout,
+		DORAWindows:       umc.doraWindows,
+		BugLabels:         umc.bugLabels,
+		Hygiene:           hygiene,
+	}
+	return umc.processorFactory.NewProcessor(dataCollector, dataMgr), stats, options, nil
+}
+
+// build the collector of GitHub repositories along with the client features reporting statistics
+func (umc UpdateMetricsCommand) gitHubCollector() (scm.DataCollector, clientStats, error) {
+	stats, err := umc.fixtures()
+	if err != nil {
+		return nil, clientStats{}, err
+	}
+
+	// establish a client for the GitHub API interactions
+	token, app, err := umc.githubCredentials()
+	if err != nil {
+		return nil, clientStats{}, err
+	}
 
-	processor := umc.processorFactory.NewProcessor(dataCollector, dataMgr)
+	glog.V(2).Infof("Building github client with base url: %s, token: %s", umc.baseURL, token)
 
-	if umc.repo == "" {
-		return processor.RepositoriesForOrg(umc.org, metrics.Options{
-			ForceMetricUpdate: umc.forceUpdate,
-			ForceAllRepoEval:  umc.forceEvalAll || umc.forceUpdate,
-		})
+	// recorded responses must be complete, conditional requests would record not modified replies instead
+	if umc.httpCache && stats.fixtures == nil {
+		stats.cache = github.NewResponseCache(filepath.Join(umc.dataDir, "http-cache"))
+		glog.V(2).Infof("Using github response cache directory: %s", stats.cache.Dir)
 	}
-	return processor.Repository(umc.org, umc.repo)
+
+	client, err := umc.gitHubClientFactory.NewGitHubClient(umc.baseURL, token, github.ClientOptions{Cache: stats.cache, App: app, Fixtures: stats.fixtures})
+	if err != nil {
+		return nil, clientStats{}, err
+	}
+
+	var dataCollector github.DataCollector
+	search := umc.discovery == discoverySearch
+	dataCollector = github.RepositoryDataCollector{GitHubClient: client, PageLimits: umc.pageLimits, UserOwners: umc.user != "", SearchDiscovery: search}
+	if umc.collector == collectorGraphQL {
+		glog.V(2).Infof("Collecting repository data using the GitHub GraphQL API")
+		dataCollector = github.GraphQLDataCollector{GitHubClient: client, PageLimits: umc.pageLimits, UserOwners: umc.user != "", SearchDiscovery: search}
+	}
+	return github.Provider{Collector: dataCollector}, stats, nil
+}
+
+// build the collector of GitLab projects, using the gitlab.com API unless another base url is supplied
+func (umc UpdateMetricsCommand) gitLabCollector() (scm.DataCollector, error) {
+	token, err := gitlabToken()
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := umc.baseURL
+	if baseURL == "" || baseURL == defaultGitHubURL {
+		baseURL = gitlab.DefaultBaseURL
+	}
+	glog.V(2).Infof("Building gitlab client with base url: %s", baseURL)
+	client, err := gitlab.NewClient(baseURL, token)
+	if err != nil {
+		return nil, err
+	}
+	return gitlab.RepositoryDataCollector{Client: client, PageLimits: umc.pageLimits, IncludeSubgroups: umc.subgroups}, nil
+}
+
+// build the fixtures recording or replaying GitHub responses when selected
+func (umc UpdateMetricsCommand) fixtures() (clientStats, error) {
+	switch {
+	case umc.record != "" && umc.replay != "":
+		return clientStats{}, errors.New("record and replay can't both be selected")
+	case umc.record != "":
+		glog.V(2).Infof("Recording github responses to: %s", umc.record)
+		return clientStats{fixtures: github.NewRecordingFixtures(umc.record)}, nil
+	case umc.replay != "":
+		glog.V(2).Infof("Replaying github responses from: %s", umc.replay)
+		return clientStats{fixtures: github.NewReplayFixtures(umc.replay)}, nil
+	}
+	return clientStats{}, nil
+}
+
+// determine the GitHub credentials for the selected authentication mode
+func (umc UpdateMetricsCommand) githubCredentials() (token string, app *github.AppCredentials, err error) {
+	switch umc.auth {
+	case "", authToken:
+		token, err = githubToken()
+		if err != nil && umc.replay != "" {
+			// replayed responses don't need credentials
+			return "", nil, nil
+		}
+		return token, nil, err
+	case authApp:
+		if umc.appID == 0 || umc.appKeyFile == "" {
+			return "", nil, errors.New("GitHub App ID and private key file required for app authentication")
+		}
+		if umc.allOrgs {
+			// the organizations of the credential are listed for the user, which an app installation isn't
+			return "", nil, errors.New("--allOrgs can't be used with app authentication, an app installation only covers the --org organization")
+		}
+		if len(umc.selectedOrgs()) != 1 {
+			return "", nil, errors.New("a single organization is required for app authentication")
+		}
+		glog.V(2).Infof("Authenticating as GitHub App %d", umc.appID)
+		return "", &github.AppCredentials{
+			AppID:          umc.appID,
+			PrivateKeyFile: umc.appKeyFile,
+			InstallationID: umc.appInstallationID,
+			Org:            umc.selectedOrgs()[0],
+			User:           umc.user != "",
+		}, nil
+	default:
+		return "", nil, fmt.Errorf("unknown GitHub authentication mode: %s", umc.auth)
+	}
+}
+
+// ensure the selected provider is known, the GitHub specific options not being selected for GitLab
+func (umc UpdateMetricsCommand) validateProvider() error {
+	switch umc.provider {
+	case "", providerGitHub:
+		if umc.subgroups {
+			return errors.New("subgroups only supported by the gitlab provider")
+		}
+		return nil
+	case providerGitLab:
+		switch {
+		case umc.auth == authApp:
+			return errors.New("app authentication not supported by the gitlab provider")
+		case umc.collector == collectorGraphQL:
+			return errors.New("graphql collector not supported by the gitlab provider")
+		case umc.discovery == discoverySearch:
+			return errors.New("search discovery not supported by the gitlab provider")
+		case umc.record != "" || umc.replay != "":
+			return errors.New("record and replay not supported by the gitlab provider")
+		case umc.user != "":
+			return errors.New("user repositories not supported by the gitlab provider")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown source control provider: %s", umc.provider)
+	}
+}
+
+// ensure the selected data collector is known
+func (umc UpdateMetricsCommand) validateCollector() error {
+	switch umc.collector {
+	case "", collectorREST, collectorGraphQL:
+		return nil
+	default:
+		return fmt.Errorf("unknown GitHub data collector: %s", umc.collector)
+	}
+}
+
+// ensure the selected discovery of changed repositories is known
+func (umc UpdateMetricsCommand) validateDiscovery() error {
+	switch umc.discovery {
+	case "", discoveryList, discoverySearch:
+		return nil
+	default:
+		return fmt.Errorf("unknown repository discovery: %s", umc.discovery)
+	}
+}
+
+// ensure organizations are selected, a single organization being required when updating a repository
+func (umc UpdateMetricsCommand) validateOrgs() error {
+	if umc.allOrgs && umc.user != "" {
+		return errors.New("user and all organizations can't both be selected")
+	}
+	orgs := umc.selectedOrgs()
+	if !umc.allOrgs && len(orgs) == 0 {
+		return errors.New("organization not specified")
+	}
+	if umc.repo != "" && (umc.allOrgs || len(orgs) != 1) {
+		return errors.New("a single organization is required when updating a repository")
+	}
+	return nil
+}
+
+// owners of the repositories to update, nil when discovering every organization the credential belongs to
+func (umc UpdateMetricsCommand) selectedOrgs() []string {
+	if umc.allOrgs {
+		return nil
+	}
+	if umc.user != "" {
+		return []string{umc.user}
+	}
+	return umc.orgs
+}
+
+// build the filter selecting the repositories of an organization to update
+func (umc UpdateMetricsCommand) repositoryFilter() (metrics.RepositoryFilter, error) {
+	filter := metrics.RepositoryFilter{RequiredTopics: umc.topics, ForbiddenTopics: umc.excludeTopics}
+	var err error
+	if filter.Include, err = compileFilter(umc.include); err != nil {
+		return filter, err
+	}
+	if filter.Exclude, err = compileFilter(umc.exclude); err != nil {
+		return filter, err
+	}
+	if filter.Archived, err = metrics.ParseSelection(umc.archived); err != nil {
+		return filter, err
+	}
+	if filter.Forks, err = metrics.ParseSelection(umc.forks); err != nil {
+		return filter, err
+	}
+	if filter.Templates, err = metrics.ParseSelection(umc.templates); err != nil {
+		return filter, err
+	}
+	for _, v := range umc.visibilities {
+		switch v {
+		case "public", "private", "internal":
+			filter.Visibilities = append(filter.Visibilities, v)
+		default:
+			return filter, fmt.Errorf("unknown repository visibility: %s", v)
+		}
+	}
+	return filter, nil
+}
+
+// compile the repository name expression, nil when not supplied
+func compileFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository name expression %s: %w", expr, err)
+	}
+	return re, nil
+}
+
+// read the hygiene policy when supplied, otherwise requiring all of the default governance files
+func (umc UpdateMetricsCommand) readHygienePolicy() (metrics.HygienePolicy, error) {
+	if umc.hygienePolicy == "" {
+		return metrics.DefaultHygienePolicy(), nil
+	}
+	glog.V(2).Infof("Using hygiene policy: %s", umc.hygienePolicy)
+	return metrics.ReadHygienePolicy(umc.hygienePolicy)
 }
 
 // retrieves authorization token for GitHub for process
@@ -123,6 +570,15 @@ func githubToken() (string, error) {
 	return token, nil
 }
 
+// retrieves authorization token for GitLab for process
+func gitlabToken() (string, error) {
+	token := os.Getenv("GITLAB_AUTH_TOKEN")
+	if token == "" {
+		return "", errors.New("GitLab token not specified")
+	}
+	return token, nil
+}
+
 // build the default directory to hold github metric data
 func defaultDataDir(userHomeDir func() (string, error)) string {
 	userDir, err := userHomeDir()
@@ -1,18 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	gogithub "github.com/google/go-github/v39/github"
 	"github.com/nyarly/spies"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/day2devops/ea-metric-extractor/pkg/github"
+	"github.com/day2devops/ea-metric-extractor/pkg/gitlab"
 	"github.com/day2devops/ea-metric-extractor/pkg/metrics"
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
 )
 
 func TestGithubToken_EnvVariableSet(t *testing.T) {
@@ -53,12 +57,37 @@ func TestNewUpdateMetricsCmd_FlagDefaults(t *testing.T) {
 	cmd, umc := newUpdateMetricsCmd()
 	cmd.ParseFlags([]string{})
 
+	assert.Equal(t, "github", umc.provider)
 	assert.Equal(t, "https://api.github.com/", umc.baseURL)
 	assert.True(t, strings.Contains(umc.dataDir, ".git-metrics"))
-	assert.Equal(t, "day2devops", umc.org)
+	assert.Equal(t, []string{"day2devops"}, umc.orgs)
+	assert.False(t, umc.allOrgs)
+	assert.Equal(t, "", umc.user)
+	assert.Equal(t, "", umc.include)
+	assert.Equal(t, "", umc.exclude)
+	assert.Empty(t, umc.topics)
+	assert.Empty(t, umc.excludeTopics)
+	assert.Equal(t, "include", umc.archived)
+	assert.Equal(t, "include", umc.forks)
+	assert.Equal(t, "include", umc.templates)
+	assert.Empty(t, umc.visibilities)
+	assert.Equal(t, "", umc.record)
+	assert.Equal(t, "", umc.replay)
 	assert.Equal(t, "", umc.repo)
 	assert.False(t, umc.forceUpdate)
 	assert.False(t, umc.forceEvalAll)
+	assert.True(t, umc.httpCache)
+	assert.Equal(t, "token", umc.auth)
+	assert.Equal(t, int64(0), umc.appID)
+	assert.Equal(t, "", umc.appKeyFile)
+	assert.Equal(t, int64(0), umc.appInstallationID)
+	assert.Equal(t, "rest", umc.collector)
+	assert.Equal(t, "list", umc.discovery)
+	assert.Equal(t, time.Duration(0), umc.timeout)
+	assert.Equal(t, time.Duration(0), umc.repoTimeout)
+	assert.Equal(t, scm.PageLimits{Branches: 10, Releases: 10, PullRequests: 10, WorkflowRuns: 10, Deployments: 10, Issues: 10, SecurityAlerts: 10, Contributors: 10}, umc.pageLimits)
+	assert.Equal(t, []int{7, 30, 90}, umc.doraWindows)
+	assert.Equal(t, []string{"bug"}, umc.bugLabels)
 	assert.NotNil(t, umc.gitHubClientFactory)
 	assert.NotNil(t, umc.processorFactory)
 }
@@ -66,20 +95,77 @@ func TestNewUpdateMetricsCmd_FlagDefaults(t *testing.T) {
 func TestNewUpdateMetricsCmd_FlagOverrides(t *testing.T) {
 	cmd, umc := newUpdateMetricsCmd()
 	cmd.ParseFlags([]string{
+		"--provider", "gitlab",
 		"--baseURL", "https://mygithub.com/",
 		"--org", "myorg",
+		"--allOrgs",
+		"--user", "myuser",
+		"--include", "^svc-",
+		"--exclude", "-sandbox$",
+		"--topic", "production,java",
+		"--excludeTopic", "deprecated",
+		"--archived", "exclude",
+		"--forks", "only",
+		"--templates", "exclude",
+		"--visibility", "internal,private",
+		"--record", "./recorded",
+		"--replay", "./replayed",
 		"--dataDir", "./.mydatadir",
 		"--repo", "myrepo",
 		"--forceUpdate",
 		"--forceEvalAll",
+		"--httpCache=false",
+		"--auth", "app",
+		"--appID", "1234",
+		"--appKeyFile", "./app.pem",
+		"--appInstallationID", "42",
+		"--collector", "graphql",
+		"--discovery", "search",
+		"--timeout", "1h",
+		"--repoTimeout", "5m",
+		"--branchPageLimit", "20",
+		"--releasePageLimit", "5",
+		"--pullRequestPageLimit", "-1",
+		"--workflowRunPageLimit", "3",
+		"--deploymentPageLimit", "2",
+		"--issuePageLimit", "4",
+		"--securityAlertPageLimit", "6",
+		"--contributorPageLimit", "7",
+		"--doraWindows", "14,60",
+		"--bugLabels", "bug,defect",
 	})
 
+	assert.Equal(t, "gitlab", umc.provider)
 	assert.Equal(t, "https://mygithub.com/", umc.baseURL)
 	assert.Equal(t, "./.mydatadir", umc.dataDir)
-	assert.Equal(t, "myorg", umc.org)
+	assert.Equal(t, []string{"myorg"}, umc.orgs)
+	assert.True(t, umc.allOrgs)
+	assert.Equal(t, "myuser", umc.user)
+	assert.Equal(t, "^svc-", umc.include)
+	assert.Equal(t, "-sandbox$", umc.exclude)
+	assert.Equal(t, []string{"production", "java"}, umc.topics)
+	assert.Equal(t, []string{"deprecated"}, umc.excludeTopics)
+	assert.Equal(t, "exclude", umc.archived)
+	assert.Equal(t, "only", umc.forks)
+	assert.Equal(t, "exclude", umc.templates)
+	assert.Equal(t, []string{"internal", "private"}, umc.visibilities)
+	assert.Equal(t, "./recorded", umc.record)
+	assert.Equal(t, "./replayed", umc.replay)
 	assert.Equal(t, "myrepo", umc.repo)
 	assert.True(t, umc.forceUpdate)
 	assert.True(t, umc.forceEvalAll)
+	assert.False(t, umc.httpCache)
+	assert.Equal(t, "app", umc.auth)
+	assert.Equal(t, int64(1234), umc.appID)
+	assert.Equal(t, "./app.pem", umc.appKeyFile)
+	assert.Equal(t, int64(42), umc.appInstallationID)
+	assert.Equal(t, "graphql", umc.collector)
+	assert.Equal(t, "search", umc.discovery)
+	assert.Equal(t, time.Hour, umc.timeout)
+	assert.Equal(t, 5*time.Minute, umc.repoTimeout)
+	assert.Equal(t, scm.PageLimits{Branches: 20, Releases: 5, PullRequests: -1, WorkflowRuns: 3, Deployments: 2, Issues: 4, SecurityAlerts: 6, Contributors: 7}, umc.pageLimits)
+	assert.Equal(t, []int{14, 60}, umc.doraWindows)
+	assert.Equal(t, []string{"bug", "defect"}, umc.bugLabels)
 	assert.NotNil(t, umc.gitHubClientFactory)
 	assert.NotNil(t, umc.processorFactory)
 }
@@ -94,6 +180,229 @@ func TestUpdateMetricsCmd_NoGitHubToken(t *testing.T) {
 	}
 }
 
+func TestUpdateMetricsCmd_UnknownAuthMode(t *testing.T) {
+	cmd := UpdateMetricsCommand{auth: "password"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "unknown GitHub authentication mode: password", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_UnknownCollector(t *testing.T) {
+	cmd := UpdateMetricsCommand{collector: "soap"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "unknown GitHub data collector: soap", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_UnknownDiscovery(t *testing.T) {
+	cmd := UpdateMetricsCommand{discovery: "crawl"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "unknown repository discovery: crawl", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_MissingHygienePolicy(t *testing.T) {
+	cmd := UpdateMetricsCommand{hygienePolicy: "./not-found.json"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+}
+
+func TestUpdateMetricsCmd_GraphQLCollector(t *testing.T) {
+	// Define spy for github client factory
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	oGitHubClient := &gogithub.Client{}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, oGitHubClient, nil)
+
+	// Define spy for metrics processor and factory
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("Repository", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	// Establish token in environment for test
+	token := "authtokenval-graphqlcollector"
+	os.Setenv("GITHUB_AUTH_TOKEN", token)
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	// Build and execute command
+	cmd := UpdateMetricsCommand{
+		baseURL:             "https://testgithub.edwardjones.com/",
+		orgs:                []string{"testorg"},
+		dataDir:             ".",
+		repo:                "test-repo",
+		collector:           "graphql",
+		discovery:           "search",
+		pageLimits:          scm.PageLimits{PullRequests: -1},
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+
+	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.Provider).Collector.(github.GraphQLDataCollector)
+	if assert.True(t, ok) {
+		assert.Same(t, oGitHubClient, ghdc.GitHubClient)
+		assert.Equal(t, -1, ghdc.PageLimits.PullRequests)
+		assert.True(t, ghdc.SearchDiscovery)
+	}
+	assert.Equal(t, 1, len(mpSpy.CallsTo("Repository")))
+}
+
+func TestUpdateMetricsCmd_UnknownProvider(t *testing.T) {
+	cmd := UpdateMetricsCommand{provider: "svn"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "unknown source control provider: svn", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_GitLabUnsupportedOption(t *testing.T) {
+	cmd := UpdateMetricsCommand{provider: "gitlab", collector: "graphql"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "graphql collector not supported by the gitlab provider", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_SubgroupsWithGitHub(t *testing.T) {
+	cmd := UpdateMetricsCommand{subgroups: true}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "subgroups only supported by the gitlab provider", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_NoGitLabToken(t *testing.T) {
+	cmd := UpdateMetricsCommand{provider: "gitlab"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "GitLab token not specified", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_GitLabProvider(t *testing.T) {
+	// Define spy for metrics processor and factory, no github client built
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("RepositoriesForOrg", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	// Establish token in environment for test
+	os.Setenv("GITLAB_AUTH_TOKEN", "authtokenval-gitlab")
+	defer os.Unsetenv("GITLAB_AUTH_TOKEN")
+
+	// Build and execute command, the github default base url switching to gitlab.com
+	cmd := UpdateMetricsCommand{
+		provider:            "gitlab",
+		baseURL:             "https://api.github.com/",
+		orgs:                []string{"testgroup"},
+		dataDir:             ".",
+		pageLimits:          scm.PageLimits{Branches: 3},
+		subgroups:           true,
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+	assert.Empty(t, ghcSpy.Calls())
+
+	gldc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(gitlab.RepositoryDataCollector)
+	if assert.True(t, ok) {
+		assert.Equal(t, "https://gitlab.com/api/v4/", gldc.Client.BaseURL.String())
+		assert.Equal(t, "authtokenval-gitlab", gldc.Client.Token)
+		assert.Equal(t, 3, gldc.PageLimits.Branches)
+		assert.True(t, gldc.IncludeSubgroups)
+	}
+	assert.Equal(t, 1, len(mpSpy.CallsTo("RepositoriesForOrg")))
+	assert.Equal(t, "testgroup", mpSpy.CallsTo("RepositoriesForOrg")[0].PassedArgs().String(0))
+}
+
+func TestUpdateMetricsCmd_GitLabBaseURL(t *testing.T) {
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, &MetricsProcessorSpy{Spy: spies.NewSpy()})
+
+	os.Setenv("GITLAB_AUTH_TOKEN", "authtokenval-gitlabbaseurl")
+	defer os.Unsetenv("GITLAB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{provider: "gitlab", baseURL: "https://gitlab.example.com/api/v4", processorFactory: mpfSpy}
+	_, _, _, err := cmd.newProcessor()
+
+	assert.NoError(t, err)
+	gldc := mpfSpy.Calls()[0].PassedArgs().Get(0).(gitlab.RepositoryDataCollector)
+	assert.Equal(t, "https://gitlab.example.com/api/v4/", gldc.Client.BaseURL.String())
+}
+
+func TestUpdateMetricsCmd_AppAuthMissingKey(t *testing.T) {
+	cmd := UpdateMetricsCommand{auth: "app", appID: 1234}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "GitHub App ID and private key file required for app authentication", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_AppAuth(t *testing.T) {
+	// Define spy for github client factory
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	// Define spy for metrics processor and factory
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("RepositoriesForOrg", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	// Build and execute command, no token in environment required
+	cmd := UpdateMetricsCommand{
+		baseURL:             "https://testgithub.edwardjones.com/",
+		orgs:                []string{"testorg"},
+		dataDir:             ".",
+		auth:                "app",
+		appID:               1234,
+		appKeyFile:          "./app.pem",
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", ghcSpy.Calls()[0].PassedArgs().String(1))
+	opts := ghcSpy.Calls()[0].PassedArgs().Get(2).(github.ClientOptions)
+	if assert.NotNil(t, opts.App) {
+		assert.Equal(t, int64(1234), opts.App.AppID)
+		assert.Equal(t, "./app.pem", opts.App.PrivateKeyFile)
+		assert.Equal(t, int64(0), opts.App.InstallationID)
+		assert.Equal(t, "testorg", opts.App.Org)
+		assert.False(t, opts.App.User)
+	}
+}
+
 func TestUpdateMetricsCmd_GitHubClientError(t *testing.T) {
 	// Define spy function for github client factory
 	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
@@ -137,10 +446,11 @@ func TestUpdateMetricsCmd_AllRepositories(t *testing.T) {
 	// Build and execute command
 	cmd := UpdateMetricsCommand{
 		baseURL:             "https://testgithub.edwardjones.com/",
-		org:                 "testorg",
+		orgs:                []string{"testorg"},
 		dataDir:             ".",
 		forceUpdate:         false,
 		forceEvalAll:        true,
+		repoTimeout:         time.Minute,
 		gitHubClientFactory: ghcSpy,
 		processorFactory:    mpfSpy,
 	}
@@ -150,10 +460,12 @@ func TestUpdateMetricsCmd_AllRepositories(t *testing.T) {
 
 	assert.Equal(t, "https://testgithub.edwardjones.com/", ghcSpy.Calls()[0].PassedArgs().Get(0))
 	assert.Equal(t, token, ghcSpy.Calls()[0].PassedArgs().Get(1))
+	assert.Nil(t, ghcSpy.Calls()[0].PassedArgs().Get(2).(github.ClientOptions).Cache)
 
-	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.RepositoryDataCollector)
+	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.Provider).Collector.(github.RepositoryDataCollector)
 	if ok {
 		assert.Same(t, oGitHubClient, ghdc.GitHubClient)
+		assert.False(t, ghdc.SearchDiscovery)
 	}
 	fdm, ok := mpfSpy.Calls()[0].PassedArgs().Get(1).(metrics.FileDataManager)
 	if ok {
@@ -164,9 +476,330 @@ func TestUpdateMetricsCmd_AllRepositories(t *testing.T) {
 	assert.Equal(t, "testorg", mpSpy.CallsTo("RepositoriesForOrg")[0].PassedArgs().String(0))
 	assert.False(t, mpSpy.CallsTo("RepositoriesForOrg")[0].PassedArgs().Get(1).(metrics.Options).ForceMetricUpdate)
 	assert.True(t, mpSpy.CallsTo("RepositoriesForOrg")[0].PassedArgs().Get(1).(metrics.Options).ForceAllRepoEval)
+	assert.Equal(t, time.Minute, mpSpy.CallsTo("RepositoriesForOrg")[0].PassedArgs().Get(1).(metrics.Options).RepoTimeout)
 	assert.Equal(t, 0, len(mpSpy.CallsTo("Repository")))
 }
 
+func TestUpdateMetricsCmd_MultipleOrgs(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("RepositoriesForOrgs", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-multipleorgs")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{
+		orgs:                []string{"org1", "org2"},
+		dataDir:             ".",
+		forceUpdate:         true,
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(mpSpy.CallsTo("RepositoriesForOrg")))
+	assert.Equal(t, 1, len(mpSpy.CallsTo("RepositoriesForOrgs")))
+	assert.Equal(t, []string{"org1", "org2"}, mpSpy.CallsTo("RepositoriesForOrgs")[0].PassedArgs().Get(0))
+	assert.True(t, mpSpy.CallsTo("RepositoriesForOrgs")[0].PassedArgs().Get(1).(metrics.Options).ForceAllRepoEval)
+}
+
+func TestUpdateMetricsCmd_AllOrgs(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("RepositoriesForOrgs", spies.AnyArgs, errors.New("metric update failed for 1 of 2 orgs: org2"))
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-allorgs")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{
+		orgs:                []string{"day2devops"},
+		allOrgs:             true,
+		dataDir:             ".",
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(mpSpy.CallsTo("RepositoriesForOrgs")))
+	assert.Nil(t, mpSpy.CallsTo("RepositoriesForOrgs")[0].PassedArgs().Get(0))
+}
+
+func TestUpdateMetricsCmd_Filters(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("RepositoriesForOrg", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-filters")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{
+		orgs:                []string{"testorg"},
+		dataDir:             ".",
+		include:             "^svc-",
+		excludeTopics:       []string{"sandbox"},
+		archived:            "exclude",
+		forks:               "only",
+		visibilities:        []string{"private"},
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+	filter := mpSpy.CallsTo("RepositoriesForOrg")[0].PassedArgs().Get(1).(metrics.Options).Filter
+	assert.Equal(t, "^svc-", filter.Include.String())
+	assert.Nil(t, filter.Exclude)
+	assert.Equal(t, []string{"sandbox"}, filter.ForbiddenTopics)
+	assert.Equal(t, metrics.SelectExclude, filter.Archived)
+	assert.Equal(t, metrics.SelectOnly, filter.Forks)
+	assert.Equal(t, metrics.SelectInclude, filter.Templates)
+	assert.Equal(t, []string{"private"}, filter.Visibilities)
+}
+
+func TestUpdateMetricsCmd_InvalidFilters(t *testing.T) {
+	for _, cmd := range []UpdateMetricsCommand{
+		{include: "svc-("},
+		{exclude: "[a-"},
+		{archived: "sometimes"},
+		{forks: "never"},
+		{templates: "all"},
+		{visibilities: []string{"secret"}},
+	} {
+		err := cmd.UpdateMetricsCmd()
+		assert.Error(t, err)
+	}
+}
+
+func TestUpdateMetricsCmd_Record(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("Repository", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-record")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{
+		orgs:                []string{"testorg"},
+		repo:                "testrepo",
+		dataDir:             "./testdata",
+		httpCache:           true,
+		record:              "./fixtures",
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "authtokenval-record", ghcSpy.Calls()[0].PassedArgs().String(1))
+	opts := ghcSpy.Calls()[0].PassedArgs().Get(2).(github.ClientOptions)
+	assert.Nil(t, opts.Cache)
+	if assert.NotNil(t, opts.Fixtures) {
+		assert.Equal(t, "./fixtures", opts.Fixtures.Dir)
+		assert.False(t, opts.Fixtures.Replay)
+	}
+}
+
+func TestUpdateMetricsCmd_Replay(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("Repository", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	// no token in environment required to replay
+	cmd := UpdateMetricsCommand{
+		orgs:                []string{"testorg"},
+		repo:                "testrepo",
+		dataDir:             "./testdata",
+		replay:              "./fixtures",
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", ghcSpy.Calls()[0].PassedArgs().String(1))
+	opts := ghcSpy.Calls()[0].PassedArgs().Get(2).(github.ClientOptions)
+	if assert.NotNil(t, opts.Fixtures) {
+		assert.Equal(t, "./fixtures", opts.Fixtures.Dir)
+		assert.True(t, opts.Fixtures.Replay)
+	}
+	assert.Equal(t, 1, len(mpSpy.CallsTo("Repository")))
+}
+
+func TestUpdateMetricsCmd_RecordAndReplay(t *testing.T) {
+	cmd := UpdateMetricsCommand{record: "./fixtures", replay: "./fixtures"}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "record and replay can't both be selected", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_User(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("RepositoriesForOrg", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-user")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{
+		orgs:                []string{"day2devops"},
+		user:                "testuser",
+		dataDir:             ".",
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+	assert.True(t, mpfSpy.Calls()[0].PassedArgs().Get(0).(github.Provider).Collector.(github.RepositoryDataCollector).UserOwners)
+	assert.Equal(t, 1, len(mpSpy.CallsTo("RepositoriesForOrg")))
+	assert.Equal(t, "testuser", mpSpy.CallsTo("RepositoriesForOrg")[0].PassedArgs().String(0))
+}
+
+func TestUpdateMetricsCmd_UserAllOrgs(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, &MetricsProcessorSpy{Spy: spies.NewSpy()})
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-userallorgs")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{user: "testuser", allOrgs: true, gitHubClientFactory: ghcSpy, processorFactory: mpfSpy}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "user and all organizations can't both be selected", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_RepositoryMultipleOrgs(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, &MetricsProcessorSpy{Spy: spies.NewSpy()})
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-repositorymultipleorgs")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{orgs: []string{"org1", "org2"}, repo: "testrepo", gitHubClientFactory: ghcSpy, processorFactory: mpfSpy}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "a single organization is required when updating a repository", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_NoOrg(t *testing.T) {
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, &MetricsProcessorSpy{Spy: spies.NewSpy()})
+
+	os.Setenv("GITHUB_AUTH_TOKEN", "authtokenval-noorg")
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	cmd := UpdateMetricsCommand{gitHubClientFactory: ghcSpy, processorFactory: mpfSpy}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "organization not specified", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_AppAuthAllOrgs(t *testing.T) {
+	cmd := UpdateMetricsCommand{auth: "app", appID: 1234, appKeyFile: "./app.pem", allOrgs: true}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "--allOrgs can't be used with app authentication, an app installation only covers the --org organization", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_AppAuthMultipleOrgs(t *testing.T) {
+	cmd := UpdateMetricsCommand{auth: "app", appID: 1234, appKeyFile: "./app.pem", orgs: []string{"org1", "org2"}}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Equal(t, "a single organization is required for app authentication", err.Error())
+	}
+}
+
+func TestUpdateMetricsCmd_ResponseCache(t *testing.T) {
+	// Define spy for github client factory
+	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
+	ghcSpy.MatchMethod("NewGitHubClient", spies.AnyArgs, &gogithub.Client{}, nil)
+
+	// Define spy for metrics processor and factory
+	mpSpy := &MetricsProcessorSpy{Spy: spies.NewSpy()}
+	mpSpy.MatchMethod("RepositoriesForOrg", spies.AnyArgs, nil)
+
+	mpfSpy := &MetricsProcessorFactorySpy{Spy: spies.NewSpy()}
+	mpfSpy.MatchMethod("NewProcessor", spies.AnyArgs, mpSpy)
+
+	// Establish token in environment for test
+	token := "authtokenval-responsecache"
+	os.Setenv("GITHUB_AUTH_TOKEN", token)
+	defer os.Unsetenv("GITHUB_AUTH_TOKEN")
+
+	// Build and execute command
+	cmd := UpdateMetricsCommand{
+		baseURL:             "https://testgithub.edwardjones.com/",
+		orgs:                []string{"testorg"},
+		dataDir:             "./testdata",
+		httpCache:           true,
+		gitHubClientFactory: ghcSpy,
+		processorFactory:    mpfSpy,
+	}
+	err := cmd.UpdateMetricsCmd()
+
+	assert.NoError(t, err)
+
+	opts := ghcSpy.Calls()[0].PassedArgs().Get(2).(github.ClientOptions)
+	if assert.NotNil(t, opts.Cache) {
+		assert.Equal(t, filepath.Join("./testdata", "http-cache"), opts.Cache.Dir)
+	}
+}
+
 func TestUpdateMetricsCmd_AllRepositories_Error(t *testing.T) {
 	// Define spy for github client factory
 	ghcSpy := &GitHubClientFactorySpy{Spy: spies.NewSpy()}
@@ -188,7 +821,7 @@ func TestUpdateMetricsCmd_AllRepositories_Error(t *testing.T) {
 	// Build and execute command
 	cmd := UpdateMetricsCommand{
 		baseURL:             "https://testgithub.edwardjones.com/",
-		org:                 "testorg",
+		orgs:                []string{"testorg"},
 		dataDir:             ".",
 		forceUpdate:         true,
 		gitHubClientFactory: ghcSpy,
@@ -201,7 +834,7 @@ func TestUpdateMetricsCmd_AllRepositories_Error(t *testing.T) {
 	assert.Equal(t, "https://testgithub.edwardjones.com/", ghcSpy.Calls()[0].PassedArgs().Get(0))
 	assert.Equal(t, token, ghcSpy.Calls()[0].PassedArgs().Get(1))
 
-	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.RepositoryDataCollector)
+	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.Provider).Collector.(github.RepositoryDataCollector)
 	if ok {
 		assert.Same(t, oGitHubClient, ghdc.GitHubClient)
 	}
@@ -238,7 +871,7 @@ func TestUpdateMetricsCmd_SpecificRepository(t *testing.T) {
 	// Build and execute command
 	cmd := UpdateMetricsCommand{
 		baseURL:             "https://testgithub.edwardjones.com/",
-		org:                 "testorg",
+		orgs:                []string{"testorg"},
 		dataDir:             ".",
 		repo:                "test-repo",
 		gitHubClientFactory: ghcSpy,
@@ -251,7 +884,7 @@ func TestUpdateMetricsCmd_SpecificRepository(t *testing.T) {
 	assert.Equal(t, "https://testgithub.edwardjones.com/", ghcSpy.Calls()[0].PassedArgs().Get(0))
 	assert.Equal(t, token, ghcSpy.Calls()[0].PassedArgs().Get(1))
 
-	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.RepositoryDataCollector)
+	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.Provider).Collector.(github.RepositoryDataCollector)
 	if ok {
 		assert.Same(t, oGitHubClient, ghdc.GitHubClient)
 	}
@@ -264,6 +897,7 @@ func TestUpdateMetricsCmd_SpecificRepository(t *testing.T) {
 	assert.Equal(t, 1, len(mpSpy.CallsTo("Repository")))
 	assert.Equal(t, "testorg", mpSpy.CallsTo("Repository")[0].PassedArgs().String(0))
 	assert.Equal(t, "test-repo", mpSpy.CallsTo("Repository")[0].PassedArgs().String(1))
+	assert.Equal(t, metrics.DefaultHygienePolicy(), mpSpy.CallsTo("Repository")[0].PassedArgs().Get(2).(metrics.Options).Hygiene)
 }
 
 func TestUpdateMetricsCmd_SpecificRepository_Error(t *testing.T) {
@@ -287,7 +921,7 @@ func TestUpdateMetricsCmd_SpecificRepository_Error(t *testing.T) {
 	// Build and execute command
 	cmd := UpdateMetricsCommand{
 		baseURL:             "https://testgithub.edwardjones.com/",
-		org:                 "testorg",
+		orgs:                []string{"testorg"},
 		dataDir:             ".",
 		repo:                "test-repo",
 		gitHubClientFactory: ghcSpy,
@@ -300,7 +934,7 @@ func TestUpdateMetricsCmd_SpecificRepository_Error(t *testing.T) {
 	assert.Equal(t, "https://testgithub.edwardjones.com/", ghcSpy.Calls()[0].PassedArgs().Get(0))
 	assert.Equal(t, token, ghcSpy.Calls()[0].PassedArgs().Get(1))
 
-	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.RepositoryDataCollector)
+	ghdc, ok := mpfSpy.Calls()[0].PassedArgs().Get(0).(github.Provider).Collector.(github.RepositoryDataCollector)
 	if ok {
 		assert.Same(t, oGitHubClient, ghdc.GitHubClient)
 	}
@@ -320,13 +954,18 @@ type MetricsProcessorSpy struct {
 	metrics.Processor
 }
 
-func (mps *MetricsProcessorSpy) RepositoriesForOrg(org string, options metrics.Options) error {
+func (mps *MetricsProcessorSpy) RepositoriesForOrg(ctx context.Context, org string, options metrics.Options) error {
 	res := mps.Called(org, options)
 	return res.Error(0)
 }
 
-func (mps *MetricsProcessorSpy) Repository(org string, repo string) error {
-	res := mps.Called(org, repo)
+func (mps *MetricsProcessorSpy) RepositoriesForOrgs(ctx context.Context, orgs []string, options metrics.Options) error {
+	res := mps.Called(orgs, options)
+	return res.Error(0)
+}
+
+func (mps *MetricsProcessorSpy) Repository(ctx context.Context, org string, repo string, options metrics.Options) error {
+	res := mps.Called(org, repo, options)
 	return res.Error(0)
 }
 
@@ -335,7 +974,7 @@ type MetricsProcessorFactorySpy struct {
 	metrics.ProcessorCreator
 }
 
-func (mpfs *MetricsProcessorFactorySpy) NewProcessor(collector github.DataCollector, dataMgr metrics.DataManager) metrics.Processor {
+func (mpfs *MetricsProcessorFactorySpy) NewProcessor(collector scm.DataCollector, dataMgr metrics.DataManager) metrics.Processor {
 	res := mpfs.Called(collector, dataMgr)
 	return res.Get(0).(metrics.Processor)
 }
@@ -345,8 +984,8 @@ type GitHubClientFactorySpy struct {
 	github.ClientCreator
 }
 
-func (ghcfs *GitHubClientFactorySpy) NewGitHubClient(baseURL string, token string) (*gogithub.Client, error) {
-	res := ghcfs.Called(baseURL, token)
+func (ghcfs *GitHubClientFactorySpy) NewGitHubClient(baseURL string, token string, opts github.ClientOptions) (*gogithub.Client, error) {
+	res := ghcfs.Called(baseURL, token, opts)
 	client := res.Get(0)
 	if client == nil {
 		return nil, res.Error(1)
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// GetWorkflows retrieves the GitHub Actions workflows defined by organization/repo
+func (m RepositoryDataCollector) GetWorkflows(ctx context.Context, org string, repo string) ([]*gogithub.Workflow, error) {
+	opt := &gogithub.ListOptions{PerPage: 100}
+
+	// empty rather than nil when none are defined, nil indicating the workflows weren't collected
+	allWorkflows := []*gogithub.Workflow{}
+	for {
+		glog.V(2).Infof("Collecting workflows for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
+		workflows, resp, err := m.GitHubClient.Actions.ListWorkflows(ctx, org, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+		allWorkflows = append(allWorkflows, workflows.Workflows...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allWorkflows, nil
+}
+
+// GetWorkflowRuns retrieves the GitHub Actions workflow runs created since the supplied time by
+// organization/repo, flagging when truncated by the page limit
+func (m RepositoryDataCollector) GetWorkflowRuns(ctx context.Context, org string, repo string, since time.Time) ([]*gogithub.WorkflowRun, bool, error) {
+	opt := &gogithub.ListWorkflowRunsOptions{
+		Created:     ">=" + since.UTC().Format(time.RFC3339),
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+
+	// process all pages until finished
+	var loopCnt = 0
+	var allRuns []*gogithub.WorkflowRun
+	for {
+		// stop looking for runs once the page limit is hit
+		loopCnt++
+		if pageLimitReached(m.PageLimits.WorkflowRuns, loopCnt) {
+			glog.Warningf("Repository has more than %d workflow runs: %s/%s", len(allRuns), org, repo)
+			return allRuns, true, nil
+		}
+
+		glog.V(2).Infof("Collecting workflow runs for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
+		runs, resp, err := m.GitHubClient.Actions.ListRepositoryWorkflowRuns(ctx, org, repo, opt)
+		if err != nil {
+			return nil, false, err
+		}
+		allRuns = append(allRuns, runs.WorkflowRuns...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allRuns, false, nil
+}
+
+// collect the workflows and their recent runs, skipping the runs when no workflows are defined.  Actions
+// disabled for the repository leave the workflows uncollected, other errors are returned.
+func (m RepositoryDataCollector) collectWorkflows(ctx context.Context, org string, repo string) ([]*gogithub.Workflow, []*gogithub.WorkflowRun, bool, error) {
+	workflows, err := m.GetWorkflows(ctx, org, repo)
+	if err != nil {
+		if featureUnavailable(err) {
+			glog.V(2).Infof("Workflows not available for %s/%s: %s", org, repo, err)
+			return nil, nil, false, nil
+		}
+		return nil, nil, false, err
+	}
+	if len(workflows) == 0 {
+		return workflows, nil, false, nil
+	}
+
+	runs, truncated, err := m.GetWorkflowRuns(ctx, org, repo, time.Now().Add(-scm.WorkflowRunHistory))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return workflows, runs, truncated, nil
+}
@@ -0,0 +1,151 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func TestGetWorkflowRuns_MultiplePages(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposActionsRunsByOwnerByRepo,
+			github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{{ID: github.Int64(1)}, {ID: github.Int64(2)}}},
+			github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{{ID: github.Int64(3)}}},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	runs, truncated, err := m.GetWorkflowRuns(context.Background(), "testorg", "testrepo", time.Now())
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, 3, len(runs))
+}
+
+func TestGetWorkflowRuns_CreatedSince(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposActionsRunsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, ">=2021-10-01T10:00:00Z", r.URL.Query().Get("created"))
+				_, err := w.Write([]byte(`{"total_count": 1, "workflow_runs": [{"id": 1}]}`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	since := time.Date(2021, 10, 1, 10, 0, 0, 0, time.UTC)
+	runs, _, err := m.GetWorkflowRuns(context.Background(), "testorg", "testrepo", since)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(runs))
+}
+
+func TestGetWorkflowRuns_ExceedPageLimit(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposActionsRunsByOwnerByRepo,
+			github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{{ID: github.Int64(1)}}},
+			github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{{ID: github.Int64(2)}}},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{WorkflowRuns: 1}}
+
+	runs, truncated, err := m.GetWorkflowRuns(context.Background(), "testorg", "testrepo", time.Now())
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, 1, len(runs))
+}
+
+func TestCollectWorkflows_NoWorkflows(t *testing.T) {
+	runCalls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposActionsWorkflowsByOwnerByRepo,
+			github.Workflows{TotalCount: github.Int(0), Workflows: []*github.Workflow{}},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposActionsRunsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				runCalls++
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	workflows, runs, truncated, err := m.collectWorkflows(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, workflows)
+	assert.Equal(t, 0, len(workflows))
+	assert.Nil(t, runs)
+	assert.False(t, truncated)
+	assert.Equal(t, 0, runCalls)
+}
+
+func TestCollectWorkflows_APIError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposActionsWorkflowsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusForbidden, "resource not accessible by integration")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	workflows, runs, _, err := m.collectWorkflows(context.Background(), "testorg", "testrepo")
+
+	assert.Error(t, err)
+	assert.Nil(t, workflows)
+	assert.Nil(t, runs)
+}
+
+func TestCollectWorkflows(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposActionsWorkflowsByOwnerByRepo,
+			github.Workflows{TotalCount: github.Int(1), Workflows: []*github.Workflow{{ID: github.Int64(7), Name: github.String("ci")}}},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposActionsRunsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.True(t, strings.HasPrefix(r.URL.Query().Get("created"), ">="))
+				_, err := w.Write([]byte(`{"total_count": 1, "workflow_runs": [{"id": 1, "workflow_id": 7}]}`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	workflows, runs, truncated, err := m.collectWorkflows(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(workflows))
+	assert.Equal(t, 1, len(runs))
+	assert.Equal(t, int64(7), runs[0].GetWorkflowID())
+	assert.False(t, truncated)
+}
@@ -0,0 +1,169 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/v39/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// lifetime of the app jwt, GitHub allows at most 10 minutes
+	appJWTLifetime = 9 * time.Minute
+
+	// backdate the app jwt issue time to allow for clock differences with GitHub
+	appJWTClockSkew = time.Minute
+
+	// refresh installation tokens this long before GitHub expires them
+	installationTokenRefresh = 5 * time.Minute
+)
+
+// AppCredentials identify a GitHub App used to mint installation access tokens
+type AppCredentials struct {
+	AppID          int64
+	PrivateKeyFile string
+	// InstallationID of the app, discovered using Org when not supplied
+	InstallationID int64
+	Org            string
+	// User set when Org is a user account the app is installed on
+	User bool
+}
+
+// appTransport http transport authenticating requests as the GitHub App using a signed jwt
+type appTransport struct {
+	base  http.RoundTripper
+	appID int64
+	key   *rsa.PrivateKey
+	now   func() time.Time
+}
+
+// installationTokenSource oauth2 token source minting installation access tokens for the app
+type installationTokenSource struct {
+	client         *github.Client
+	installationID int64
+}
+
+// newInstallationTokenSource builds a token source for the app installation that refreshes
+// the installation token on its own as it nears expiration, returned along with the installation ID
+func newInstallationTokenSource(baseURL string, base http.RoundTripper, creds AppCredentials) (oauth2.TokenSource, int64, error) {
+	key, err := loadAppPrivateKey(creds.PrivateKeyFile)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	transport := &appTransport{base: base, appID: creds.AppID, key: key, now: time.Now}
+	client, err := github.NewEnterpriseClient(baseURL, baseURL, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	installationID := creds.InstallationID
+	if installationID == 0 {
+		if creds.Org == "" {
+			return nil, 0, errors.New("GitHub App installation ID or organization required")
+		}
+		glog.V(2).Infof("Discovering installation of GitHub App %d for org %s", creds.AppID, creds.Org)
+		find := client.Apps.FindOrganizationInstallation
+		if creds.User {
+			find = client.Apps.FindUserInstallation
+		}
+		installation, _, err := find(context.Background(), creds.Org)
+		if err != nil {
+			return nil, 0, err
+		}
+		installationID = installation.GetID()
+	}
+	glog.V(2).Infof("Using installation %d of GitHub App %d", installationID, creds.AppID)
+
+	src := &installationTokenSource{client: client, installationID: installationID}
+	return oauth2.ReuseTokenSource(nil, src), installationID, nil
+}
+
+// Token mints a new installation access token
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.client.Apps.CreateInstallationToken(context.Background(), s.installationID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := token.GetExpiresAt().Add(-installationTokenRefresh)
+	glog.V(2).Infof("Minted token for installation %d, expires at %s", s.installationID, token.GetExpiresAt())
+	return &oauth2.Token{AccessToken: token.GetToken(), Expiry: expiry}, nil
+}
+
+// RoundTrip executes the request authenticated as the app
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := t.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+jwt)
+	return t.base.RoundTrip(r)
+}
+
+// builds a signed (RS256) jwt identifying the app
+func (t *appTransport) jwt() (string, error) {
+	now := t.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": t.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, t.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// read the app private key from the supplied PEM file (PKCS1 as issued by GitHub, or PKCS8)
+func loadAppPrivateKey(file string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in GitHub App private key file %s", file)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App private key in %s is not an RSA key", file)
+	}
+	return key, nil
+}
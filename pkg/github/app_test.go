@@ -0,0 +1,261 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// write a new private key to a PEM file in a temporary directory
+func testAppKeyFile(t *testing.T, pkcs8 bool) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if pkcs8 {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	file := filepath.Join(t.TempDir(), "app.pem")
+	if err = ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key, file
+}
+
+// verify the supplied jwt was signed by the key and issued by the app
+func assertAppJWT(t *testing.T, key *rsa.PrivateKey, appID int64, jwt string) {
+	parts := strings.Split(jwt, ".")
+	if !assert.Equal(t, 3, len(parts)) {
+		return
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+	claims := map[string]int64{}
+	assert.NoError(t, json.Unmarshal(data, &claims))
+	assert.Equal(t, appID, claims["iss"])
+	assert.True(t, claims["exp"]-claims["iat"] <= int64((10*time.Minute).Seconds()))
+}
+
+func TestNewGitHubClient_AppInstallationDiscovery(t *testing.T) {
+	key, keyFile := testAppKeyFile(t, false)
+
+	tokenCalls := 0
+	var repoAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/orgs/testorg/installation":
+			assertAppJWT(t, key, 1234, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+			_, err := w.Write([]byte(`{"id": 42}`))
+			assert.NoError(t, err)
+		case "/api/v3/app/installations/42/access_tokens":
+			tokenCalls++
+			assertAppJWT(t, key, 1234, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+			expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			w.WriteHeader(http.StatusCreated)
+			_, err := w.Write([]byte(`{"token": "ghs_installationtoken", "expires_at": "` + expires + `"}`))
+			assert.NoError(t, err)
+		case "/api/v3/repos/testorg/testrepo":
+			repoAuth = r.Header.Get("Authorization")
+			_, err := w.Write([]byte(`{"id": 123, "name": "testrepo"}`))
+			assert.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := ClientFactory{}.NewGitHubClient(server.URL, "", ClientOptions{
+		App: &AppCredentials{AppID: 1234, PrivateKeyFile: keyFile, Org: "testorg"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		repo, _, err := client.Repositories.Get(context.Background(), "testorg", "testrepo")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(123), repo.GetID())
+	}
+	assert.Equal(t, "Bearer ghs_installationtoken", repoAuth)
+	assert.Equal(t, 1, tokenCalls)
+}
+
+func TestNewGitHubClient_AppUserInstallationDiscovery(t *testing.T) {
+	_, keyFile := testAppKeyFile(t, false)
+
+	var repoAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/users/testuser/installation":
+			_, err := w.Write([]byte(`{"id": 43}`))
+			assert.NoError(t, err)
+		case "/api/v3/app/installations/43/access_tokens":
+			expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			w.WriteHeader(http.StatusCreated)
+			_, err := w.Write([]byte(`{"token": "ghs_usertoken", "expires_at": "` + expires + `"}`))
+			assert.NoError(t, err)
+		case "/api/v3/repos/testuser/testrepo":
+			repoAuth = r.Header.Get("Authorization")
+			_, err := w.Write([]byte(`{"id": 123, "name": "testrepo"}`))
+			assert.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := ClientFactory{}.NewGitHubClient(server.URL, "", ClientOptions{
+		App: &AppCredentials{AppID: 1234, PrivateKeyFile: keyFile, Org: "testuser", User: true},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, _, err = client.Repositories.Get(context.Background(), "testuser", "testrepo")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer ghs_usertoken", repoAuth)
+}
+
+func TestNewGitHubClient_AppTokenRefresh(t *testing.T) {
+	_, keyFile := testAppKeyFile(t, true)
+
+	tokenCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/app/installations/77/access_tokens":
+			tokenCalls++
+			// expires within the refresh window so every use mints a new token
+			expires := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+			w.WriteHeader(http.StatusCreated)
+			_, err := w.Write([]byte(`{"token": "ghs_token", "expires_at": "` + expires + `"}`))
+			assert.NoError(t, err)
+		default:
+			_, err := w.Write([]byte(`{}`))
+			assert.NoError(t, err)
+		}
+	}))
+	defer server.Close()
+
+	client, err := ClientFactory{}.NewGitHubClient(server.URL, "", ClientOptions{
+		App: &AppCredentials{AppID: 99, PrivateKeyFile: keyFile, InstallationID: 77},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _, err := client.Repositories.Get(context.Background(), "testorg", "testrepo")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, tokenCalls)
+}
+
+func TestNewGitHubClient_AppCacheSharedByInstallationTokens(t *testing.T) {
+	_, keyFile := testAppKeyFile(t, false)
+
+	tokenCalls := 0
+	var repoAuth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/app/installations/77/access_tokens":
+			tokenCalls++
+			expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			w.WriteHeader(http.StatusCreated)
+			_, err := w.Write([]byte(`{"token": "ghs_token` + strconv.Itoa(tokenCalls) + `", "expires_at": "` + expires + `"}`))
+			assert.NoError(t, err)
+		case "/api/v3/repos/testorg/testrepo":
+			repoAuth = append(repoAuth, r.Header.Get("Authorization"))
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			_, err := w.Write([]byte(`{"id": 123, "name": "testrepo"}`))
+			assert.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// each run mints its own installation token
+	cache := NewResponseCache(t.TempDir())
+	for i := 0; i < 2; i++ {
+		client, err := ClientFactory{}.NewGitHubClient(server.URL, "", ClientOptions{
+			Cache: cache,
+			App:   &AppCredentials{AppID: 99, PrivateKeyFile: keyFile, InstallationID: 77},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		repo, _, err := client.Repositories.Get(context.Background(), "testorg", "testrepo")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(123), repo.GetID())
+	}
+
+	assert.Equal(t, []string{"Bearer ghs_token1", "Bearer ghs_token2"}, repoAuth)
+	hits, misses := cache.Stats()
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, int64(1), misses)
+}
+
+func TestNewGitHubClient_AppMissingKeyFile(t *testing.T) {
+	client, err := ClientFactory{}.NewGitHubClient("https://api.github.com/", "", ClientOptions{
+		App: &AppCredentials{AppID: 99, PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem"), InstallationID: 77},
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, client)
+}
+
+func TestNewGitHubClient_AppMissingInstallation(t *testing.T) {
+	_, keyFile := testAppKeyFile(t, false)
+	client, err := ClientFactory{}.NewGitHubClient("https://api.github.com/", "", ClientOptions{
+		App: &AppCredentials{AppID: 99, PrivateKeyFile: keyFile},
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, client)
+}
+
+func TestLoadAppPrivateKey_NotPEM(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.pem")
+	if err := ioutil.WriteFile(file, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := loadAppPrivateKey(file)
+
+	assert.Error(t, err)
+	assert.Nil(t, key)
+}
@@ -0,0 +1,77 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// GetBranchActivity retrieves the last commit date of each branch other than the default branch and whether it
+// has been merged into the default branch by branch name.  The commit date is only looked up when not already
+// part of the branch commit.  A branch sharing no history with the default branch (e.g. an orphan gh-pages branch)
+// is recorded as not merged, any other error failing the collection.
+func (m RepositoryDataCollector) GetBranchActivity(ctx context.Context, org string, repo string, defaultBranch string, branches []*gogithub.Branch) (map[string]scm.BranchActivity, error) {
+	activity := make(map[string]scm.BranchActivity)
+	for _, b := range branches {
+		if b.GetName() == defaultBranch {
+			continue
+		}
+
+		lastCommit := committedAt(b.GetCommit().GetCommit())
+		if lastCommit == nil {
+			glog.V(3).Infof("Collecting last commit of branch %s for %s/%s", b.GetName(), org, repo)
+			commit, _, err := m.GitHubClient.Git.GetCommit(ctx, org, repo, b.GetCommit().GetSHA())
+			if err != nil {
+				return nil, err
+			}
+			lastCommit = committedAt(commit)
+		}
+
+		// a branch behind or identical to the default branch has been merged, commits aren't needed
+		glog.V(3).Infof("Comparing branch %s with %s for %s/%s", b.GetName(), defaultBranch, org, repo)
+		comparison, _, err := m.GitHubClient.Repositories.CompareCommits(ctx, org, repo, defaultBranch, b.GetName(), &gogithub.ListOptions{PerPage: 1})
+		if err != nil && !noCommonAncestor(err) {
+			return nil, err
+		}
+		if err != nil {
+			glog.V(2).Infof("Branch %s shares no history with %s for %s/%s, recording it as not merged", b.GetName(), defaultBranch, org, repo)
+		}
+
+		activity[b.GetName()] = scm.BranchActivity{
+			LastCommit: lastCommit,
+			Merged:     err == nil && comparison.GetAheadBy() == 0,
+		}
+	}
+	return activity, nil
+}
+
+// collect the branch activity, nil when the default branch isn't known
+func (m RepositoryDataCollector) collectBranchActivity(ctx context.Context, org string, repo string, defaultBranch string, branches []*gogithub.Branch) (map[string]scm.BranchActivity, error) {
+	if defaultBranch == "" {
+		return nil, nil
+	}
+	return m.GetBranchActivity(ctx, org, repo, defaultBranch, branches)
+}
+
+// determine if the comparison failed because the branches share no history, GitHub answering 404 with a
+// message naming the missing common ancestor
+func noCommonAncestor(err error) bool {
+	var errResp *gogithub.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound &&
+		strings.Contains(strings.ToLower(errResp.Message), "no common ancestor")
+}
+
+// committer date of the commit, nil when not known
+func committedAt(commit *gogithub.Commit) *time.Time {
+	if commit.GetCommitter() == nil {
+		return nil
+	}
+	return commit.GetCommitter().Date
+}
@@ -0,0 +1,167 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBranchActivity(t *testing.T) {
+	committed := time.Date(2021, 9, 15, 10, 0, 0, 0, time.UTC)
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposGitCommitsByOwnerByRepoByCommitSha,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.True(t, strings.HasSuffix(r.URL.Path, "/git/commits/def"))
+				_, err := w.Write([]byte(`{"sha": "def", "committer": {"date": "2021-06-01T10:00:00Z"}}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposCompareByOwnerByRepoByBasehead,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "1", r.URL.Query().Get("per_page"))
+				aheadBy := "0"
+				if strings.HasSuffix(r.URL.Path, "main...active") {
+					aheadBy = "3"
+				}
+				_, err := w.Write([]byte(`{"ahead_by": ` + aheadBy + `}`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	activity, err := m.GetBranchActivity(context.Background(), "testorg", "testrepo", "main", []*github.Branch{
+		{Name: github.String("main"), Commit: &github.RepositoryCommit{SHA: github.String("abc")}},
+		{Name: github.String("merged"), Commit: &github.RepositoryCommit{SHA: github.String("def")}},
+		{Name: github.String("active"), Commit: &github.RepositoryCommit{
+			SHA:    github.String("ghi"),
+			Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &committed}},
+		}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(activity))
+	assert.Equal(t, "2021-06-01 10:00:00 +0000 UTC", activity["merged"].LastCommit.String())
+	assert.True(t, activity["merged"].Merged)
+	assert.Equal(t, committed, *activity["active"].LastCommit)
+	assert.False(t, activity["active"].Merged)
+}
+
+func TestCollectBranchActivity_NoCommonAncestor(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposCompareByOwnerByRepoByBasehead,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "main...gh-pages") {
+					writeGitHubError(t, w, http.StatusNotFound, "No common ancestor between main and gh-pages.")
+					return
+				}
+				_, err := w.Write([]byte(`{"ahead_by": 0}`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	committed := time.Now()
+	activity, err := m.collectBranchActivity(context.Background(), "testorg", "testrepo", "main", []*github.Branch{
+		{Name: github.String("gh-pages"), Commit: &github.RepositoryCommit{
+			Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &committed}},
+		}},
+		{Name: github.String("merged"), Commit: &github.RepositoryCommit{
+			Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &committed}},
+		}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(activity))
+	assert.Equal(t, committed, *activity["gh-pages"].LastCommit)
+	assert.False(t, activity["gh-pages"].Merged)
+	assert.True(t, activity["merged"].Merged)
+}
+
+func TestCollectBranchActivity_CompareError(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden, http.StatusInternalServerError} {
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(
+				mock.GetReposCompareByOwnerByRepoByBasehead,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					writeGitHubError(t, w, status, "You have exceeded a secondary rate limit")
+				}),
+			),
+		)
+
+		c := github.NewClient(mockedHTTPClient)
+		m := RepositoryDataCollector{GitHubClient: c}
+
+		committed := time.Now()
+		activity, err := m.collectBranchActivity(context.Background(), "testorg", "testrepo", "main", []*github.Branch{
+			{Name: github.String("feature"), Commit: &github.RepositoryCommit{
+				Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &committed}},
+			}},
+		})
+
+		assert.Error(t, err, status)
+		assert.Nil(t, activity, status)
+	}
+}
+
+func TestCollectBranchActivity_CommitError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposGitCommitsByOwnerByRepoByCommitSha,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusInternalServerError, "github went belly up or something")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	activity, err := m.collectBranchActivity(context.Background(), "testorg", "testrepo", "main", []*github.Branch{
+		{Name: github.String("missing"), Commit: &github.RepositoryCommit{SHA: github.String("def")}},
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, activity)
+}
+
+func TestCollectBranchActivity_Cancelled(t *testing.T) {
+	c := github.NewClient(mock.NewMockedHTTPClient())
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	committed := time.Now()
+	activity, err := m.collectBranchActivity(ctx, "testorg", "testrepo", "main", []*github.Branch{
+		{Name: github.String("feature"), Commit: &github.RepositoryCommit{
+			Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &committed}},
+		}},
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, activity)
+}
+
+func TestCollectBranchActivity_NoDefaultBranch(t *testing.T) {
+	c := github.NewClient(mock.NewMockedHTTPClient())
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	activity, err := m.collectBranchActivity(context.Background(), "testorg", "testrepo", "", []*github.Branch{{Name: github.String("feature")}})
+
+	assert.NoError(t, err)
+	assert.Nil(t, activity)
+}
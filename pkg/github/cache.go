@@ -0,0 +1,224 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/golang/glog"
+)
+
+// ResponseCache persistent cache of GitHub responses used to send conditional requests.  GitHub
+// answers a conditional request with 304 Not Modified when nothing has changed, which doesn't
+// count against the rate limit, and the cached response is served in its place.
+type ResponseCache struct {
+	Dir    string
+	hits   int64
+	misses int64
+}
+
+// cacheEntry cached response for a url along with its validators
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag"`
+	LastModified string      `json:"lastModified"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+// cacheTransport http transport adding conditional request support using a response cache, the entries being
+// kept apart by the identity of the credentials authenticating the requests
+type cacheTransport struct {
+	cache    *ResponseCache
+	base     http.RoundTripper
+	identity string
+}
+
+// NewResponseCache creates a response cache persisted in the supplied directory
+func NewResponseCache(dir string) *ResponseCache {
+	return &ResponseCache{Dir: dir}
+}
+
+// Stats returns the number of requests served from the cache and the number that were not
+func (c *ResponseCache) Stats() (hits int64, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+// wraps the supplied transport with conditional request handling for requests authenticated by the identity
+func (c *ResponseCache) transport(base http.RoundTripper, identity string) http.RoundTripper {
+	return &cacheTransport{cache: c, base: base, identity: identity}
+}
+
+// RoundTrip executes the request, sending the cached validators and serving the cached
+// response when GitHub reports it has not been modified
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := t.cache.key(req, t.identity)
+	entry, found := t.cache.read(key)
+
+	condReq := req
+	if found {
+		condReq = req.Clone(req.Context())
+		if entry.ETag != "" {
+			condReq.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			condReq.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(condReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		atomic.AddInt64(&t.cache.hits, 1)
+		glog.V(3).Infof("Response cache hit for %s", req.URL)
+		return entry.response(req, resp), nil
+	}
+
+	atomic.AddInt64(&t.cache.misses, 1)
+	glog.V(3).Infof("Response cache miss for %s", req.URL)
+	if resp.StatusCode == http.StatusOK {
+		return t.cache.store(key, req, resp)
+	}
+	return resp, nil
+}
+
+// store the response when it carries validators, returning a response with a readable body
+func (c *ResponseCache) store(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	closeQuietly(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry := cacheEntry{
+		URL:          req.URL.String(),
+		ETag:         etag,
+		LastModified: lastModified,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+	}
+	if err = c.write(key, entry); err != nil {
+		glog.Warningf("Problem writing response cache entry for %s: %s", req.URL, err.Error())
+	}
+	return resp, nil
+}
+
+// builds the cache key for the request, responses vary by url, requested media type and the identity of the
+// credentials used, so a response visible to one identity is never served to another.  The identity is stable
+// across runs where the tokens themselves may not be, e.g. installation tokens minted each run.
+func (c *ResponseCache) key(req *http.Request, identity string) string {
+	sum := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Accept") + "\n" + identity))
+	return hex.EncodeToString(sum[:])
+}
+
+// identity of the personal access token for keying the cache, a hash so the token isn't part of the key
+func tokenIdentity(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token/" + hex.EncodeToString(sum[:])
+}
+
+// identity of the app installation for keying the cache, shared by every token minted for the installation
+func installationIdentity(installationID int64) string {
+	return "installation/" + strconv.FormatInt(installationID, 10)
+}
+
+// builds the file name for the supplied cache key
+func (c *ResponseCache) fileName(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// read the cache entry for the supplied key
+func (c *ResponseCache) read(key string) (cacheEntry, bool) {
+	var entry cacheEntry
+	data, err := ioutil.ReadFile(c.fileName(key))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			glog.Warningf("Problem reading response cache entry %s, treating as not found: %s", key, err.Error())
+		}
+		return entry, false
+	}
+	if err = json.Unmarshal(data, &entry); err != nil {
+		glog.Warningf("Problem parsing response cache entry %s, treating as not found: %s", key, err.Error())
+		return entry, false
+	}
+	return entry, true
+}
+
+// write the cache entry for the supplied key, replacing the file so readers never see a partial entry
+func (c *ResponseCache) write(key string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.fileName(key))
+	}
+	if err != nil {
+		if rmErr := os.Remove(tmp.Name()); rmErr != nil {
+			glog.V(3).Infof("Problem removing temporary cache file %s: %s", tmp.Name(), rmErr.Error())
+		}
+	}
+	return err
+}
+
+// build the response served for a not modified reply, the headers from the reply
+// take precedence so the rate limit and validator values stay current
+func (e cacheEntry) response(req *http.Request, notModified *http.Response) *http.Response {
+	drainAndClose(notModified)
+
+	header := e.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for k, v := range notModified.Header {
+		header[k] = v
+	}
+	header.Del("Content-Length")
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,164 @@
+package github
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// issue a get request using the supplied client, returning status and body
+func cacheTestGet(t *testing.T, client *http.Client, url string) (int, string) {
+	resp, err := client.Get(url)
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestResponseCache_ETag(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set(headerRateRemaining, "4999")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Link", `<https://api.github.com/next>; rel="next"`)
+		_, err := w.Write([]byte(`[{"name":"branch-1"}]`))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	cache := NewResponseCache(t.TempDir())
+	client := &http.Client{Transport: cache.transport(http.DefaultTransport, tokenIdentity("token"))}
+
+	status, body := cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo/branches")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `[{"name":"branch-1"}]`, body)
+
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo/branches")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	cached, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `[{"name":"branch-1"}]`, string(cached))
+	assert.Equal(t, `<https://api.github.com/next>; rel="next"`, resp.Header.Get("Link"))
+	assert.Equal(t, "4999", resp.Header.Get(headerRateRemaining))
+
+	hits, misses := cache.Stats()
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, int64(1), misses)
+}
+
+func TestResponseCache_LastModified(t *testing.T) {
+	lastModified := "Mon, 01 Nov 2021 10:00:00 GMT"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+		_, err := w.Write([]byte(`{"Go":5000}`))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	cache := NewResponseCache(t.TempDir())
+	client := &http.Client{Transport: cache.transport(http.DefaultTransport, tokenIdentity("token"))}
+
+	cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo/languages")
+	status, body := cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo/languages")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"Go":5000}`, body)
+	hits, misses := cache.Stats()
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, int64(1), misses)
+}
+
+func TestResponseCache_ChangedContent(t *testing.T) {
+	version := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == version {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", version)
+		_, err := w.Write([]byte(version))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	cache := NewResponseCache(t.TempDir())
+	client := &http.Client{Transport: cache.transport(http.DefaultTransport, tokenIdentity("token"))}
+
+	cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo/topics")
+	version = "v2"
+	_, body := cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo/topics")
+	assert.Equal(t, "v2", body)
+	_, body = cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo/topics")
+	assert.Equal(t, "v2", body)
+
+	hits, misses := cache.Stats()
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, int64(2), misses)
+}
+
+func TestResponseCache_NoValidators(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "", r.Header.Get("If-None-Match"))
+		_, err := w.Write([]byte("data"))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	cache := NewResponseCache(t.TempDir())
+	client := &http.Client{Transport: cache.transport(http.DefaultTransport, tokenIdentity("token"))}
+
+	cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo")
+	_, body := cacheTestGet(t, client, server.URL+"/repos/testorg/testrepo")
+
+	assert.Equal(t, "data", body)
+	assert.Equal(t, 2, calls)
+	hits, misses := cache.Stats()
+	assert.Equal(t, int64(0), hits)
+	assert.Equal(t, int64(2), misses)
+}
+
+func TestResponseCache_VariesByAccept(t *testing.T) {
+	cache := NewResponseCache(t.TempDir())
+
+	req1, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos/testorg/testrepo", nil)
+	assert.NoError(t, err)
+	req2 := req1.Clone(req1.Context())
+	req2.Header.Set("Accept", "application/vnd.github.mercy-preview+json")
+
+	assert.NotEqual(t, cache.key(req1, "token/abc"), cache.key(req2, "token/abc"))
+}
+
+func TestResponseCache_VariesByIdentity(t *testing.T) {
+	cache := NewResponseCache(t.TempDir())
+
+	req1, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos/testorg/testrepo", nil)
+	assert.NoError(t, err)
+	req1.Header.Set("Authorization", "Bearer token1")
+	req2 := req1.Clone(req1.Context())
+	req2.Header.Set("Authorization", "Bearer token2")
+
+	assert.Equal(t, cache.key(req1, installationIdentity(42)), cache.key(req2, installationIdentity(42)))
+	assert.NotEqual(t, cache.key(req1, installationIdentity(42)), cache.key(req1, installationIdentity(43)))
+	assert.NotEqual(t, cache.key(req1, tokenIdentity("token1")), cache.key(req2, tokenIdentity("token2")))
+	assert.NotContains(t, tokenIdentity("token1"), "token1")
+}
@@ -0,0 +1,81 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+)
+
+// GetDeployments retrieves the deployments created since the supplied time by organization/repo, flagging
+// when truncated by the page limit
+func (m RepositoryDataCollector) GetDeployments(ctx context.Context, org string, repo string, since time.Time) ([]*gogithub.Deployment, bool, error) {
+	opt := &gogithub.DeploymentsListOptions{
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+
+	// process all pages until finished, deployments are listed newest first
+	var loopCnt = 0
+	var allDeployments []*gogithub.Deployment
+	for {
+		// stop looking for deployments once the page limit is hit
+		loopCnt++
+		if pageLimitReached(m.PageLimits.Deployments, loopCnt) {
+			glog.Warningf("Repository has more than %d deployments: %s/%s", len(allDeployments), org, repo)
+			return allDeployments, true, nil
+		}
+
+		glog.V(2).Infof("Collecting deployments for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
+		deployments, resp, err := m.GitHubClient.Repositories.ListDeployments(ctx, org, repo, opt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, d := range deployments {
+			if d.CreatedAt != nil && d.CreatedAt.Before(since) {
+				return allDeployments, false, nil
+			}
+			allDeployments = append(allDeployments, d)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allDeployments, false, nil
+}
+
+// GetDeploymentStatuses retrieves the most recent statuses (up to 100) of each deployment by deployment ID
+func (m RepositoryDataCollector) GetDeploymentStatuses(ctx context.Context, org string, repo string, deployments []*gogithub.Deployment) (map[int64][]*gogithub.DeploymentStatus, error) {
+	statuses := make(map[int64][]*gogithub.DeploymentStatus)
+	for _, d := range deployments {
+		glog.V(3).Infof("Collecting deployment statuses for %s/%s deployment %d", org, repo, d.GetID())
+		s, _, err := m.GitHubClient.Repositories.ListDeploymentStatuses(ctx, org, repo, d.GetID(), &gogithub.ListOptions{PerPage: 100})
+		if err != nil {
+			return nil, err
+		}
+		statuses[d.GetID()] = s
+	}
+	return statuses, nil
+}
+
+// collect the deployments created since the supplied time along with their statuses, uncollected when no time
+// is supplied
+func (m RepositoryDataCollector) collectDeployments(ctx context.Context, org string, repo string, since *time.Time) ([]*gogithub.Deployment, map[int64][]*gogithub.DeploymentStatus, bool, error) {
+	if since == nil {
+		return nil, nil, false, nil
+	}
+
+	deployments, truncated, err := m.GetDeployments(ctx, org, repo, *since)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	statuses, err := m.GetDeploymentStatuses(ctx, org, repo, deployments)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return deployments, statuses, truncated, nil
+}
@@ -0,0 +1,120 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func TestGetDeployments_StopsBeforeSince(t *testing.T) {
+	since := time.Date(2021, 10, 1, 10, 0, 0, 0, time.UTC)
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposDeploymentsByOwnerByRepo,
+			[]github.Deployment{
+				{ID: github.Int64(3), CreatedAt: &github.Timestamp{Time: since.Add(2 * time.Hour)}},
+				{ID: github.Int64(2), CreatedAt: &github.Timestamp{Time: since.Add(time.Hour)}},
+			},
+			[]github.Deployment{
+				{ID: github.Int64(1), CreatedAt: &github.Timestamp{Time: since.Add(-time.Hour)}},
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	deployments, truncated, err := m.GetDeployments(context.Background(), "testorg", "testrepo", since)
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, 2, len(deployments))
+	assert.Equal(t, int64(3), deployments[0].GetID())
+	assert.Equal(t, int64(2), deployments[1].GetID())
+}
+
+func TestGetDeployments_ExceedPageLimit(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposDeploymentsByOwnerByRepo,
+			[]github.Deployment{{ID: github.Int64(2)}},
+			[]github.Deployment{{ID: github.Int64(1)}},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{Deployments: 1}}
+
+	deployments, truncated, err := m.GetDeployments(context.Background(), "testorg", "testrepo", time.Time{})
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, 1, len(deployments))
+}
+
+func TestCollectDeployments(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposDeploymentsByOwnerByRepo,
+			[]github.Deployment{{ID: github.Int64(1)}, {ID: github.Int64(2)}},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposDeploymentsStatusesByOwnerByRepoByDeploymentId,
+			[]github.DeploymentStatus{{ID: github.Int64(10), State: github.String("success")}},
+			[]github.DeploymentStatus{{ID: github.Int64(20), State: github.String("failure")}},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	since := time.Time{}
+	deployments, statuses, truncated, err := m.collectDeployments(context.Background(), "testorg", "testrepo", &since)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(deployments))
+	assert.False(t, truncated)
+	assert.Equal(t, "success", statuses[1][0].GetState())
+	assert.Equal(t, "failure", statuses[2][0].GetState())
+}
+
+func TestCollectDeployments_NotRequested(t *testing.T) {
+	c := github.NewClient(mock.NewMockedHTTPClient())
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	deployments, statuses, truncated, err := m.collectDeployments(context.Background(), "testorg", "testrepo", nil)
+
+	assert.NoError(t, err)
+	assert.Nil(t, deployments)
+	assert.Nil(t, statuses)
+	assert.False(t, truncated)
+}
+
+func TestCollectDeployments_Error(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposDeploymentsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusForbidden, "Resource not accessible by integration")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	since := time.Time{}
+	deployments, statuses, truncated, err := m.collectDeployments(context.Background(), "testorg", "testrepo", &since)
+
+	assert.Error(t, err)
+	assert.Nil(t, deployments)
+	assert.Nil(t, statuses)
+	assert.False(t, truncated)
+}
@@ -0,0 +1,272 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// redactedToken replaces installation access tokens in the recorded responses
+const redactedToken = "REDACTED"
+
+// volatileParams query parameters holding times relative to the run, left out of fixture keys so a
+// replay made later still finds the recorded responses
+var volatileParams = []string{"created", "since"}
+
+// Fixtures directory of recorded GitHub responses.  When recording, every response received is saved to
+// the directory.  When replaying, requests are answered from the directory without using the network.
+// Repeated requests are answered in the order recorded, the last response answering any further requests.
+type Fixtures struct {
+	Dir     string
+	Replay  bool
+	mu      sync.Mutex
+	entries map[string][]fixtureResponse
+	served  map[string]int
+	misses  int
+}
+
+// fixtureResponse recorded response to a request
+type fixtureResponse struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// fixtureTransport http transport recording responses to, or replaying responses from, fixtures
+type fixtureTransport struct {
+	fixtures *Fixtures
+	base     http.RoundTripper
+}
+
+// NewRecordingFixtures creates fixtures recording responses to the supplied directory
+func NewRecordingFixtures(dir string) *Fixtures {
+	return &Fixtures{Dir: dir, served: make(map[string]int), entries: make(map[string][]fixtureResponse)}
+}
+
+// NewReplayFixtures creates fixtures replaying the responses recorded in the supplied directory
+func NewReplayFixtures(dir string) *Fixtures {
+	f := NewRecordingFixtures(dir)
+	f.Replay = true
+	return f
+}
+
+// Stats returns the number of requests recorded or replayed and, when replaying, the number of requests
+// without a recorded response
+func (f *Fixtures) Stats() (served int, missing int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, n := range f.served {
+		served += n
+	}
+	return served, f.misses
+}
+
+// wraps the supplied transport with recording or, when replaying, replaces it
+func (f *Fixtures) transport(base http.RoundTripper) http.RoundTripper {
+	return &fixtureTransport{fixtures: f, base: base}
+}
+
+// RoundTrip records the response to the request or answers it with the recorded response
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, req, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := t.fixtures.key(req, body)
+
+	if t.fixtures.Replay {
+		return t.fixtures.replay(key, req)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.fixtures.record(key, req, resp)
+}
+
+// answer the request with the next response recorded for the key
+func (f *Fixtures) replay(key string, req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	responses, found := f.entries[key]
+	if !found {
+		var err error
+		if responses, err = f.read(key); err != nil {
+			f.misses++
+			glog.V(2).Infof("No recorded response for %s %s: %s", req.Method, req.URL, err.Error())
+			return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+		}
+		f.entries[key] = responses
+	}
+
+	n := f.served[key]
+	f.served[key]++
+	if n >= len(responses) {
+		n = len(responses) - 1
+	}
+	glog.V(3).Infof("Replaying response %d for %s %s", n, req.Method, req.URL)
+	return responses[n].response(req), nil
+}
+
+// save the response under the key, returning a response with a readable body
+func (f *Fixtures) record(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	closeQuietly(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[key] = append(f.entries[key], fixtureResponse{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     scrubHeader(resp.Header),
+		Body:       redactBody(req, body),
+	})
+	f.served[key]++
+	glog.V(3).Infof("Recording response for %s %s", req.Method, req.URL)
+	if err = f.write(key, f.entries[key]); err != nil {
+		glog.Warningf("Problem writing fixture for %s %s: %s", req.Method, req.URL, err.Error())
+	}
+	return resp, nil
+}
+
+// copy of the response header without credentials
+func scrubHeader(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	scrubbed.Del("Authorization")
+	return scrubbed
+}
+
+// body of the response to record, the token of an installation access token response being redacted so live
+// credentials aren't written to the fixture directory
+func redactBody(req *http.Request, body []byte) string {
+	path := req.URL.Path
+	if !strings.Contains(path, "/app/installations/") || !strings.HasSuffix(path, "/access_tokens") {
+		return string(body)
+	}
+
+	var token map[string]interface{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		// the body can't be safely recorded when the token can't be found in it
+		return ""
+	}
+	if _, found := token["token"]; found {
+		token["token"] = redactedToken
+	}
+	redacted, err := json.Marshal(token)
+	if err != nil {
+		return ""
+	}
+	return string(redacted)
+}
+
+// builds the fixture key for the request, responses vary by method, url, requested media type and body
+func (f *Fixtures) key(req *http.Request, body []byte) string {
+	u := *req.URL
+	query := u.Query()
+	for _, p := range volatileParams {
+		query.Del(p)
+	}
+	u.RawQuery = query.Encode()
+
+	h := sha256.New()
+	h.Write([]byte(req.Method + "\n" + u.String() + "\n" + req.Header.Get("Accept") + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// builds the file name for the supplied fixture key
+func (f *Fixtures) fileName(key string) string {
+	return filepath.Join(f.Dir, key+".json")
+}
+
+// read the responses recorded for the key
+func (f *Fixtures) read(key string) ([]fixtureResponse, error) {
+	data, err := ioutil.ReadFile(f.fileName(key))
+	if err != nil {
+		return nil, err
+	}
+	var responses []fixtureResponse
+	if err = json.Unmarshal(data, &responses); err != nil {
+		return nil, err
+	}
+	if len(responses) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return responses, nil
+}
+
+// write the responses recorded for the key
+func (f *Fixtures) write(key string, responses []fixtureResponse) error {
+	data, err := json.MarshalIndent(responses, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(f.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.fileName(key), data, 0644)
+}
+
+// read the request body, returning the request to send in place of the supplied one when the body had to be
+// consumed to read it
+func requestBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer closeQuietly(rc)
+		body, err := ioutil.ReadAll(rc)
+		return body, req, err
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	closeQuietly(req.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, clone, nil
+}
+
+// build the response answering the request from the recording
+func (r fixtureResponse) response(req *http.Request) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(r.Body))),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
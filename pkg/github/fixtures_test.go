@@ -0,0 +1,206 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtures_RecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/api/v3/repos/testorg/testrepo":
+			_, err := w.Write([]byte(`{"id": 123, "name": "testrepo", "default_branch": "main"}`))
+			assert.NoError(t, err)
+		case "/api/v3/repos/testorg/testrepo/stats/contributors":
+			// statistics are computed on the first request
+			if calls < 3 {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			_, err := w.Write([]byte(`[{"total": 5, "author": {"login": "dev1"}}]`))
+			assert.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	recorder := NewRecordingFixtures(dir)
+	client, err := ClientFactory{}.NewGitHubClient(server.URL, "token", ClientOptions{Fixtures: recorder})
+	if !assert.NoError(t, err) {
+		return
+	}
+	repo, _, err := client.Repositories.Get(context.Background(), "testorg", "testrepo")
+	assert.NoError(t, err)
+	_, resp, _ := client.Repositories.ListContributorsStats(context.Background(), "testorg", "testrepo")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	stats, _, err := client.Repositories.ListContributorsStats(context.Background(), "testorg", "testrepo")
+	assert.NoError(t, err)
+	_, _, err = client.Repositories.Get(context.Background(), "testorg", "missing")
+	assert.Error(t, err)
+	server.Close()
+
+	served, missing := recorder.Stats()
+	assert.Equal(t, 4, served)
+	assert.Equal(t, 0, missing)
+
+	// replay with the server gone, answering repeated requests in the order recorded
+	replayer := NewReplayFixtures(dir)
+	client, err = ClientFactory{}.NewGitHubClient(server.URL, "", ClientOptions{Fixtures: replayer})
+	if !assert.NoError(t, err) {
+		return
+	}
+	replayedRepo, _, err := client.Repositories.Get(context.Background(), "testorg", "testrepo")
+	assert.NoError(t, err)
+	assert.Equal(t, repo, replayedRepo)
+
+	_, resp, _ = client.Repositories.ListContributorsStats(context.Background(), "testorg", "testrepo")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	for i := 0; i < 2; i++ {
+		replayedStats, _, err := client.Repositories.ListContributorsStats(context.Background(), "testorg", "testrepo")
+		assert.NoError(t, err)
+		assert.Equal(t, stats, replayedStats)
+	}
+
+	_, resp, err = client.Repositories.Get(context.Background(), "testorg", "missing")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, _, err = client.Repositories.Get(context.Background(), "testorg", "notrecorded")
+	assert.Error(t, err)
+
+	served, missing = replayer.Stats()
+	assert.Equal(t, 5, served)
+	assert.Equal(t, 1, missing)
+}
+
+func TestFixtures_RecordRedactsCredentials(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Authorization", "token live-secret")
+		if r.URL.Path == "/app/installations/42/access_tokens" {
+			w.WriteHeader(http.StatusCreated)
+			_, err := w.Write([]byte(`{"token": "ghs_live", "expires_at": "2021-11-01T12:00:00Z"}`))
+			assert.NoError(t, err)
+			return
+		}
+		_, err := w.Write([]byte(`{"id": 123}`))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	recorder := NewRecordingFixtures(dir)
+	client := &http.Client{Transport: recorder.transport(http.DefaultTransport)}
+	resp, err := client.Post(server.URL+"/app/installations/42/access_tokens", "application/json", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var token struct {
+		Token string `json:"token"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	closeQuietly(resp.Body)
+	resp, err = client.Get(server.URL + "/repos/testorg/testrepo")
+	if !assert.NoError(t, err) {
+		return
+	}
+	closeQuietly(resp.Body)
+
+	// the caller still receives the live token, only the recording is redacted
+	assert.Equal(t, "ghs_live", token.Token)
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "ghs_live")
+		assert.NotContains(t, string(data), "live-secret")
+	}
+}
+
+func TestFixtures_KeyIgnoresVolatileParams(t *testing.T) {
+	f := NewRecordingFixtures(t.TempDir())
+
+	req1, _ := http.NewRequest(http.MethodGet, "https://api.github.com/repos/o/r/actions/runs?created=%3E%3D2021-01-01T00%3A00%3A00Z&page=2", nil)
+	req2, _ := http.NewRequest(http.MethodGet, "https://api.github.com/repos/o/r/actions/runs?page=2&created=%3E%3D2021-02-01T00%3A00%3A00Z", nil)
+	req3, _ := http.NewRequest(http.MethodGet, "https://api.github.com/repos/o/r/actions/runs?page=3", nil)
+
+	assert.Equal(t, f.key(req1, nil), f.key(req2, nil))
+	assert.NotEqual(t, f.key(req1, nil), f.key(req3, nil))
+}
+
+func TestFixtures_KeyIncludesBody(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{"received": body["query"]}))
+	}))
+
+	post := func(client *github.Client, query string) string {
+		req, err := client.NewRequest(http.MethodPost, "graphql", map[string]string{"query": query})
+		assert.NoError(t, err)
+		result := map[string]string{}
+		_, err = client.Do(context.Background(), req, &result)
+		assert.NoError(t, err)
+		return result["received"]
+	}
+
+	client, err := ClientFactory{}.NewGitHubClient(server.URL, "token", ClientOptions{Fixtures: NewRecordingFixtures(dir)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "query { a }", post(client, "query { a }"))
+	assert.Equal(t, "query { b }", post(client, "query { b }"))
+	server.Close()
+
+	client, err = ClientFactory{}.NewGitHubClient(server.URL, "", ClientOptions{Fixtures: NewReplayFixtures(dir)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "query { b }", post(client, "query { b }"))
+	assert.Equal(t, "query { a }", post(client, "query { a }"))
+}
+
+func TestFixtures_ReplayExhaustedRateLimit(t *testing.T) {
+	dir := t.TempDir()
+	reset := time.Now().Add(-time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset))
+		_, err := w.Write([]byte(`{"id": 1}`))
+		assert.NoError(t, err)
+	}))
+	client, err := ClientFactory{}.NewGitHubClient(server.URL, "token", ClientOptions{Fixtures: NewRecordingFixtures(dir)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, _, err = client.Repositories.Get(context.Background(), "testorg", "testrepo")
+	assert.NoError(t, err)
+	server.Close()
+
+	client, err = ClientFactory{}.NewGitHubClient(server.URL, "", ClientOptions{Fixtures: NewReplayFixtures(dir)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		repo, _, err := client.Repositories.Get(ctx, "testorg", "testrepo")
+		assert.NoError(t, err)
+		assert.Equal(t, github.Int64(1), repo.ID)
+	}
+}
@@ -2,6 +2,7 @@ package github
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/google/go-github/v39/github"
 	"golang.org/x/oauth2"
@@ -9,17 +10,48 @@ import (
 
 // ClientCreator interface for representing github client creation functions
 type ClientCreator interface {
-	NewGitHubClient(baseURL string, token string) (*github.Client, error)
+	NewGitHubClient(baseURL string, token string, opts ClientOptions) (*github.Client, error)
+}
+
+// ClientOptions optional behavior layered onto the GitHub client
+type ClientOptions struct {
+	// Cache used to send conditional requests, disabled when nil
+	Cache *ResponseCache
+	// App credentials to authenticate as a GitHub App installation in place of the token
+	App *AppCredentials
+	// Fixtures records responses to, or replays responses from, a directory, disabled when nil
+	Fixtures *Fixtures
 }
 
 // ClientFactory factory implementation for ClientCreator interface
 type ClientFactory struct {
 }
 
-// NewGitHubClient creates a client to access the GitHub API
-func (ClientFactory) NewGitHubClient(baseURL string, token string) (*github.Client, error) {
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+// NewGitHubClient creates a client to access the GitHub API.  The client tracks the rate limit
+// budget reported by GitHub, pausing until the reset when it runs low and retrying rate limited calls.
+func (ClientFactory) NewGitHubClient(baseURL string, token string, opts ClientOptions) (*github.Client, error) {
+	base := http.DefaultTransport
+	if opts.Fixtures != nil {
+		base = opts.Fixtures.transport(base)
+	}
+	var transport http.RoundTripper = newRateLimitTransport(base)
+
+	var ts oauth2.TokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	identity := tokenIdentity(token)
+	if opts.App != nil {
+		src, installationID, err := newInstallationTokenSource(baseURL, transport, *opts.App)
+		if err != nil {
+			return nil, err
+		}
+		ts = src
+		identity = installationIdentity(installationID)
+	}
+
+	if opts.Cache != nil {
+		transport = opts.Cache.transport(transport, identity)
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
 	tc := oauth2.NewClient(ctx, ts)
 
 	return github.NewEnterpriseClient(baseURL, baseURL, tc)
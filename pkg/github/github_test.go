@@ -7,7 +7,13 @@ import (
 )
 
 func Test_NewGitHubClient(t *testing.T) {
-	client, err := ClientFactory{}.NewGitHubClient("baseurlval", "authtokenval")
+	client, err := ClientFactory{}.NewGitHubClient("baseurlval", "authtokenval", ClientOptions{})
+	assert.NotNil(t, client)
+	assert.NoError(t, err)
+}
+
+func Test_NewGitHubClient_WithCache(t *testing.T) {
+	client, err := ClientFactory{}.NewGitHubClient("baseurlval", "authtokenval", ClientOptions{Cache: NewResponseCache(t.TempDir())})
 	assert.NotNil(t, client)
 	assert.NoError(t, err)
 }
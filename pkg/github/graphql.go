@@ -0,0 +1,912 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// graphQLRepositoryQuery batches the repository detail and the first page of each connection into a
+// single call.  Connections still having pages are requested together on later calls with the detail
+// and finished connections skipped using the include variables.  The connections nested in pull requests
+// and releases aren't paged, those with more nodes are completed using the REST API.
+const graphQLRepositoryQuery = `query($owner: String!, $name: String!, $detail: Boolean!,
+  $branches: Boolean!, $branchesAfter: String,
+  $releases: Boolean!, $releasesAfter: String,
+  $pullRequests: Boolean!, $pullRequestsAfter: String, $pullRequestsOrder: IssueOrderField!,
+  $languages: Boolean!, $languagesAfter: String,
+  $topics: Boolean!, $topicsAfter: String) {
+  repository(owner: $owner, name: $name) {
+    ... on Repository @include(if: $detail) {
+      databaseId
+      name
+      nameWithOwner
+      description
+      url
+      isPrivate
+      isFork
+      isArchived
+      createdAt
+      updatedAt
+      pushedAt
+      defaultBranchRef {
+        name
+        branchProtectionRule {
+          requiresApprovingReviews requiredApprovingReviewCount dismissesStaleReviews requiresCodeOwnerReviews
+          requiresStatusChecks requiresStrictStatusChecks requiredStatusCheckContexts
+          isAdminEnforced requiresLinearHistory allowsForcePushes allowsDeletions
+        }
+      }
+      squashMergeAllowed
+      rebaseMergeAllowed
+      mergeCommitAllowed
+    }
+    refs(refPrefix: "refs/heads/", first: 100, after: $branchesAfter) @include(if: $branches) {
+      nodes { name target { oid ... on Commit { committedDate } } branchProtectionRule { id } }
+      pageInfo { hasNextPage endCursor }
+    }
+    releases(first: 100, after: $releasesAfter, orderBy: {field: CREATED_AT, direction: DESC}) @include(if: $releases) {
+      nodes {
+        databaseId name tagName isDraft isPrerelease createdAt publishedAt
+        releaseAssets(first: 100) { nodes { name downloadCount } pageInfo { hasNextPage } }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+    pullRequests(first: 100, after: $pullRequestsAfter, orderBy: {field: $pullRequestsOrder, direction: DESC}) @include(if: $pullRequests) {
+      nodes {
+        databaseId number title state createdAt updatedAt closedAt mergedAt author { login }
+        reviews(first: 100) { nodes { databaseId state submittedAt commit { oid } author { login } } pageInfo { hasNextPage } }
+        reviewRequests(first: 50) { nodes { requestedReviewer { ... on User { login } ... on Team { slug } } } pageInfo { hasNextPage } }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+    languages(first: 100, after: $languagesAfter, orderBy: {field: SIZE, direction: DESC}) @include(if: $languages) {
+      edges { size node { name } }
+      pageInfo { hasNextPage endCursor }
+    }
+    repositoryTopics(first: 100, after: $topicsAfter) @include(if: $topics) {
+      nodes { topic { name } }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+// connections available on the repository query, named by their include variable
+const (
+	graphQLBranches     = "branches"
+	graphQLReleases     = "releases"
+	graphQLPullRequests = "pullRequests"
+	graphQLLanguages    = "languages"
+	graphQLTopics       = "topics"
+)
+
+// GraphQLDataCollector used to collect data from git hub repositories using the GraphQL (v4) API,
+// batching the repository contents into far fewer calls than the REST collector
+type GraphQLDataCollector struct {
+	GitHubClient *gogithub.Client
+	PageLimits   scm.PageLimits
+	StatsRetry   StatsRetry
+	// UserOwners set when repository owners are user accounts, otherwise detected when not an organization
+	UserOwners bool
+	// SearchDiscovery set to discover changed repositories using the search API, falling back to listing the
+	// repositories when the search fails
+	SearchDiscovery bool
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data struct {
+		Repository *graphQLRepository `json:"repository"`
+	} `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+type graphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphQLBranchProtectionRule struct {
+	RequiresApprovingReviews     bool     `json:"requiresApprovingReviews"`
+	RequiredApprovingReviewCount int      `json:"requiredApprovingReviewCount"`
+	DismissesStaleReviews        bool     `json:"dismissesStaleReviews"`
+	RequiresCodeOwnerReviews     bool     `json:"requiresCodeOwnerReviews"`
+	RequiresStatusChecks         bool     `json:"requiresStatusChecks"`
+	RequiresStrictStatusChecks   bool     `json:"requiresStrictStatusChecks"`
+	RequiredStatusCheckContexts  []string `json:"requiredStatusCheckContexts"`
+	IsAdminEnforced              bool     `json:"isAdminEnforced"`
+	RequiresLinearHistory        bool     `json:"requiresLinearHistory"`
+	AllowsForcePushes            bool     `json:"allowsForcePushes"`
+	AllowsDeletions              bool     `json:"allowsDeletions"`
+}
+
+type graphQLRepository struct {
+	DatabaseID       int64      `json:"databaseId"`
+	Name             string     `json:"name"`
+	NameWithOwner    string     `json:"nameWithOwner"`
+	Description      string     `json:"description"`
+	URL              string     `json:"url"`
+	IsPrivate        bool       `json:"isPrivate"`
+	IsFork           bool       `json:"isFork"`
+	IsArchived       bool       `json:"isArchived"`
+	CreatedAt        *time.Time `json:"createdAt"`
+	UpdatedAt        *time.Time `json:"updatedAt"`
+	PushedAt         *time.Time `json:"pushedAt"`
+	DefaultBranchRef *struct {
+		Name                 string                       `json:"name"`
+		BranchProtectionRule *graphQLBranchProtectionRule `json:"branchProtectionRule"`
+	} `json:"defaultBranchRef"`
+	SquashMergeAllowed bool                       `json:"squashMergeAllowed"`
+	RebaseMergeAllowed bool                       `json:"rebaseMergeAllowed"`
+	MergeCommitAllowed bool                       `json:"mergeCommitAllowed"`
+	Refs               *graphQLBranchConnection   `json:"refs"`
+	Releases           *graphQLReleaseConnection  `json:"releases"`
+	PullRequests       *graphQLPullConnection     `json:"pullRequests"`
+	Languages          *graphQLLanguageConnection `json:"languages"`
+	RepositoryTopics   *graphQLTopicConnection    `json:"repositoryTopics"`
+
+	// nested connections completed using the REST API, replacing the nodes of the query
+	restReviews   map[int][]*gogithub.PullRequestReview
+	restReviewers map[int]*gogithub.Reviewers
+	restAssets    map[int64][]*gogithub.ReleaseAsset
+}
+
+type graphQLBranchConnection struct {
+	Nodes []struct {
+		Name   string `json:"name"`
+		Target struct {
+			Oid           string     `json:"oid"`
+			CommittedDate *time.Time `json:"committedDate"`
+		} `json:"target"`
+		BranchProtectionRule *struct {
+			ID string `json:"id"`
+		} `json:"branchProtectionRule"`
+	} `json:"nodes"`
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+}
+
+type graphQLReleaseConnection struct {
+	Nodes []struct {
+		DatabaseID   int64      `json:"databaseId"`
+		Name         string     `json:"name"`
+		TagName      string     `json:"tagName"`
+		IsDraft      bool       `json:"isDraft"`
+		IsPrerelease bool       `json:"isPrerelease"`
+		CreatedAt    *time.Time `json:"createdAt"`
+		PublishedAt  *time.Time `json:"publishedAt"`
+		Assets       struct {
+			Nodes []struct {
+				Name          string `json:"name"`
+				DownloadCount int    `json:"downloadCount"`
+			} `json:"nodes"`
+			PageInfo graphQLPageInfo `json:"pageInfo"`
+		} `json:"releaseAssets"`
+	} `json:"nodes"`
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+}
+
+type graphQLPullConnection struct {
+	Nodes []struct {
+		DatabaseID int64      `json:"databaseId"`
+		Number     int        `json:"number"`
+		Title      string     `json:"title"`
+		State      string     `json:"state"`
+		CreatedAt  *time.Time `json:"createdAt"`
+		UpdatedAt  *time.Time `json:"updatedAt"`
+		ClosedAt   *time.Time `json:"closedAt"`
+		MergedAt   *time.Time `json:"mergedAt"`
+		Author     struct {
+			Login string `json:"login"`
+		} `json:"author"`
+		Reviews struct {
+			Nodes []struct {
+				DatabaseID  int64      `json:"databaseId"`
+				State       string     `json:"state"`
+				SubmittedAt *time.Time `json:"submittedAt"`
+				Commit      struct {
+					Oid string `json:"oid"`
+				} `json:"commit"`
+				Author struct {
+					Login string `json:"login"`
+				} `json:"author"`
+			} `json:"nodes"`
+			PageInfo graphQLPageInfo `json:"pageInfo"`
+		} `json:"reviews"`
+		ReviewRequests struct {
+			Nodes []struct {
+				RequestedReviewer struct {
+					Login string `json:"login"`
+					Slug  string `json:"slug"`
+				} `json:"requestedReviewer"`
+			} `json:"nodes"`
+			PageInfo graphQLPageInfo `json:"pageInfo"`
+		} `json:"reviewRequests"`
+	} `json:"nodes"`
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+}
+
+type graphQLLanguageConnection struct {
+	Edges []struct {
+		Size int `json:"size"`
+		Node struct {
+			Name string `json:"name"`
+		} `json:"node"`
+	} `json:"edges"`
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+}
+
+type graphQLTopicConnection struct {
+	Nodes []struct {
+		Topic struct {
+			Name string `json:"name"`
+		} `json:"topic"`
+	} `json:"nodes"`
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+}
+
+// ListOrganizations retrieves the logins of the organizations the authenticated user belongs to
+func (m GraphQLDataCollector) ListOrganizations(ctx context.Context) ([]string, error) {
+	return m.rest().ListOrganizations(ctx)
+}
+
+// ListRepositories retrieves the set of repositories for an organization
+func (m GraphQLDataCollector) ListRepositories(ctx context.Context, org string, changedAfter *time.Time) ([]Repository, error) {
+	// listing already returns 100 repositories per call, the REST listing is used as is
+	return m.rest().ListRepositories(ctx, org, changedAfter)
+}
+
+// GetRepository retrieves the repository information by organization/name
+func (m GraphQLDataCollector) GetRepository(ctx context.Context, org string, name string, opts scm.CollectOptions) (*Repository, error) {
+	// contributor statistics, workflow runs, security alerts, deployments, issues and hygiene files
+	// are collected from the REST API while the GraphQL queries run
+	// sections from the GraphQL queries arrive with the base repository data so are required, the sections
+	// collected from the REST API are recorded against the repository when they fail
+	callerCtx := ctx
+	grp, ctx := errgroup.WithContext(ctx)
+	failures := &scm.SectionFailures{Org: org, Name: name}
+
+	var r *graphQLRepository
+	var truncated map[string]bool
+	grp.Go(func() error {
+		var err error
+		r, truncated, err = m.collect(ctx, org, name, true, opts.PullRequestsSince,
+			graphQLBranches, graphQLReleases, graphQLPullRequests, graphQLLanguages, graphQLTopics)
+		if err != nil {
+			return err
+		}
+		return m.completeNested(ctx, org, name, r)
+	})
+
+	var contributors []*gogithub.ContributorStats
+	var contributorsPending bool
+	grp.Go(func() error {
+		c, pending, err := m.rest().GetContributorStats(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionContributors, err)
+			return nil
+		}
+		contributors = c
+		contributorsPending = pending
+		return nil
+	})
+
+	var workflows []*gogithub.Workflow
+	var workflowRuns []*gogithub.WorkflowRun
+	var workflowRunsTruncated bool
+	grp.Go(func() error {
+		var err error
+		if workflows, workflowRuns, workflowRunsTruncated, err = m.rest().collectWorkflows(ctx, org, name); err != nil {
+			failures.Fail(scm.SectionWorkflows, err)
+		}
+		return nil
+	})
+
+	var security SecurityAlerts
+	var securityTruncated bool
+	grp.Go(func() error {
+		var err error
+		if security, securityTruncated, err = m.rest().collectSecurityAlerts(ctx, org, name); err != nil {
+			failures.Fail(scm.SectionSecurity, err)
+		}
+		return nil
+	})
+
+	var deployments []*gogithub.Deployment
+	var deploymentStatuses map[int64][]*gogithub.DeploymentStatus
+	var deploymentsTruncated bool
+	grp.Go(func() error {
+		var err error
+		if deployments, deploymentStatuses, deploymentsTruncated, err = m.rest().collectDeployments(ctx, org, name, opts.DeploymentsSince); err != nil {
+			failures.Fail(scm.SectionDeployments, err)
+		}
+		return nil
+	})
+
+	var issues []*gogithub.Issue
+	var issueComments map[int][]*gogithub.IssueComment
+	var issuesTruncated bool
+	grp.Go(func() error {
+		var err error
+		if issues, issueComments, issuesTruncated, err = m.rest().collectIssues(ctx, org, name); err != nil {
+			failures.Fail(scm.SectionIssues, err)
+		}
+		return nil
+	})
+
+	var hygieneFiles map[string]bool
+	grp.Go(func() error {
+		var err error
+		if hygieneFiles, err = m.rest().collectHygieneFiles(ctx, org, name, opts.HygieneFiles); err != nil {
+			failures.Fail(scm.SectionHygiene, err)
+		}
+		return nil
+	})
+
+	if err := grp.Wait(); err != nil {
+		return nil, err
+	}
+
+	// sections fail when the caller gives up, report that rather than a repository missing its sections
+	if err := callerCtx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the branch activity is compared with the default branch so is collected once both are known, using the
+	// caller context as the group context is cancelled once the group finishes
+	ghRepo := r.detail()
+	branches := r.branches()
+	branchActivity, err := m.rest().collectBranchActivity(callerCtx, org, name, ghRepo.GetDefaultBranch(), branches)
+	if err != nil {
+		failures.Fail(scm.SectionBranchActivity, err)
+	}
+
+	// Build repository output
+	return &Repository{
+		ID:                  r.DatabaseID,
+		Org:                 org,
+		Name:                name,
+		Topics:              r.topics(),
+		Changed:             extractLastChangeTS(ghRepo),
+		Detail:              ghRepo,
+		Branches:            branches,
+		BranchActivity:      branchActivity,
+		Releases:            r.releases(),
+		PullRequests:        r.pullRequests(),
+		Reviews:             r.reviews(),
+		Languages:           r.languages(),
+		Contributors:        contributors,
+		ContributorsPending: contributorsPending,
+		Workflows:           workflows,
+		WorkflowRuns:        workflowRuns,
+		Security:            security,
+		Deployments:         deployments,
+		DeploymentStatuses:  deploymentStatuses,
+		Issues:              issues,
+		IssueComments:       issueComments,
+		HygieneFiles:        hygieneFiles,
+		Protection:          r.protection(),
+		Truncated: scm.Truncated{
+			Branches:       truncated[graphQLBranches],
+			Releases:       truncated[graphQLReleases],
+			PullRequests:   truncated[graphQLPullRequests],
+			WorkflowRuns:   workflowRunsTruncated,
+			Deployments:    deploymentsTruncated,
+			Issues:         issuesTruncated,
+			SecurityAlerts: securityTruncated,
+		},
+		PullRequestsSince: opts.PullRequestsSince,
+		SectionErrors:     failures.Failed(),
+	}, nil
+}
+
+// GetBranches retrieves branch information by organization/repo, flagging when truncated by the page limit
+func (m GraphQLDataCollector) GetBranches(ctx context.Context, org string, repo string) ([]*gogithub.Branch, bool, error) {
+	r, truncated, err := m.collect(ctx, org, repo, false, nil, graphQLBranches)
+	if err != nil {
+		return nil, false, err
+	}
+	return r.branches(), truncated[graphQLBranches], nil
+}
+
+// GetReleases retrieves release information by organization/repo, flagging when truncated by the page limit
+func (m GraphQLDataCollector) GetReleases(ctx context.Context, org string, repo string) ([]*gogithub.RepositoryRelease, bool, error) {
+	r, truncated, err := m.collect(ctx, org, repo, false, nil, graphQLReleases)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = m.completeNested(ctx, org, repo, r); err != nil {
+		return nil, false, err
+	}
+	return r.releases(), truncated[graphQLReleases], nil
+}
+
+// rest collector sharing the client for data not covered by the GraphQL queries
+func (m GraphQLDataCollector) rest() RepositoryDataCollector {
+	return RepositoryDataCollector{GitHubClient: m.GitHubClient, PageLimits: m.PageLimits, StatsRetry: m.StatsRetry, UserOwners: m.UserOwners, SearchDiscovery: m.SearchDiscovery}
+}
+
+// page limit configured for the connection
+func (m GraphQLDataCollector) pageLimit(connection string) int {
+	switch connection {
+	case graphQLBranches:
+		return m.PageLimits.Branches
+	case graphQLReleases:
+		return m.PageLimits.Releases
+	case graphQLPullRequests:
+		return m.PageLimits.PullRequests
+	default:
+		return 0
+	}
+}
+
+// query the repository for the supplied connections, paging through each using its cursor until
+// all connections are finished or hit their page limit, returning the connections truncated by the
+// limit.  The detail is only requested on the first call.  When a since time is supplied, only pull
+// requests updated since then are collected.
+func (m GraphQLDataCollector) collect(ctx context.Context, org string, name string, detail bool, since *time.Time, connections ...string) (*graphQLRepository, map[string]bool, error) {
+	vars := map[string]interface{}{
+		"owner":             org,
+		"name":              name,
+		"detail":            detail,
+		graphQLBranches:     false,
+		graphQLReleases:     false,
+		graphQLPullRequests: false,
+		graphQLLanguages:    false,
+		graphQLTopics:       false,
+		"pullRequestsOrder": "CREATED_AT",
+	}
+	for _, c := range connections {
+		vars[c] = true
+	}
+
+	// sort by most recently updated when collecting changes so paging can stop at the first older request
+	if since != nil {
+		glog.V(2).Infof("Collecting pull requests for %s/%s updated since %s", org, name, since)
+		vars["pullRequestsOrder"] = "UPDATED_AT"
+	}
+
+	// process all pages until finished
+	var loopCnt = 0
+	var result *graphQLRepository
+	truncated := make(map[string]bool)
+	for {
+		// stop paging through connections once their page limit is hit
+		loopCnt++
+		for _, c := range pending(vars, connections) {
+			if pageLimitReached(m.pageLimit(c), loopCnt) {
+				glog.Warningf("Repository has more than %d pages of %s: %s/%s", loopCnt-1, c, org, name)
+				vars[c] = false
+				truncated[c] = true
+			}
+		}
+
+		names := pending(vars, connections)
+		if len(names) == 0 {
+			break
+		}
+
+		glog.V(2).Infof("Collecting %s for %s/%s using GraphQL, page number = %d", strings.Join(names, ", "), org, name, loopCnt)
+		page, err := m.query(ctx, org, name, vars)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if result == nil {
+			result = page
+		} else {
+			result.appendPage(page)
+		}
+
+		infos := page.pageInfos()
+		for _, c := range names {
+			vars[c] = infos[c].HasNextPage
+			vars[c+"After"] = infos[c].EndCursor
+		}
+		if since != nil && page.PullRequests != nil && page.PullRequests.updatedBefore(*since) {
+			vars[graphQLPullRequests] = false
+		}
+		vars["detail"] = false
+	}
+
+	if since != nil && result.PullRequests != nil {
+		result.PullRequests.removeUpdatedBefore(*since)
+	}
+	return result, truncated, nil
+}
+
+// complete the connections nested in the pull requests and releases having more nodes than the query returns
+// using the REST API, only requesting those of the pull requests and releases that were cut off
+func (m GraphQLDataCollector) completeNested(ctx context.Context, org string, name string, r *graphQLRepository) error {
+	if r.PullRequests != nil {
+		var cutOff []*gogithub.PullRequest
+		for _, n := range r.PullRequests.Nodes {
+			if n.Reviews.PageInfo.HasNextPage {
+				glog.V(2).Infof("Collecting reviews of %s/%s#%d using REST, more than a GraphQL page", org, name, n.Number)
+				cutOff = append(cutOff, &gogithub.PullRequest{Number: gogithub.Int(n.Number)})
+			}
+			if n.ReviewRequests.PageInfo.HasNextPage {
+				glog.V(2).Infof("Collecting requested reviewers of %s/%s#%d using REST, more than a GraphQL page", org, name, n.Number)
+				reviewers, _, err := m.GitHubClient.PullRequests.ListReviewers(ctx, org, name, n.Number, &gogithub.ListOptions{PerPage: 100})
+				if err != nil {
+					return err
+				}
+				if r.restReviewers == nil {
+					r.restReviewers = make(map[int]*gogithub.Reviewers)
+				}
+				r.restReviewers[n.Number] = reviewers
+			}
+		}
+		if len(cutOff) > 0 {
+			reviews, err := m.rest().GetReviews(ctx, org, name, cutOff)
+			if err != nil {
+				return err
+			}
+			r.restReviews = reviews
+		}
+	}
+
+	if r.Releases != nil {
+		for _, n := range r.Releases.Nodes {
+			if !n.Assets.PageInfo.HasNextPage {
+				continue
+			}
+			glog.V(2).Infof("Collecting assets of release %s for %s/%s using REST, more than a GraphQL page", n.TagName, org, name)
+			assets, err := m.listReleaseAssets(ctx, org, name, n.DatabaseID)
+			if err != nil {
+				return err
+			}
+			if r.restAssets == nil {
+				r.restAssets = make(map[int64][]*gogithub.ReleaseAsset)
+			}
+			r.restAssets[n.DatabaseID] = assets
+		}
+	}
+	return nil
+}
+
+// page through all the assets of the release using the REST API
+func (m GraphQLDataCollector) listReleaseAssets(ctx context.Context, org string, name string, id int64) ([]*gogithub.ReleaseAsset, error) {
+	var allAssets []*gogithub.ReleaseAsset
+	opt := &gogithub.ListOptions{PerPage: 100}
+	for {
+		assets, resp, err := m.GitHubClient.Repositories.ListReleaseAssets(ctx, org, name, id, opt)
+		if err != nil {
+			return nil, err
+		}
+		allAssets = append(allAssets, assets...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allAssets, nil
+}
+
+// execute the repository query using the supplied variables
+func (m GraphQLDataCollector) query(ctx context.Context, org string, name string, vars map[string]interface{}) (*graphQLRepository, error) {
+	req, err := m.GitHubClient.NewRequest(http.MethodPost, graphQLEndpoint(m.GitHubClient), graphQLRequest{
+		Query:     graphQLRepositoryQuery,
+		Variables: vars,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp graphQLResponse
+	if _, err = m.GitHubClient.Do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Errors) > 0 {
+		var messages []string
+		for _, e := range resp.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("GraphQL query for %s/%s failed: %s", org, name, strings.Join(messages, "; "))
+	}
+	if resp.Data.Repository == nil {
+		return nil, fmt.Errorf("repository %s/%s not found", org, name)
+	}
+	return resp.Data.Repository, nil
+}
+
+// GraphQL endpoint relative to the client base url, GitHub Enterprise serves the API
+// under /api/v3/ and GraphQL under /api/graphql
+func graphQLEndpoint(c *gogithub.Client) string {
+	if strings.HasSuffix(c.BaseURL.Path, "/api/v3/") {
+		return "../graphql"
+	}
+	return "graphql"
+}
+
+// connections still being paged through
+func pending(vars map[string]interface{}, connections []string) []string {
+	var names []string
+	for _, c := range connections {
+		if vars[c] == true {
+			names = append(names, c)
+		}
+	}
+	return names
+}
+
+// page info for each connection returned, keyed by the connection include variable
+func (r *graphQLRepository) pageInfos() map[string]graphQLPageInfo {
+	infos := make(map[string]graphQLPageInfo)
+	if r.Refs != nil {
+		infos[graphQLBranches] = r.Refs.PageInfo
+	}
+	if r.Releases != nil {
+		infos[graphQLReleases] = r.Releases.PageInfo
+	}
+	if r.PullRequests != nil {
+		infos[graphQLPullRequests] = r.PullRequests.PageInfo
+	}
+	if r.Languages != nil {
+		infos[graphQLLanguages] = r.Languages.PageInfo
+	}
+	if r.RepositoryTopics != nil {
+		infos[graphQLTopics] = r.RepositoryTopics.PageInfo
+	}
+	return infos
+}
+
+// append the connection nodes from a later page
+func (r *graphQLRepository) appendPage(page *graphQLRepository) {
+	if r.Refs != nil && page.Refs != nil {
+		r.Refs.Nodes = append(r.Refs.Nodes, page.Refs.Nodes...)
+	}
+	if r.Releases != nil && page.Releases != nil {
+		r.Releases.Nodes = append(r.Releases.Nodes, page.Releases.Nodes...)
+	}
+	if r.PullRequests != nil && page.PullRequests != nil {
+		r.PullRequests.Nodes = append(r.PullRequests.Nodes, page.PullRequests.Nodes...)
+	}
+	if r.Languages != nil && page.Languages != nil {
+		r.Languages.Edges = append(r.Languages.Edges, page.Languages.Edges...)
+	}
+	if r.RepositoryTopics != nil && page.RepositoryTopics != nil {
+		r.RepositoryTopics.Nodes = append(r.RepositoryTopics.Nodes, page.RepositoryTopics.Nodes...)
+	}
+}
+
+// determine if the page reached pull requests updated before the supplied time
+func (c *graphQLPullConnection) updatedBefore(since time.Time) bool {
+	for _, n := range c.Nodes {
+		if n.UpdatedAt != nil && n.UpdatedAt.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// remove the pull requests updated before the supplied time
+func (c *graphQLPullConnection) removeUpdatedBefore(since time.Time) {
+	nodes := c.Nodes[:0]
+	for _, n := range c.Nodes {
+		if n.UpdatedAt == nil || !n.UpdatedAt.Before(since) {
+			nodes = append(nodes, n)
+		}
+	}
+	c.Nodes = nodes
+}
+
+// map the repository detail to the REST representation
+func (r *graphQLRepository) detail() *gogithub.Repository {
+	repo := &gogithub.Repository{
+		ID:               gogithub.Int64(r.DatabaseID),
+		Name:             gogithub.String(r.Name),
+		FullName:         gogithub.String(r.NameWithOwner),
+		Description:      gogithub.String(r.Description),
+		HTMLURL:          gogithub.String(r.URL),
+		Private:          gogithub.Bool(r.IsPrivate),
+		Fork:             gogithub.Bool(r.IsFork),
+		Archived:         gogithub.Bool(r.IsArchived),
+		CreatedAt:        timestamp(r.CreatedAt),
+		UpdatedAt:        timestamp(r.UpdatedAt),
+		PushedAt:         timestamp(r.PushedAt),
+		AllowSquashMerge: gogithub.Bool(r.SquashMergeAllowed),
+		AllowRebaseMerge: gogithub.Bool(r.RebaseMergeAllowed),
+		AllowMergeCommit: gogithub.Bool(r.MergeCommitAllowed),
+		Topics:           r.topics(),
+	}
+	if r.DefaultBranchRef != nil {
+		repo.DefaultBranch = gogithub.String(r.DefaultBranchRef.Name)
+	}
+	return repo
+}
+
+// map the default branch protection rule to the REST representation, the rule is null when the branch isn't
+// protected or can't be read
+func (r *graphQLRepository) protection() BranchProtection {
+	if r.DefaultBranchRef == nil {
+		return BranchProtection{Status: scm.FeatureUnknown}
+	}
+	rule := r.DefaultBranchRef.BranchProtectionRule
+	if rule == nil {
+		return BranchProtection{Status: scm.FeatureNotEnabled}
+	}
+
+	settings := &gogithub.Protection{
+		EnforceAdmins:        &gogithub.AdminEnforcement{Enabled: rule.IsAdminEnforced},
+		RequireLinearHistory: &gogithub.RequireLinearHistory{Enabled: rule.RequiresLinearHistory},
+		AllowForcePushes:     &gogithub.AllowForcePushes{Enabled: rule.AllowsForcePushes},
+		AllowDeletions:       &gogithub.AllowDeletions{Enabled: rule.AllowsDeletions},
+	}
+	if rule.RequiresApprovingReviews {
+		settings.RequiredPullRequestReviews = &gogithub.PullRequestReviewsEnforcement{
+			RequiredApprovingReviewCount: rule.RequiredApprovingReviewCount,
+			DismissStaleReviews:          rule.DismissesStaleReviews,
+			RequireCodeOwnerReviews:      rule.RequiresCodeOwnerReviews,
+		}
+	}
+	if rule.RequiresStatusChecks {
+		settings.RequiredStatusChecks = &gogithub.RequiredStatusChecks{
+			Strict:   rule.RequiresStrictStatusChecks,
+			Contexts: rule.RequiredStatusCheckContexts,
+		}
+	}
+	return BranchProtection{Status: scm.FeatureEnabled, Settings: settings}
+}
+
+// map the branches to the REST representation
+func (r *graphQLRepository) branches() []*gogithub.Branch {
+	if r.Refs == nil {
+		return nil
+	}
+	var branches []*gogithub.Branch
+	for _, n := range r.Refs.Nodes {
+		branches = append(branches, &gogithub.Branch{
+			Name: gogithub.String(n.Name),
+			Commit: &gogithub.RepositoryCommit{
+				SHA:    gogithub.String(n.Target.Oid),
+				Commit: &gogithub.Commit{Committer: &gogithub.CommitAuthor{Date: n.Target.CommittedDate}},
+			},
+			Protected: gogithub.Bool(n.BranchProtectionRule != nil),
+		})
+	}
+	return branches
+}
+
+// map the releases to the REST representation
+func (r *graphQLRepository) releases() []*gogithub.RepositoryRelease {
+	if r.Releases == nil {
+		return nil
+	}
+	var releases []*gogithub.RepositoryRelease
+	for _, n := range r.Releases.Nodes {
+		assets, completed := r.restAssets[n.DatabaseID]
+		if !completed {
+			for _, a := range n.Assets.Nodes {
+				assets = append(assets, &gogithub.ReleaseAsset{Name: gogithub.String(a.Name), DownloadCount: gogithub.Int(a.DownloadCount)})
+			}
+		}
+		releases = append(releases, &gogithub.RepositoryRelease{
+			ID:          gogithub.Int64(n.DatabaseID),
+			Name:        gogithub.String(n.Name),
+			TagName:     gogithub.String(n.TagName),
+			Draft:       gogithub.Bool(n.IsDraft),
+			Prerelease:  gogithub.Bool(n.IsPrerelease),
+			CreatedAt:   timestamp(n.CreatedAt),
+			PublishedAt: timestamp(n.PublishedAt),
+			Assets:      assets,
+		})
+	}
+	return releases
+}
+
+// map the pull requests to the REST representation, REST only knows open and closed
+// states with merged pull requests being closed
+func (r *graphQLRepository) pullRequests() []*gogithub.PullRequest {
+	if r.PullRequests == nil {
+		return nil
+	}
+	var pullRequests []*gogithub.PullRequest
+	for _, n := range r.PullRequests.Nodes {
+		state := "closed"
+		if n.State == "OPEN" {
+			state = "open"
+		}
+		pr := &gogithub.PullRequest{
+			ID:        gogithub.Int64(n.DatabaseID),
+			Number:    gogithub.Int(n.Number),
+			Title:     gogithub.String(n.Title),
+			State:     gogithub.String(state),
+			Merged:    gogithub.Bool(n.State == "MERGED"),
+			CreatedAt: n.CreatedAt,
+			UpdatedAt: n.UpdatedAt,
+			ClosedAt:  n.ClosedAt,
+			MergedAt:  n.MergedAt,
+			User:      &gogithub.User{Login: gogithub.String(n.Author.Login)},
+		}
+		if reviewers, ok := r.restReviewers[n.Number]; ok {
+			pr.RequestedReviewers = reviewers.Users
+			pr.RequestedTeams = reviewers.Teams
+			pullRequests = append(pullRequests, pr)
+			continue
+		}
+		for _, rr := range n.ReviewRequests.Nodes {
+			if rr.RequestedReviewer.Slug != "" {
+				pr.RequestedTeams = append(pr.RequestedTeams, &gogithub.Team{Slug: gogithub.String(rr.RequestedReviewer.Slug)})
+			} else {
+				pr.RequestedReviewers = append(pr.RequestedReviewers, &gogithub.User{Login: gogithub.String(rr.RequestedReviewer.Login)})
+			}
+		}
+		pullRequests = append(pullRequests, pr)
+	}
+	return pullRequests
+}
+
+// map the reviews submitted on each pull request by pull request number
+func (r *graphQLRepository) reviews() map[int][]*gogithub.PullRequestReview {
+	if r.PullRequests == nil {
+		return nil
+	}
+	reviews := make(map[int][]*gogithub.PullRequestReview)
+	for _, n := range r.PullRequests.Nodes {
+		if rest, ok := r.restReviews[n.Number]; ok {
+			reviews[n.Number] = rest
+			continue
+		}
+		for _, rv := range n.Reviews.Nodes {
+			reviews[n.Number] = append(reviews[n.Number], &gogithub.PullRequestReview{
+				ID:          gogithub.Int64(rv.DatabaseID),
+				User:        &gogithub.User{Login: gogithub.String(rv.Author.Login)},
+				State:       gogithub.String(rv.State),
+				SubmittedAt: rv.SubmittedAt,
+				CommitID:    gogithub.String(rv.Commit.Oid),
+			})
+		}
+	}
+	return reviews
+}
+
+// map the language sizes by name
+func (r *graphQLRepository) languages() map[string]int {
+	if r.Languages == nil {
+		return nil
+	}
+	languages := make(map[string]int)
+	for _, e := range r.Languages.Edges {
+		languages[e.Node.Name] = e.Size
+	}
+	return languages
+}
+
+// map the topic names
+func (r *graphQLRepository) topics() []string {
+	if r.RepositoryTopics == nil {
+		return nil
+	}
+	var topics []string
+	for _, n := range r.RepositoryTopics.Nodes {
+		topics = append(topics, n.Topic.Name)
+	}
+	return topics
+}
+
+// convert time reference to a GitHub timestamp if supplied, otherwise default to nil
+func timestamp(t *time.Time) *gogithub.Timestamp {
+	if t != nil {
+		return &gogithub.Timestamp{Time: *t}
+	}
+	return nil
+}
@@ -0,0 +1,473 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+var postGraphQL = mock.EndpointPattern{Pattern: "/graphql", Method: "POST"}
+
+// decode the query variables from the GraphQL request
+func graphQLTestVariables(t *testing.T, r *http.Request) map[string]interface{} {
+	var req graphQLRequest
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+	return req.Variables
+}
+
+func TestGraphQLGetRepository(t *testing.T) {
+	calls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				vars := graphQLTestVariables(t, r)
+				assert.Equal(t, "testorg", vars["owner"])
+				assert.Equal(t, "testrepo", vars["name"])
+
+				if calls == 1 {
+					assert.Equal(t, true, vars["detail"])
+					_, err := w.Write([]byte(`{"data": {"repository": {
+						"databaseId": 123, "name": "testrepo", "nameWithOwner": "testorg/testrepo",
+						"createdAt": "2021-01-01T10:00:00Z", "updatedAt": "2021-11-01T10:00:00Z", "pushedAt": "2021-10-01T10:00:00Z",
+						"defaultBranchRef": {"name": "main", "branchProtectionRule": {"requiresApprovingReviews": true, "requiredApprovingReviewCount": 2,
+							"requiresStatusChecks": true, "requiredStatusCheckContexts": ["build"], "isAdminEnforced": true}},
+						"squashMergeAllowed": true, "rebaseMergeAllowed": false,
+						"refs": {"nodes": [{"name": "main", "target": {"oid": "abc"}, "branchProtectionRule": {"id": "rule"}},
+							{"name": "feature", "target": {"oid": "def", "committedDate": "2021-09-15T10:00:00Z"}, "branchProtectionRule": null}],
+							"pageInfo": {"hasNextPage": false}},
+						"releases": {"nodes": [{"databaseId": 11, "name": "release-1", "tagName": "v1.0.0", "releaseAssets": {"nodes": [{"name": "app.zip", "downloadCount": 42}]}}], "pageInfo": {"hasNextPage": false}},
+						"pullRequests": {"nodes": [
+							{"databaseId": 21, "number": 3, "title": "pr3", "state": "OPEN", "createdAt": "2021-10-01T10:00:00Z",
+								"reviews": {"nodes": [{"databaseId": 31, "state": "APPROVED", "submittedAt": "2021-10-02T10:00:00Z",
+									"commit": {"oid": "abc"}, "author": {"login": "reviewer"}}]},
+								"reviewRequests": {"nodes": [{"requestedReviewer": {"login": "user1"}}, {"requestedReviewer": {"slug": "team1"}}]}},
+							{"databaseId": 22, "number": 2, "title": "pr2", "state": "MERGED", "createdAt": "2021-09-01T10:00:00Z",
+								"closedAt": "2021-09-02T10:00:00Z", "mergedAt": "2021-09-02T10:00:00Z"}],
+							"pageInfo": {"hasNextPage": true, "endCursor": "cursor-1"}},
+						"languages": {"edges": [{"size": 5000, "node": {"name": "Go"}}, {"size": 2000, "node": {"name": "Bash"}}], "pageInfo": {"hasNextPage": false}},
+						"repositoryTopics": {"nodes": [{"topic": {"name": "topic1"}}, {"topic": {"name": "topic2"}}], "pageInfo": {"hasNextPage": false}}
+					}}}`))
+					assert.NoError(t, err)
+					return
+				}
+
+				// second page should only request the unfinished pull requests
+				assert.Equal(t, false, vars["detail"])
+				assert.Equal(t, false, vars["branches"])
+				assert.Equal(t, false, vars["topics"])
+				assert.Equal(t, true, vars["pullRequests"])
+				assert.Equal(t, "cursor-1", vars["pullRequestsAfter"])
+				_, err := w.Write([]byte(`{"data": {"repository": {
+					"pullRequests": {"nodes": [{"databaseId": 23, "number": 1, "title": "pr1", "state": "CLOSED",
+						"createdAt": "2021-08-01T10:00:00Z", "closedAt": "2021-08-03T10:00:00Z"}],
+						"pageInfo": {"hasNextPage": false}}
+				}}}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			[]github.ContributorStats{
+				{
+					Author: &github.Contributor{ID: github.Int64(1001)},
+					Total:  github.Int(10),
+				},
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposCompareByOwnerByRepoByBasehead,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.True(t, strings.HasSuffix(r.URL.Path, "/compare/main...feature"))
+				_, err := w.Write([]byte(`{"status": "ahead", "ahead_by": 2, "behind_by": 0}`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	if !assert.NotNil(t, repo) {
+		return
+	}
+	assert.Equal(t, int64(123), repo.ID)
+	assert.Equal(t, "testorg", repo.Org)
+	assert.Equal(t, "testrepo", repo.Name)
+	assert.Equal(t, "2021-11-01 10:00:00 +0000 UTC", repo.Changed.String())
+	assert.Equal(t, "main", repo.Detail.GetDefaultBranch())
+	assert.Equal(t, scm.FeatureEnabled, repo.Protection.Status)
+	assert.Equal(t, 2, repo.Protection.Settings.RequiredPullRequestReviews.RequiredApprovingReviewCount)
+	assert.Equal(t, []string{"build"}, repo.Protection.Settings.RequiredStatusChecks.Contexts)
+	assert.True(t, repo.Protection.Settings.EnforceAdmins.Enabled)
+	assert.False(t, repo.Protection.Settings.AllowForcePushes.Enabled)
+	assert.True(t, repo.Detail.GetAllowSquashMerge())
+	assert.False(t, repo.Detail.GetAllowRebaseMerge())
+	assert.Equal(t, 2, len(repo.Branches))
+	assert.Equal(t, "main", repo.Branches[0].GetName())
+	assert.True(t, repo.Branches[0].GetProtected())
+	assert.Equal(t, "abc", repo.Branches[0].GetCommit().GetSHA())
+	assert.False(t, repo.Branches[1].GetProtected())
+	assert.Equal(t, 1, len(repo.BranchActivity))
+	assert.Equal(t, "2021-09-15 10:00:00 +0000 UTC", repo.BranchActivity["feature"].LastCommit.String())
+	assert.False(t, repo.BranchActivity["feature"].Merged)
+	assert.Equal(t, 1, len(repo.Releases))
+	assert.Equal(t, "release-1", repo.Releases[0].GetName())
+	assert.Equal(t, "v1.0.0", repo.Releases[0].GetTagName())
+	assert.Equal(t, 42, repo.Releases[0].Assets[0].GetDownloadCount())
+	assert.Equal(t, 3, len(repo.PullRequests))
+	assert.Equal(t, int64(21), repo.PullRequests[0].GetID())
+	assert.Equal(t, "open", repo.PullRequests[0].GetState())
+	assert.Equal(t, "closed", repo.PullRequests[1].GetState())
+	assert.True(t, repo.PullRequests[1].GetMerged())
+	assert.NotNil(t, repo.PullRequests[1].MergedAt)
+	assert.Equal(t, "pr1", repo.PullRequests[2].GetTitle())
+	assert.Equal(t, "closed", repo.PullRequests[2].GetState())
+	assert.Nil(t, repo.PullRequests[2].MergedAt)
+	assert.Equal(t, "user1", repo.PullRequests[0].RequestedReviewers[0].GetLogin())
+	assert.Equal(t, "team1", repo.PullRequests[0].RequestedTeams[0].GetSlug())
+	assert.Equal(t, 1, len(repo.Reviews[3]))
+	assert.Equal(t, "APPROVED", repo.Reviews[3][0].GetState())
+	assert.Equal(t, "abc", repo.Reviews[3][0].GetCommitID())
+	assert.Equal(t, "reviewer", repo.Reviews[3][0].GetUser().GetLogin())
+	assert.Equal(t, []string{"topic1", "topic2"}, repo.Topics)
+	assert.Equal(t, map[string]int{"Go": 5000, "Bash": 2000}, repo.Languages)
+	assert.Equal(t, 1, len(repo.Contributors))
+	assert.Equal(t, scm.Truncated{}, repo.Truncated)
+}
+
+func TestGraphQLGetRepository_PageLimit(t *testing.T) {
+	calls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				vars := graphQLTestVariables(t, r)
+				pullRequests := `, "pullRequests": {"nodes": [{"databaseId": 1, "state": "OPEN"}], "pageInfo": {"hasNextPage": true, "endCursor": "next"}}`
+				if calls > 1 {
+					// pull requests stop at the limit while branches continue
+					assert.Equal(t, false, vars["pullRequests"])
+					assert.Equal(t, true, vars["branches"])
+					pullRequests = ""
+				}
+				_, err := w.Write([]byte(`{"data": {"repository": {
+					"databaseId": 123,
+					"refs": {"nodes": [{"name": "branch"}], "pageInfo": {"hasNextPage": ` + fmt.Sprint(calls < 3) + `, "endCursor": "next"}}` +
+					pullRequests + `
+				}}}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			[]github.ContributorStats{},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{PullRequests: 1, Branches: -1}}
+
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, len(repo.Branches))
+	assert.Equal(t, 1, len(repo.PullRequests))
+	assert.Equal(t, scm.Truncated{PullRequests: true}, repo.Truncated)
+}
+
+func TestGraphQLGetRepository_PullRequestsSince(t *testing.T) {
+	calls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				vars := graphQLTestVariables(t, r)
+				assert.Equal(t, "UPDATED_AT", vars["pullRequestsOrder"])
+				_, err := w.Write([]byte(`{"data": {"repository": {
+					"databaseId": 123, "name": "testrepo", "updatedAt": "2021-11-01T10:00:00Z",
+					"pullRequests": {"nodes": [
+						{"databaseId": 21, "number": 3, "title": "pr3", "state": "OPEN", "createdAt": "2021-10-01T10:00:00Z", "updatedAt": "2021-11-01T10:00:00Z"},
+						{"databaseId": 22, "number": 2, "title": "pr2", "state": "OPEN", "createdAt": "2021-09-01T10:00:00Z", "updatedAt": "2021-09-01T10:00:00Z"}],
+						"pageInfo": {"hasNextPage": true, "endCursor": "cursor-1"}}
+				}}}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			[]github.ContributorStats{},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	since := time.Date(2021, 10, 15, 0, 0, 0, 0, time.UTC)
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{PullRequestsSince: &since})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	if !assert.NotNil(t, repo) {
+		return
+	}
+	assert.Equal(t, &since, repo.PullRequestsSince)
+	assert.False(t, repo.Truncated.PullRequests)
+	assert.Equal(t, 1, len(repo.PullRequests))
+	assert.Equal(t, int64(21), repo.PullRequests[0].GetID())
+}
+
+func TestGraphQLGetRepository_QueryError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte(`{"data": {"repository": null}, "errors": [{"type": "NOT_FOUND", "message": "Could not resolve to a Repository"}]}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			[]github.ContributorStats{},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
+
+	assert.Nil(t, repo)
+	if assert.Error(t, err) {
+		assert.Equal(t, "GraphQL query for testorg/testrepo failed: Could not resolve to a Repository", err.Error())
+	}
+}
+
+func TestGraphQLGetRepository_APIError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusInternalServerError, "github went belly up or something")
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			[]github.ContributorStats{},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
+
+	assert.Nil(t, repo)
+	assert.Error(t, err)
+}
+
+func TestGraphQLGetRepository_ContributorsAPIError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(featuresNotEnabled(t,
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte(`{"data": {"repository": {
+					"databaseId": 123,
+					"refs": {"nodes": [{"name": "branch"}], "pageInfo": {"hasNextPage": false}}
+				}}}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusInternalServerError, "github went belly up or something")
+			}),
+		),
+	)...)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), repo.ID)
+	assert.Equal(t, 1, len(repo.Branches))
+	assert.Nil(t, repo.Contributors)
+	assert.Equal(t, 1, len(repo.SectionErrors))
+	assert.Contains(t, repo.SectionErrors, scm.SectionContributors)
+}
+
+func TestGraphQLGetRepository_NestedConnectionsCompleted(t *testing.T) {
+	// the query returns a page of 100 reviews for pull request 5 out of the 150 submitted
+	var reviewNodes []string
+	var restReviews []github.PullRequestReview
+	for i := 1; i <= 150; i++ {
+		if i <= 100 {
+			reviewNodes = append(reviewNodes, fmt.Sprintf(`{"databaseId": %d, "state": "COMMENTED"}`, i))
+		}
+		restReviews = append(restReviews, github.PullRequestReview{ID: github.Int64(int64(i)), State: github.String("COMMENTED")})
+	}
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(featuresNotEnabled(t,
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte(`{"data": {"repository": {
+					"databaseId": 123,
+					"releases": {"nodes": [{"databaseId": 11, "tagName": "v1.0.0",
+						"releaseAssets": {"nodes": [{"name": "app.zip", "downloadCount": 42}], "pageInfo": {"hasNextPage": true}}}],
+						"pageInfo": {"hasNextPage": false}},
+					"pullRequests": {"nodes": [
+						{"databaseId": 21, "number": 5, "state": "OPEN",
+							"reviews": {"nodes": [` + strings.Join(reviewNodes, ",") + `], "pageInfo": {"hasNextPage": true}},
+							"reviewRequests": {"nodes": [{"requestedReviewer": {"login": "user1"}}], "pageInfo": {"hasNextPage": true}}},
+						{"databaseId": 22, "number": 4, "state": "OPEN",
+							"reviews": {"nodes": [{"databaseId": 200, "state": "APPROVED"}], "pageInfo": {"hasNextPage": false}},
+							"reviewRequests": {"nodes": [{"requestedReviewer": {"slug": "team1"}}], "pageInfo": {"hasNextPage": false}}}],
+						"pageInfo": {"hasNextPage": false}}
+				}}}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.True(t, strings.HasSuffix(r.URL.Path, "/pulls/5/reviews"))
+				page := restReviews[:100]
+				if r.URL.Query().Get("page") == "2" {
+					page = restReviews[100:]
+				} else {
+					w.Header().Set("Link", `<https://api.github.com/repos/testorg/testrepo/pulls/5/reviews?page=2>; rel="next"`)
+				}
+				_, err := w.Write(mock.MustMarshal(page))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsRequestedReviewersByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.True(t, strings.HasSuffix(r.URL.Path, "/pulls/5/requested_reviewers"))
+				_, err := w.Write([]byte(`{"users": [{"login": "user1"}, {"login": "user2"}], "teams": [{"slug": "team2"}]}`))
+				assert.NoError(t, err)
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposReleasesAssetsByOwnerByRepoByReleaseId,
+			[]github.ReleaseAsset{
+				{Name: github.String("app.zip"), DownloadCount: github.Int(42)},
+				{Name: github.String("app.tar.gz"), DownloadCount: github.Int(8)},
+			},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			[]github.ContributorStats{},
+		),
+	)...)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
+
+	assert.NoError(t, err)
+	if !assert.NotNil(t, repo) {
+		return
+	}
+	assert.Equal(t, 150, len(repo.Reviews[5]))
+	assert.Equal(t, int64(150), repo.Reviews[5][149].GetID())
+	assert.Equal(t, 1, len(repo.Reviews[4]))
+	if assert.Equal(t, 2, len(repo.PullRequests)) {
+		assert.Equal(t, 2, len(repo.PullRequests[0].RequestedReviewers))
+		assert.Equal(t, "team2", repo.PullRequests[0].RequestedTeams[0].GetSlug())
+		assert.Equal(t, "team1", repo.PullRequests[1].RequestedTeams[0].GetSlug())
+	}
+	if assert.Equal(t, 1, len(repo.Releases)) {
+		assert.Equal(t, 2, len(repo.Releases[0].Assets))
+	}
+}
+
+func TestGraphQLGetBranches_ExceedPageSanityCheck(t *testing.T) {
+	calls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				vars := graphQLTestVariables(t, r)
+				assert.Equal(t, false, vars["detail"])
+				assert.Equal(t, false, vars["releases"])
+				_, err := w.Write([]byte(`{"data": {"repository": {
+					"refs": {"nodes": [{"name": "branch"}], "pageInfo": {"hasNextPage": true, "endCursor": "next"}}
+				}}}`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	branches, truncated, err := m.GetBranches(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, 10, calls)
+	assert.Equal(t, 10, len(branches))
+}
+
+func TestGraphQLGetReleases(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			postGraphQL,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				vars := graphQLTestVariables(t, r)
+				assert.Equal(t, true, vars["releases"])
+				assert.Equal(t, false, vars["branches"])
+				_, err := w.Write([]byte(`{"data": {"repository": {
+					"releases": {"nodes": [{"name": "release-1"}, {"name": "release-2"}], "pageInfo": {"hasNextPage": false}}
+				}}}`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := GraphQLDataCollector{GitHubClient: c}
+
+	releases, truncated, err := m.GetReleases(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, 2, len(releases))
+	assert.Equal(t, "release-2", releases[1].GetName())
+}
+
+func TestGraphQLEndpoint(t *testing.T) {
+	c := github.NewClient(nil)
+	assert.Equal(t, "graphql", graphQLEndpoint(c))
+
+	c, err := github.NewEnterpriseClient("https://testgithub.com/", "https://testgithub.com/", nil)
+	assert.NoError(t, err)
+	req, err := c.NewRequest(http.MethodPost, graphQLEndpoint(c), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://testgithub.com/api/graphql", req.URL.String())
+}
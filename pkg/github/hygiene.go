@@ -0,0 +1,78 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// GetHygieneFiles determines which of the governance files are present by organization/repo.  Files reported
+// by the community profile aren't looked up, the remaining files are looked up at each of their paths.
+func (m RepositoryDataCollector) GetHygieneFiles(ctx context.Context, org string, repo string, files []scm.HygieneFile) (map[string]bool, error) {
+	present := make(map[string]bool)
+
+	// the community profile isn't available for all repositories, falling back to the contents lookups
+	glog.V(2).Infof("Collecting community profile for %s/%s", org, repo)
+	profile, _, err := m.GitHubClient.Repositories.GetCommunityHealthMetrics(ctx, org, repo)
+	if err != nil {
+		glog.V(2).Infof("Community profile not available for %s/%s (%s)", org, repo, err.Error())
+	}
+	community := communityFiles(profile)
+
+	for _, f := range files {
+		if community[f.Name] {
+			present[f.Name] = true
+			continue
+		}
+		found, err := m.contentExists(ctx, org, repo, f.Paths)
+		if err != nil {
+			return nil, err
+		}
+		present[f.Name] = found
+	}
+	return present, nil
+}
+
+// determine if content exists at any of the paths
+func (m RepositoryDataCollector) contentExists(ctx context.Context, org string, repo string, paths []string) (bool, error) {
+	for _, path := range paths {
+		glog.V(3).Infof("Looking up %s in %s/%s", path, org, repo)
+		_, _, _, err := m.GitHubClient.Repositories.GetContents(ctx, org, repo, path, nil)
+		if err == nil {
+			return true, nil
+		}
+		var errResp *gogithub.ErrorResponse
+		if !errors.As(err, &errResp) || errResp.Response == nil || errResp.Response.StatusCode != http.StatusNotFound {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// collect the presence of the governance files, nil when no files are configured
+func (m RepositoryDataCollector) collectHygieneFiles(ctx context.Context, org string, repo string, files []scm.HygieneFile) (map[string]bool, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return m.GetHygieneFiles(ctx, org, repo, files)
+}
+
+// governance files reported present by the community profile by hygiene file name
+func communityFiles(profile *gogithub.CommunityHealthMetrics) map[string]bool {
+	if profile == nil || profile.Files == nil {
+		return nil
+	}
+	files := profile.Files
+	return map[string]bool{
+		"readme":                files.Readme != nil,
+		"license":               files.License != nil,
+		"contributing":          files.Contributing != nil,
+		"pull-request-template": files.PullRequestTemplate != nil,
+		"code-of-conduct":       files.CodeOfConduct != nil || files.CodeOfConductFile != nil,
+	}
+}
@@ -0,0 +1,115 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// serve the repository contents found at the paths, answering 404 for other paths
+func contentsHandler(t *testing.T, found ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[strings.Index(r.URL.Path, "/contents/")+len("/contents/"):]
+		for _, f := range found {
+			if f == path {
+				_, err := w.Write([]byte(`{"type": "file", "path": "` + path + `"}`))
+				assert.NoError(t, err)
+				return
+			}
+		}
+		writeGitHubError(t, w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func TestGetHygieneFiles(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposCommunityProfileByOwnerByRepo,
+			github.CommunityHealthMetrics{Files: &github.CommunityHealthFiles{Readme: &github.Metric{}}},
+		),
+		mock.WithRequestMatchHandler(
+			mock.EndpointPattern{Pattern: "/repos/{owner}/{repo}/contents/{path:.+}", Method: "GET"},
+			contentsHandler(t, "README.md", "docs/CODEOWNERS", ".github/dependabot.yml"),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	files, err := m.GetHygieneFiles(context.Background(), "testorg", "testrepo", []scm.HygieneFile{
+		{Name: "readme", Paths: []string{"readme.rst"}},
+		{Name: "codeowners", Paths: []string{"CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"}},
+		{Name: "security", Paths: []string{"SECURITY.md"}},
+		{Name: "dependabot", Paths: []string{".github/dependabot.yml"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"readme": true, "codeowners": true, "security": false, "dependabot": true}, files)
+}
+
+func TestGetHygieneFiles_NoCommunityProfile(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposCommunityProfileByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusNotFound, "Not Found")
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.EndpointPattern{Pattern: "/repos/{owner}/{repo}/contents/{path:.+}", Method: "GET"},
+			contentsHandler(t, "README.md"),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	files, err := m.GetHygieneFiles(context.Background(), "testorg", "testrepo", scm.DefaultHygieneFiles)
+
+	assert.NoError(t, err)
+	assert.True(t, files["readme"])
+	assert.False(t, files["license"])
+	assert.Equal(t, len(scm.DefaultHygieneFiles), len(files))
+}
+
+func TestCollectHygieneFiles_Error(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposCommunityProfileByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusNotFound, "Not Found")
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.EndpointPattern{Pattern: "/repos/{owner}/{repo}/contents/{path:.+}", Method: "GET"},
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusForbidden, "Resource not accessible by integration")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	files, err := m.collectHygieneFiles(context.Background(), "testorg", "testrepo", scm.DefaultHygieneFiles)
+
+	assert.Error(t, err)
+	assert.Nil(t, files)
+}
+
+func TestCollectHygieneFiles_NoFiles(t *testing.T) {
+	c := github.NewClient(mock.NewMockedHTTPClient())
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	files, err := m.collectHygieneFiles(context.Background(), "testorg", "testrepo", nil)
+
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+}
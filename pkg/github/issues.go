@@ -0,0 +1,135 @@
+package github
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+)
+
+// GetIssues retrieves the open and closed issues by organization/repo newest first, excluding the pull
+// requests also listed by the issues API and flagging when truncated by the page limit
+func (m RepositoryDataCollector) GetIssues(ctx context.Context, org string, repo string) ([]*gogithub.Issue, bool, error) {
+	opt := &gogithub.IssueListByRepoOptions{
+		State:       "all",
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+
+	// process all pages until finished
+	var loopCnt = 0
+	var allIssues []*gogithub.Issue
+	for {
+		// stop looking for issues once the page limit is hit
+		loopCnt++
+		if pageLimitReached(m.PageLimits.Issues, loopCnt) {
+			glog.Warningf("Repository has more than %d issues: %s/%s", len(allIssues), org, repo)
+			return allIssues, true, nil
+		}
+
+		glog.V(2).Infof("Collecting issues for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
+		issues, resp, err := m.GitHubClient.Issues.ListByRepo(ctx, org, repo, opt)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, issue := range issues {
+			if !issue.IsPullRequest() {
+				allIssues = append(allIssues, issue)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allIssues, false, nil
+}
+
+// GetIssueComments retrieves the comments updated since the supplied time on the issues by issue number, the
+// comments are listed for the whole repository oldest first and flagged when truncated by the page limit.  Only
+// the newest comments are left out when truncated, so the first response to an issue is never a later comment.
+func (m RepositoryDataCollector) GetIssueComments(ctx context.Context, org string, repo string, issues []*gogithub.Issue, since time.Time) (map[int][]*gogithub.IssueComment, bool, error) {
+	opt := &gogithub.IssueListCommentsOptions{
+		Sort:        gogithub.String("created"),
+		Direction:   gogithub.String("asc"),
+		Since:       &since,
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+
+	comments := make(map[int][]*gogithub.IssueComment)
+	for _, issue := range issues {
+		comments[issue.GetNumber()] = nil
+	}
+
+	// process all pages until finished
+	var loopCnt = 0
+	for {
+		// stop looking for comments once the page limit is hit
+		loopCnt++
+		if pageLimitReached(m.PageLimits.Issues, loopCnt) {
+			glog.Warningf("Repository has more than %d pages of issue comments: %s/%s", loopCnt-1, org, repo)
+			return comments, true, nil
+		}
+
+		glog.V(2).Infof("Collecting issue comments for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
+		page, resp, err := m.GitHubClient.Issues.ListComments(ctx, org, repo, 0, opt)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, c := range page {
+			// pull request comments are also listed, only keeping those on the collected issues
+			number := issueNumber(c.GetIssueURL())
+			if _, found := comments[number]; found {
+				comments[number] = append(comments[number], c)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return comments, false, nil
+}
+
+// collect the issues and their comments, issues disabled for the repository leave the issues uncollected, other
+// errors are returned
+func (m RepositoryDataCollector) collectIssues(ctx context.Context, org string, repo string) ([]*gogithub.Issue, map[int][]*gogithub.IssueComment, bool, error) {
+	issues, truncated, err := m.GetIssues(ctx, org, repo)
+	if err != nil {
+		if featureUnavailable(err) {
+			glog.V(2).Infof("Issues not available for %s/%s: %s", org, repo, err)
+			return nil, nil, false, nil
+		}
+		return nil, nil, false, err
+	}
+	if len(issues) == 0 {
+		return []*gogithub.Issue{}, nil, truncated, nil
+	}
+
+	// comments can't precede the oldest issue collected
+	since := time.Now()
+	for _, issue := range issues {
+		if issue.CreatedAt != nil && issue.CreatedAt.Before(since) {
+			since = *issue.CreatedAt
+		}
+	}
+
+	comments, commentsTruncated, err := m.GetIssueComments(ctx, org, repo, issues, since)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return issues, comments, truncated || commentsTruncated, nil
+}
+
+// issue number from the issue url of a comment, zero when not found
+func issueNumber(issueURL string) int {
+	number, err := strconv.Atoi(issueURL[strings.LastIndex(issueURL, "/")+1:])
+	if err != nil {
+		return 0
+	}
+	return number
+}
@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func TestGetIssues_ExcludesPullRequests(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposIssuesByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "all", r.URL.Query().Get("state"))
+				_, err := w.Write([]byte(`[{"number": 3}, {"number": 2, "pull_request": {"url": "https://api.github.com/repos/testorg/testrepo/pulls/2"}}, {"number": 1}]`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	issues, truncated, err := m.GetIssues(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, 2, len(issues))
+	assert.Equal(t, 3, issues[0].GetNumber())
+	assert.Equal(t, 1, issues[1].GetNumber())
+}
+
+func TestGetIssues_ExceedPageLimit(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposIssuesByOwnerByRepo,
+			[]github.Issue{{Number: github.Int(2)}},
+			[]github.Issue{{Number: github.Int(1)}},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{Issues: 1}}
+
+	issues, truncated, err := m.GetIssues(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, 1, len(issues))
+}
+
+func TestCollectIssues(t *testing.T) {
+	created := time.Date(2021, 10, 1, 10, 0, 0, 0, time.UTC)
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposIssuesByOwnerByRepo,
+			[]github.Issue{
+				{Number: github.Int(2), CreatedAt: &created},
+				{Number: github.Int(1), CreatedAt: &created},
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposIssuesCommentsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "2021-10-01T10:00:00Z", r.URL.Query().Get("since"))
+				assert.Equal(t, "asc", r.URL.Query().Get("direction"))
+				_, err := w.Write([]byte(`[
+					{"id": 30, "issue_url": "https://api.github.com/repos/testorg/testrepo/issues/5"},
+					{"id": 20, "issue_url": "https://api.github.com/repos/testorg/testrepo/issues/2"},
+					{"id": 10, "issue_url": "https://api.github.com/repos/testorg/testrepo/issues/2"}
+				]`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	issues, comments, truncated, err := m.collectIssues(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(issues))
+	assert.False(t, truncated)
+	assert.Equal(t, 2, len(comments[2]))
+	assert.Equal(t, 0, len(comments[1]))
+	_, found := comments[5]
+	assert.False(t, found)
+}
+
+func TestGetIssueComments_ExceedPageLimit(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposIssuesCommentsByOwnerByRepo,
+			[]github.IssueComment{
+				{ID: github.Int64(10), IssueURL: github.String("https://api.github.com/repos/testorg/testrepo/issues/1")},
+			},
+			[]github.IssueComment{
+				{ID: github.Int64(20), IssueURL: github.String("https://api.github.com/repos/testorg/testrepo/issues/1")},
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{Issues: 1}}
+
+	comments, truncated, err := m.GetIssueComments(context.Background(), "testorg", "testrepo", []*github.Issue{{Number: github.Int(1)}}, time.Now())
+
+	// the oldest comments are kept, holding the first response
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, 1, len(comments[1]))
+	assert.Equal(t, int64(10), comments[1][0].GetID())
+}
+
+func TestCollectIssues_Error(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposIssuesByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusGone, "Issues are disabled for this repo")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	issues, comments, truncated, err := m.collectIssues(context.Background(), "testorg", "testrepo")
+
+	// issues disabled for the repository aren't a failure
+	assert.NoError(t, err)
+	assert.Nil(t, issues)
+	assert.Nil(t, comments)
+	assert.False(t, truncated)
+}
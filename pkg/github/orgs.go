@@ -0,0 +1,41 @@
+package github
+
+import (
+	"context"
+	"errors"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+)
+
+// ListOrganizations retrieves the logins of the organizations the authenticated user belongs to, requiring user
+// credentials as GitHub App installations can't list them
+func (m RepositoryDataCollector) ListOrganizations(ctx context.Context) ([]string, error) {
+	opt := &gogithub.ListOptions{Page: 1, PerPage: 100}
+
+	// process all pages until finished
+	var loopCnt = 0
+	var allOrgs []string
+	for {
+		// sanity check the paging loop to prevent infinite loop and spamming of github api
+		loopCnt++
+		if loopCnt > 1000 {
+			return nil, errors.New("organization loop exceeded sanity check")
+		}
+
+		glog.V(2).Infof("Collecting organizations, count per page = %d, page number = %d", opt.PerPage, opt.Page)
+		orgs, resp, err := m.GitHubClient.Organizations.List(ctx, "", opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range orgs {
+			allOrgs = append(allOrgs, o.GetLogin())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allOrgs, nil
+}
@@ -0,0 +1,53 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListOrganizations(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetUserOrgs,
+			[]github.Organization{
+				{Login: github.String("org1")},
+				{Login: github.String("org2")},
+			},
+			[]github.Organization{
+				{Login: github.String("org3")},
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	orgs, err := m.ListOrganizations(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"org1", "org2", "org3"}, orgs)
+}
+
+func TestListOrganizations_APIError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetUserOrgs,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusUnauthorized, "Bad credentials")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	orgs, err := m.ListOrganizations(context.Background())
+
+	assert.Error(t, err)
+	assert.Nil(t, orgs)
+}
@@ -0,0 +1,68 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+)
+
+// ownerListing endpoint used to list the repositories of an owner
+type ownerListing int
+
+const (
+	// repositories of an organization
+	listOrgRepos ownerListing = iota
+	// repositories owned by a user account other than the authenticated user, public repositories only
+	listUserRepos
+	// repositories owned by the authenticated user, including private repositories
+	listOwnRepos
+)
+
+// list a page of repositories of the owner using the sort, newest first
+func (m RepositoryDataCollector) listPage(ctx context.Context, owner string, listing ownerListing, sort string, page gogithub.ListOptions) ([]*gogithub.Repository, *gogithub.Response, error) {
+	switch listing {
+	case listUserRepos:
+		opt := &gogithub.RepositoryListOptions{Type: "owner", Sort: sort, Direction: "desc", ListOptions: page}
+		return m.GitHubClient.Repositories.List(ctx, owner, opt)
+	case listOwnRepos:
+		opt := &gogithub.RepositoryListOptions{Affiliation: "owner", Sort: sort, Direction: "desc", ListOptions: page}
+		return m.GitHubClient.Repositories.List(ctx, "", opt)
+	default:
+		opt := &gogithub.RepositoryListByOrgOptions{Sort: sort, Direction: "desc", ListOptions: page}
+		return m.GitHubClient.Repositories.ListByOrg(ctx, owner, opt)
+	}
+}
+
+// determine the listing for repositories of the user account, private repositories only being listed when the
+// user is the authenticated user
+func (m RepositoryDataCollector) userListing(ctx context.Context, user string) ownerListing {
+	self, _, err := m.GitHubClient.Users.Get(ctx, "")
+	if err != nil {
+		// app installations have no authenticated user
+		glog.V(2).Infof("Unable to determine authenticated user, listing public repositories of %s: %s", user, err)
+		return listUserRepos
+	}
+	if strings.EqualFold(self.GetLogin(), user) {
+		return listOwnRepos
+	}
+	return listUserRepos
+}
+
+// determine if the account is a user rather than an organization
+func (m RepositoryDataCollector) isUser(ctx context.Context, name string) (bool, error) {
+	account, _, err := m.GitHubClient.Users.Get(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return account.GetType() == "User", nil
+}
+
+// determine if the error is a GitHub not found response
+func isNotFound(err error) bool {
+	var errResp *gogithub.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,104 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListRepositories_UserDetected(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetOrgsReposByOrg,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusNotFound, "Not Found")
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetUsersByUsername,
+			github.User{Login: github.String("testuser"), Type: github.String("User")},
+		),
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("serviceaccount"), Type: github.String("User")},
+		),
+		mock.WithRequestMatch(
+			mock.GetUsersReposByUsername,
+			[]github.Repository{
+				{ID: github.Int64(123), Name: github.String("Repo-123")},
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	repos, err := m.ListRepositories(context.Background(), "testuser", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(repos))
+	assert.Equal(t, "testuser", repos[0].Org)
+	assert.Equal(t, "Repo-123", repos[0].Name)
+}
+
+func TestListRepositories_OwnUserRepositories(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("TestUser"), Type: github.String("User")},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetUserRepos,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "owner", r.URL.Query().Get("affiliation"))
+				assert.Equal(t, "created", r.URL.Query().Get("sort"))
+				_, err := w.Write(mock.MustMarshal([]github.Repository{
+					{ID: github.Int64(123), Name: github.String("Private-123"), Private: github.Bool(true)},
+					{ID: github.Int64(124), Name: github.String("Repo-124")},
+				}))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, UserOwners: true}
+
+	repos, err := m.ListRepositories(context.Background(), "testuser", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(repos))
+	assert.Equal(t, "testuser", repos[0].Org)
+	assert.Equal(t, "Private-123", repos[0].Name)
+	assert.Equal(t, "Repo-124", repos[1].Name)
+}
+
+func TestListRepositories_UnknownOwner(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetOrgsReposByOrg,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusNotFound, "Not Found")
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetUsersByUsername,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusNotFound, "Not Found")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	repos, err := m.ListRepositories(context.Background(), "missing", nil)
+
+	assert.Error(t, err)
+	assert.True(t, isNotFound(err))
+	assert.Nil(t, repos)
+}
@@ -0,0 +1,260 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	gogithub "github.com/google/go-github/v39/github"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// ProviderName name of the GitHub source control provider
+const ProviderName = "github"
+
+// Provider collects repositories from GitHub using the collector, converting them to the provider neutral
+// repository model
+type Provider struct {
+	Collector DataCollector
+}
+
+// ListOrganizations retrieves the logins of the organizations the authenticated user belongs to
+func (p Provider) ListOrganizations(ctx context.Context) ([]string, error) {
+	return p.Collector.ListOrganizations(ctx)
+}
+
+// ListRepositories retrieves the set of repositories for an organization changed after the time
+func (p Provider) ListRepositories(ctx context.Context, org string, changedAfter *time.Time) ([]scm.Repository, error) {
+	repos, err := p.Collector.ListRepositories(ctx, org, changedAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	var neutral []scm.Repository
+	for i := range repos {
+		neutral = append(neutral, *NeutralRepository(&repos[i]))
+	}
+	return neutral, nil
+}
+
+// GetRepository retrieves the repository information by organization/name
+func (p Provider) GetRepository(ctx context.Context, org string, name string, opts scm.CollectOptions) (*scm.Repository, error) {
+	r, err := p.Collector.GetRepository(ctx, org, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return NeutralRepository(r), nil
+}
+
+// NeutralRepository converts the GitHub repository to the provider neutral repository model
+func NeutralRepository(r *Repository) *scm.Repository {
+	neutral := &scm.Repository{
+		ID:                  r.ID,
+		Provider:            ProviderName,
+		Org:                 r.Org,
+		Name:                r.Name,
+		Topics:              r.Topics,
+		Changed:             r.Changed,
+		Detail:              neutralDetail(r.Detail),
+		BranchActivity:      r.BranchActivity,
+		Protection:          neutralProtection(r.Protection),
+		Languages:           r.Languages,
+		ContributorsPending: r.ContributorsPending,
+		Security:            neutralSecurity(r.Security),
+		HygieneFiles:        r.HygieneFiles,
+		Truncated:           r.Truncated,
+		PullRequestsSince:   r.PullRequestsSince,
+		SectionErrors:       r.SectionErrors,
+	}
+
+	for _, b := range r.Branches {
+		neutral.Branches = append(neutral.Branches, scm.Branch{Name: b.GetName(), Protected: b.GetProtected()})
+	}
+	for _, release := range r.Releases {
+		neutral.Releases = append(neutral.Releases, neutralRelease(release))
+	}
+	for _, pr := range r.PullRequests {
+		neutral.PullRequests = append(neutral.PullRequests, neutralPullRequest(pr))
+	}
+	if r.Reviews != nil {
+		neutral.Reviews = make(map[int][]scm.Review, len(r.Reviews))
+		for number, reviews := range r.Reviews {
+			for _, review := range reviews {
+				neutral.Reviews[number] = append(neutral.Reviews[number], scm.Review{
+					ID:        review.GetID(),
+					State:     review.GetState(),
+					Author:    review.GetUser().GetLogin(),
+					CommitID:  review.GetCommitID(),
+					Submitted: review.SubmittedAt,
+				})
+			}
+		}
+	}
+	for _, c := range r.Contributors {
+		neutral.Contributors = append(neutral.Contributors, scm.Contributor{Login: c.GetAuthor().GetLogin(), Commits: c.GetTotal()})
+	}
+
+	// empty rather than nil when none are defined, nil indicating the workflows weren't collected
+	if r.Workflows != nil {
+		neutral.Workflows = []scm.Workflow{}
+	}
+	for _, w := range r.Workflows {
+		neutral.Workflows = append(neutral.Workflows, scm.Workflow{ID: w.GetID(), Name: w.GetName()})
+	}
+	for _, run := range r.WorkflowRuns {
+		neutral.WorkflowRuns = append(neutral.WorkflowRuns, scm.WorkflowRun{
+			WorkflowID: run.GetWorkflowID(),
+			Name:       run.GetName(),
+			Status:     run.GetStatus(),
+			Conclusion: run.GetConclusion(),
+			Created:    extractTime(run.CreatedAt),
+			Updated:    extractTime(run.UpdatedAt),
+		})
+	}
+
+	for _, d := range r.Deployments {
+		neutral.Deployments = append(neutral.Deployments, scm.Deployment{
+			ID:          d.GetID(),
+			SHA:         d.GetSHA(),
+			Environment: d.GetEnvironment(),
+			Created:     extractTime(d.CreatedAt),
+		})
+	}
+	if r.DeploymentStatuses != nil {
+		neutral.DeploymentStatuses = make(map[int64][]scm.DeploymentStatus, len(r.DeploymentStatuses))
+		for id, statuses := range r.DeploymentStatuses {
+			for _, s := range statuses {
+				neutral.DeploymentStatuses[id] = append(neutral.DeploymentStatuses[id], scm.DeploymentStatus{State: s.GetState(), Created: extractTime(s.CreatedAt)})
+			}
+		}
+	}
+
+	// empty rather than nil when there are none, nil indicating the issues weren't collected
+	if r.Issues != nil {
+		neutral.Issues = []scm.Issue{}
+	}
+	for _, issue := range r.Issues {
+		neutral.Issues = append(neutral.Issues, neutralIssue(issue))
+	}
+	if r.IssueComments != nil {
+		neutral.IssueComments = make(map[int][]scm.IssueComment, len(r.IssueComments))
+		for number, comments := range r.IssueComments {
+			for _, c := range comments {
+				neutral.IssueComments[number] = append(neutral.IssueComments[number], scm.IssueComment{Author: c.GetUser().GetLogin(), Created: c.CreatedAt})
+			}
+		}
+	}
+	return neutral
+}
+
+// convert the repository settings and timestamps
+func neutralDetail(r *gogithub.Repository) scm.Detail {
+	if r == nil {
+		return scm.Detail{}
+	}
+	return scm.Detail{
+		Created:       extractTime(r.CreatedAt),
+		Updated:       extractTime(r.UpdatedAt),
+		Pushed:        extractTime(r.PushedAt),
+		DefaultBranch: r.GetDefaultBranch(),
+		Squashable:    r.GetAllowSquashMerge(),
+		Rebaseable:    r.GetAllowRebaseMerge(),
+		Archived:      r.GetArchived(),
+		Fork:          r.GetFork(),
+		Template:      r.GetIsTemplate(),
+		Private:       r.GetPrivate(),
+		Visibility:    r.GetVisibility(),
+	}
+}
+
+// convert the branch protection rules
+func neutralProtection(protection BranchProtection) scm.BranchProtection {
+	neutral := scm.BranchProtection{Status: protection.Status}
+	settings := protection.Settings
+	if settings == nil {
+		return neutral
+	}
+
+	neutral.Settings = &scm.ProtectionSettings{
+		EnforceAdmins:        settings.EnforceAdmins != nil && settings.EnforceAdmins.Enabled,
+		RequireLinearHistory: settings.RequireLinearHistory != nil && settings.RequireLinearHistory.Enabled,
+		AllowForcePushes:     settings.AllowForcePushes != nil && settings.AllowForcePushes.Enabled,
+		AllowDeletions:       settings.AllowDeletions != nil && settings.AllowDeletions.Enabled,
+	}
+	if reviews := settings.RequiredPullRequestReviews; reviews != nil {
+		neutral.Settings.RequiredApprovingReviewCount = reviews.RequiredApprovingReviewCount
+		neutral.Settings.DismissStaleReviews = reviews.DismissStaleReviews
+		neutral.Settings.RequireCodeOwnerReviews = reviews.RequireCodeOwnerReviews
+	}
+	if checks := settings.RequiredStatusChecks; checks != nil {
+		neutral.Settings.RequiredStatusChecks = checks.Contexts
+		neutral.Settings.StrictStatusChecks = checks.Strict
+	}
+	return neutral
+}
+
+// convert the release, totalling the downloads of its assets
+func neutralRelease(r *gogithub.RepositoryRelease) scm.Release {
+	release := scm.Release{
+		ID:         r.GetID(),
+		Name:       r.GetName(),
+		Tag:        r.GetTagName(),
+		Draft:      r.GetDraft(),
+		Prerelease: r.GetPrerelease(),
+		Created:    extractTime(r.CreatedAt),
+		Published:  extractTime(r.PublishedAt),
+	}
+	for _, a := range r.Assets {
+		release.Downloads += a.GetDownloadCount()
+	}
+	return release
+}
+
+// convert the pull request, counting both the users and teams requested to review
+func neutralPullRequest(pr *gogithub.PullRequest) scm.PullRequest {
+	return scm.PullRequest{
+		ID:                 pr.GetID(),
+		Number:             pr.GetNumber(),
+		State:              pr.GetState(),
+		Author:             pr.GetUser().GetLogin(),
+		RequestedReviewers: len(pr.RequestedReviewers) + len(pr.RequestedTeams),
+		Created:            pr.CreatedAt,
+		Updated:            pr.UpdatedAt,
+		Closed:             pr.ClosedAt,
+		Merged:             pr.MergedAt,
+	}
+}
+
+// convert the security alerts, code scanning alerts rank by their security severity, others by the rule severity
+func neutralSecurity(security SecurityAlerts) scm.SecurityAlerts {
+	neutral := scm.SecurityAlerts{CodeScanning: security.CodeScanning, Dependabot: security.Dependabot}
+	for _, alert := range security.CodeScanningAlerts {
+		severity := alert.GetRule().GetSecuritySeverityLevel()
+		if severity == "" {
+			severity = alert.GetRule().GetSeverity()
+		}
+		if severity == "" {
+			severity = alert.GetRuleSeverity()
+		}
+		neutral.CodeScanningAlerts = append(neutral.CodeScanningAlerts, scm.SecurityAlert{Severity: severity})
+	}
+	for _, alert := range security.DependabotAlerts {
+		neutral.DependabotAlerts = append(neutral.DependabotAlerts, scm.SecurityAlert{Severity: alert.SecurityAdvisory.Severity})
+	}
+	return neutral
+}
+
+// convert the issue along with the names of its labels
+func neutralIssue(issue *gogithub.Issue) scm.Issue {
+	neutral := scm.Issue{
+		Number:  issue.GetNumber(),
+		State:   issue.GetState(),
+		Author:  issue.GetUser().GetLogin(),
+		Created: issue.CreatedAt,
+		Closed:  issue.ClosedAt,
+	}
+	for _, l := range issue.Labels {
+		neutral.Labels = append(neutral.Labels, l.GetName())
+	}
+	return neutral
+}
@@ -0,0 +1,103 @@
+package github
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func TestNeutralRepository(t *testing.T) {
+	created := time.Date(2021, 10, 1, 10, 0, 0, 0, time.UTC)
+	dependabot := DependabotAlert{}
+	dependabot.SecurityAdvisory.Severity = "high"
+	r := &Repository{
+		ID:     42,
+		Org:    "org",
+		Name:   "repo",
+		Detail: &github.Repository{DefaultBranch: github.String("main"), Fork: github.Bool(true), Visibility: github.String("internal")},
+		Releases: []*github.RepositoryRelease{{
+			TagName:   github.String("v1.0.0"),
+			CreatedAt: &github.Timestamp{Time: created},
+			Assets:    []*github.ReleaseAsset{{DownloadCount: github.Int(5)}, {DownloadCount: github.Int(7)}},
+		}},
+		PullRequests: []*github.PullRequest{{
+			Number:             github.Int(1),
+			User:               &github.User{Login: github.String("dev")},
+			RequestedReviewers: []*github.User{{Login: github.String("lead")}},
+			RequestedTeams:     []*github.Team{{Slug: github.String("team")}},
+		}},
+		Workflows: []*github.Workflow{},
+		Security: SecurityAlerts{
+			CodeScanning: scm.FeatureEnabled,
+			CodeScanningAlerts: []*github.Alert{
+				{Rule: &github.Rule{SecuritySeverityLevel: github.String("critical"), Severity: github.String("error")}},
+				{Rule: &github.Rule{Severity: github.String("warning")}},
+				{RuleSeverity: github.String("note")},
+			},
+			Dependabot:       scm.FeatureEnabled,
+			DependabotAlerts: []*DependabotAlert{&dependabot},
+		},
+		Protection: BranchProtection{
+			Status: scm.FeatureEnabled,
+			Settings: &github.Protection{
+				RequiredPullRequestReviews: &github.PullRequestReviewsEnforcement{RequiredApprovingReviewCount: 2},
+				AllowForcePushes:           &github.AllowForcePushes{Enabled: true},
+			},
+		},
+	}
+
+	neutral := NeutralRepository(r)
+
+	assert.Equal(t, "github", neutral.Provider)
+	assert.Equal(t, int64(42), neutral.ID)
+	assert.Equal(t, scm.Detail{DefaultBranch: "main", Fork: true, Visibility: "internal"}, neutral.Detail)
+	assert.Equal(t, []scm.Release{{Tag: "v1.0.0", Created: &created, Downloads: 12}}, neutral.Releases)
+	if assert.Len(t, neutral.PullRequests, 1) {
+		assert.Equal(t, "dev", neutral.PullRequests[0].Author)
+		assert.Equal(t, 2, neutral.PullRequests[0].RequestedReviewers)
+	}
+	assert.NotNil(t, neutral.Workflows)
+	assert.Empty(t, neutral.Workflows)
+	assert.Nil(t, neutral.Issues)
+	assert.Equal(t, []scm.SecurityAlert{{Severity: "critical"}, {Severity: "warning"}, {Severity: "note"}}, neutral.Security.CodeScanningAlerts)
+	assert.Equal(t, []scm.SecurityAlert{{Severity: "high"}}, neutral.Security.DependabotAlerts)
+	assert.Equal(t, scm.FeatureEnabled, neutral.Protection.Status)
+	assert.Equal(t, &scm.ProtectionSettings{RequiredApprovingReviewCount: 2, AllowForcePushes: true}, neutral.Protection.Settings)
+}
+
+func TestNeutralRepository_NoDetail(t *testing.T) {
+	neutral := NeutralRepository(&Repository{Org: "org", Name: "repo"})
+
+	assert.Equal(t, scm.Detail{}, neutral.Detail)
+	assert.Nil(t, neutral.Protection.Settings)
+	assert.Nil(t, neutral.Workflows)
+}
+
+func TestProvider_ListRepositories(t *testing.T) {
+	spy := &DataCollectorSpy{}
+	spy.repos = []Repository{{ID: 1, Org: "org", Name: "repo", Topics: []string{"java"}}}
+
+	repos, err := Provider{Collector: spy}.ListRepositories(context.Background(), "org", nil)
+
+	assert.NoError(t, err)
+	if assert.Len(t, repos, 1) {
+		assert.Equal(t, "github", repos[0].Provider)
+		assert.Equal(t, "repo", repos[0].Name)
+		assert.Equal(t, []string{"java"}, repos[0].Topics)
+	}
+}
+
+// collector answering with the repositories
+type DataCollectorSpy struct {
+	DataCollector
+	repos []Repository
+}
+
+func (s *DataCollectorSpy) ListRepositories(ctx context.Context, org string, changedAfter *time.Time) ([]Repository, error) {
+	return s.repos, nil
+}
@@ -0,0 +1,270 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+	headerRateResource  = "X-RateLimit-Resource"
+	headerRetryAfter    = "Retry-After"
+
+	// pause once the remaining budget for a resource drops to this level
+	defaultRateLimitThreshold = 10
+
+	// number of times a request rejected by a rate limit is retried
+	defaultRateLimitRetries = 3
+
+	// GitHub recommends waiting at least a minute on a secondary limit without a Retry-After
+	secondaryRateLimitWait = time.Minute
+
+	// padding added to reset times to allow for clock differences with GitHub
+	rateLimitResetBuffer = time.Second
+)
+
+// rateBudget remaining requests for a rate limit resource and when it resets
+type rateBudget struct {
+	remaining int
+	reset     time.Time
+}
+
+// rateLimitTransport http transport that tracks the GitHub rate limit budget, pausing until
+// the reset when the remaining budget runs low and retrying requests rejected by either the
+// primary or secondary (abuse) rate limits
+type rateLimitTransport struct {
+	base       http.RoundTripper
+	threshold  int
+	maxRetries int
+	now        func() time.Time
+	sleep      func(ctx context.Context, d time.Duration) error
+
+	mu      sync.Mutex
+	budgets map[string]rateBudget
+}
+
+// newRateLimitTransport builds a rate limit aware transport around the supplied transport
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:       base,
+		threshold:  defaultRateLimitThreshold,
+		maxRetries: defaultRateLimitRetries,
+		now:        time.Now,
+		sleep:      sleepContext,
+		budgets:    make(map[string]rateBudget),
+	}
+}
+
+// RoundTrip executes the request, waiting out the rate limit when necessary
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req.URL.Path)
+	for attempt := 0; ; attempt++ {
+		if err := t.waitForBudget(req.Context(), resource); err != nil {
+			return nil, err
+		}
+
+		attemptReq, err := rewindRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		resource = t.recordBudget(resource, resp)
+
+		wait, limited := t.limitedWait(resp)
+		if !limited {
+			// the client refuses to make calls once it has seen an exhausted budget, so
+			// hold the response until the reset rather than let the next call fail
+			if err := t.waitForExhaustedBudget(req.Context(), resource); err != nil {
+				drainAndClose(resp)
+				return nil, err
+			}
+			return resp, nil
+		}
+		if attempt >= t.maxRetries || (req.Body != nil && req.GetBody == nil) {
+			glog.Warningf("GitHub rate limit still exceeded for %s %s, giving up after %d attempts", req.Method, req.URL.Path, attempt+1)
+			return resp, nil
+		}
+		drainAndClose(resp)
+
+		glog.Warningf("GitHub rate limit exceeded for %s %s, waiting %s before retry %d of %d", req.Method, req.URL.Path, wait, attempt+1, t.maxRetries)
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// pause until the budget resets when the remaining budget is at or below the threshold
+func (t *rateLimitTransport) waitForBudget(ctx context.Context, resource string) error {
+	return t.waitIf(ctx, resource, func(b rateBudget) bool { return b.remaining <= t.threshold })
+}
+
+// pause until the budget resets when the budget has been completely used
+func (t *rateLimitTransport) waitForExhaustedBudget(ctx context.Context, resource string) error {
+	return t.waitIf(ctx, resource, func(b rateBudget) bool { return b.remaining <= 0 })
+}
+
+// pause until the budget for the resource resets when the supplied condition holds
+func (t *rateLimitTransport) waitIf(ctx context.Context, resource string, cond func(rateBudget) bool) error {
+	t.mu.Lock()
+	budget, found := t.budgets[resource]
+	t.mu.Unlock()
+	if !found || !cond(budget) {
+		return nil
+	}
+
+	wait := budget.reset.Sub(t.now()) + rateLimitResetBuffer
+	if wait <= 0 {
+		return nil
+	}
+
+	glog.Infof("GitHub %s rate limit low (%d remaining), pausing %s until reset at %s", resource, budget.remaining, wait.Round(time.Second), budget.reset)
+	if err := t.sleep(ctx, wait); err != nil {
+		return err
+	}
+
+	// budget has reset, forget it until the next response reports the new one
+	t.mu.Lock()
+	if current, found := t.budgets[resource]; found && current.reset.Equal(budget.reset) {
+		delete(t.budgets, resource)
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// record the rate limit budget reported on the response, returning the resource it applies to
+func (t *rateLimitTransport) recordBudget(resource string, resp *http.Response) string {
+	if r := resp.Header.Get(headerRateResource); r != "" {
+		resource = r
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get(headerRateRemaining))
+	if err != nil {
+		return resource
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64)
+	if err != nil {
+		return resource
+	}
+
+	glog.V(3).Infof("GitHub %s rate limit remaining: %d, resets at %s", resource, remaining, time.Unix(reset, 0))
+	t.mu.Lock()
+	t.budgets[resource] = rateBudget{remaining: remaining, reset: time.Unix(reset, 0)}
+	t.mu.Unlock()
+	return resource
+}
+
+// determine if the response was rejected by a rate limit and how long to wait before retrying
+func (t *rateLimitTransport) limitedWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// secondary limits tell us how long to back off
+	if v := resp.Header.Get(headerRetryAfter); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	// primary limit exhausted, wait for the reset
+	if resp.Header.Get(headerRateRemaining) == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64); err == nil {
+			wait := time.Unix(reset, 0).Sub(t.now()) + rateLimitResetBuffer
+			if wait < 0 {
+				wait = 0
+			}
+			return wait, true
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || isSecondaryRateLimit(resp) {
+		return secondaryRateLimitWait, true
+	}
+	return 0, false
+}
+
+// check the body of a forbidden response for the secondary rate limit message, leaving the body readable
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	closeQuietly(resp.Body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	msg := strings.ToLower(string(body))
+	return strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse")
+}
+
+// determine the rate limit resource a request counts against using its path
+func rateLimitResource(path string) string {
+	switch {
+	case strings.Contains(path, "/search/") || strings.HasPrefix(path, "search/"):
+		return "search"
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	default:
+		return "core"
+	}
+}
+
+// provide a request with a fresh body for the supplied attempt
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// read and close the response body so the connection can be reused
+func drainAndClose(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		glog.V(3).Infof("Problem draining response body: %s", err.Error())
+	}
+	closeQuietly(resp.Body)
+}
+
+// close the supplied resource, logging rather than failing when it can't be closed
+func closeQuietly(c io.Closer) {
+	if err := c.Close(); err != nil {
+		glog.V(3).Infof("Problem closing resource: %s", err.Error())
+	}
+}
+
+// sleep for the supplied duration, returning early when the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
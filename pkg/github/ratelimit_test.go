@@ -0,0 +1,259 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// builds a rate limit transport against the supplied handler that records sleeps instead of waiting
+func testRateLimitTransport(handler http.HandlerFunc) (*rateLimitTransport, *[]time.Duration, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	now := time.Unix(1600000000, 0)
+
+	var sleeps []time.Duration
+	t := newRateLimitTransport(http.DefaultTransport)
+	t.now = func() time.Time { return now }
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return t, &sleeps, server
+}
+
+func TestRateLimitTransport_NoLimitHeaders(t *testing.T) {
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 0, len(*sleeps))
+}
+
+func TestRateLimitTransport_PausesWhenBudgetLow(t *testing.T) {
+	reset := time.Unix(1600000000, 0).Add(time.Minute)
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateRemaining, "5")
+		w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+		w.Header().Set(headerRateResource, "core")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	_, err := client.Get(server.URL + "/repos/testorg/testrepo")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(*sleeps))
+
+	_, err = client.Get(server.URL + "/repos/testorg/testrepo")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*sleeps))
+	assert.Equal(t, time.Minute+rateLimitResetBuffer, (*sleeps)[0])
+}
+
+func TestRateLimitTransport_SeparateResourceBudgets(t *testing.T) {
+	reset := time.Unix(1600000000, 0).Add(time.Minute)
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateRemaining, "2")
+		w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+		w.Header().Set(headerRateResource, "search")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	_, err := client.Get(server.URL + "/search/repositories")
+	assert.NoError(t, err)
+
+	_, err = client.Get(server.URL + "/repos/testorg/testrepo")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(*sleeps))
+}
+
+func TestRateLimitTransport_ExhaustedBudgetHoldsResponse(t *testing.T) {
+	reset := time.Unix(1600000000, 0).Add(30 * time.Second)
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateRemaining, "0")
+		w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, len(*sleeps))
+	assert.Equal(t, 30*time.Second+rateLimitResetBuffer, (*sleeps)[0])
+}
+
+func TestRateLimitTransport_ExhaustedBudgetWaitCancelled(t *testing.T) {
+	reset := time.Unix(1600000000, 0).Add(30 * time.Second)
+	rlt, _, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateRemaining, "0")
+		w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+	rlt.sleep = func(ctx context.Context, d time.Duration) error {
+		return context.Canceled
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/repos/testorg/testrepo", nil)
+	assert.NoError(t, err)
+	resp, err := rlt.RoundTrip(req)
+
+	// the response is released rather than returned along with the error
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestRateLimitTransport_RetryAfter(t *testing.T) {
+	calls := 0
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set(headerRetryAfter, "17")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []time.Duration{17 * time.Second}, *sleeps)
+}
+
+func TestRateLimitTransport_PrimaryLimitExceeded(t *testing.T) {
+	reset := time.Unix(1600000000, 0).Add(2 * time.Minute)
+	calls := 0
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set(headerRateRemaining, "0")
+			w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2*time.Minute+rateLimitResetBuffer, (*sleeps)[0])
+}
+
+func TestRateLimitTransport_SecondaryLimitMessage(t *testing.T) {
+	calls := 0
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, `{"message":"You have exceeded a secondary rate limit."}`, http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []time.Duration{secondaryRateLimitWait}, *sleeps)
+}
+
+func TestRateLimitTransport_ForbiddenNotRateLimited(t *testing.T) {
+	calls := 0
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, `{"message":"Resource not accessible by integration"}`, http.StatusForbidden)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, len(*sleeps))
+}
+
+func TestRateLimitTransport_RetriesExhausted(t *testing.T) {
+	calls := 0
+	rlt, sleeps, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set(headerRetryAfter, "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer server.Close()
+
+	client := &http.Client{Transport: rlt}
+	resp, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, defaultRateLimitRetries+1, calls)
+	assert.Equal(t, defaultRateLimitRetries, len(*sleeps))
+}
+
+func TestRateLimitTransport_SleepCancelled(t *testing.T) {
+	rlt, _, server := testRateLimitTransport(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRetryAfter, "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer server.Close()
+	rlt.sleep = func(ctx context.Context, d time.Duration) error {
+		return context.Canceled
+	}
+
+	client := &http.Client{Transport: rlt}
+	_, err := client.Get(server.URL + "/repos/testorg/testrepo")
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestRateLimitResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"core", "/repos/testorg/testrepo", "core"},
+		{"search", "/search/repositories", "search"},
+		{"enterprise search", "/api/v3/search/repositories", "search"},
+		{"graphql", "/api/graphql", "graphql"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, rateLimitResource(tt.path))
+		})
+	}
+}
@@ -3,13 +3,36 @@ package github
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	gogithub "github.com/google/go-github/v39/github"
 	"golang.org/x/sync/errgroup"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// defaultPageLimit number of pages collected for a resource when no limit is configured
+const defaultPageLimit = 10
+
+// reviewConcurrency number of pull requests whose reviews are retrieved at the same time, kept low to stay clear
+// of the GitHub secondary rate limits
+const reviewConcurrency = 4
+
+// default number of attempts and delay between attempts while GitHub computes contributor statistics
+const (
+	defaultStatsAttempts = 5
+	defaultStatsDelay    = 2 * time.Second
 )
 
+// StatsRetry number of attempts and delay between attempts made to collect contributor statistics while
+// GitHub computes them (202 Accepted), the defaults of 5 attempts 2 seconds apart are used when zero
+type StatsRetry struct {
+	Attempts int
+	Delay    time.Duration
+}
+
 // Repository represents the minimal repository identifiers
 type Repository struct {
 	ID           int64
@@ -21,49 +44,122 @@ type Repository struct {
 	Branches     []*gogithub.Branch
 	Releases     []*gogithub.RepositoryRelease
 	PullRequests []*gogithub.PullRequest
+	// Reviews submitted on the collected pull requests by pull request number
+	Reviews      map[int][]*gogithub.PullRequestReview
 	Contributors []*gogithub.ContributorStats
 	Languages    map[string]int
+	// Workflows defined for GitHub Actions, nil when not collected, along with the recent runs
+	Workflows    []*gogithub.Workflow
+	WorkflowRuns []*gogithub.WorkflowRun
+	Security     SecurityAlerts
+	// Deployments created since the collection option along with their statuses by deployment ID
+	Deployments        []*gogithub.Deployment
+	DeploymentStatuses map[int64][]*gogithub.DeploymentStatus
+	// Issues, nil when not collected, along with their comments by issue number
+	Issues        []*gogithub.Issue
+	IssueComments map[int][]*gogithub.IssueComment
+	// HygieneFiles presence of the governance files by name, nil when not collected
+	HygieneFiles map[string]bool
+	// BranchActivity of the branches other than the default branch by name, nil when not collected
+	BranchActivity map[string]scm.BranchActivity
+	// Protection settings of the default branch
+	Protection BranchProtection
+	Truncated  scm.Truncated
+	// ContributorsPending set when contributor statistics weren't available after retrying
+	ContributorsPending bool
+	// PullRequestsSince set when only pull requests updated since the time were collected
+	PullRequestsSince *time.Time
+	// SectionErrors errors of the sections that failed to collect by section, nil when every section was collected
+	SectionErrors map[string]error
+}
+
+// BranchProtection protection settings of a branch along with whether the branch is protected, the settings
+// are only available when the status is enabled
+type BranchProtection struct {
+	Status   scm.FeatureStatus
+	Settings *gogithub.Protection
 }
 
 // DataCollector defines methods for repository management
 type DataCollector interface {
-	ListRepositories(org string, changedAfter *time.Time) ([]Repository, error)
-	GetRepository(org string, name string) (*Repository, error)
-	GetBranches(org string, repo string) ([]*gogithub.Branch, error)
-	GetReleases(org string, repo string) ([]*gogithub.RepositoryRelease, error)
+	ListOrganizations(ctx context.Context) ([]string, error)
+	ListRepositories(ctx context.Context, org string, changedAfter *time.Time) ([]Repository, error)
+	GetRepository(ctx context.Context, org string, name string, opts scm.CollectOptions) (*Repository, error)
+	GetBranches(ctx context.Context, org string, repo string) (branches []*gogithub.Branch, truncated bool, err error)
+	GetReleases(ctx context.Context, org string, repo string) (releases []*gogithub.RepositoryRelease, truncated bool, err error)
 }
 
 // RepositoryDataCollector used to collect data from git hub repositories
 type RepositoryDataCollector struct {
 	GitHubClient *gogithub.Client
+	PageLimits   scm.PageLimits
+	StatsRetry   StatsRetry
+	// UserOwners set when repository owners are user accounts, otherwise detected when not an organization
+	UserOwners bool
+	// SearchDiscovery set to discover changed repositories using the search API, falling back to listing the
+	// repositories when the search fails
+	SearchDiscovery bool
+}
+
+// ListRepositories retrieves the set of repositories for an organization, or for a user account when the owner
+// isn't an organization
+func (m RepositoryDataCollector) ListRepositories(ctx context.Context, org string, changedAfter *time.Time) ([]Repository, error) {
+	listing := listOrgRepos
+	if m.UserOwners {
+		listing = m.userListing(ctx, org)
+	}
+	repos, err := m.listRepositories(ctx, org, listing, changedAfter)
+
+	// names that aren't organizations may be user accounts
+	if listing == listOrgRepos && isNotFound(err) {
+		user, userErr := m.isUser(ctx, org)
+		if userErr != nil || !user {
+			return nil, err
+		}
+		glog.Infof("Owner %s is a user account, collecting repositories owned by the user", org)
+		return m.listRepositories(ctx, org, m.userListing(ctx, org), changedAfter)
+	}
+	return repos, err
 }
 
-// ListRepositories retrieves the set of repositories for an organization
-func (m RepositoryDataCollector) ListRepositories(org string, changedAfter *time.Time) ([]Repository, error) {
+// retrieves the set of repositories for the owner using the listing
+func (m RepositoryDataCollector) listRepositories(ctx context.Context, org string, listing ownerListing, changedAfter *time.Time) ([]Repository, error) {
 	// if no change after timestamp supplied, just return all repos using created sort
 	if changedAfter == nil {
 		glog.Infof("Collecting all repositories for org %s", org)
-		return m.listByOrgAndSort(org, "created", nil)
+		return m.listByOrgAndSort(ctx, org, listing, "created", nil)
+	}
+
+	// the search API finds the changed repositories without listing the others
+	if m.SearchDiscovery {
+		repos, err := m.searchChanged(ctx, org, listing, *changedAfter)
+		if err == nil {
+			return repos, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		glog.Warningf("Problem searching for changed repositories in org %s, listing repositories instead: %s", org, err)
 	}
 
 	// changed after timestamp has been supplied.  GitHub API can list repositories by created, updated, or pushed
 	// timestamps but unfortunately can't list by the lastest of those timestamps.  To simulate that, we will merge
 	// the results of getting repositories using each of those sort options.
 	glog.Infof("Collecting repositories for org %s UPDATED after %s", org, changedAfter)
-	repos, err := m.listByOrgAndSort(org, "updated", changedAfter)
+	repos, err := m.listByOrgAndSort(ctx, org, listing, "updated", changedAfter)
 	if err != nil {
 		return nil, err
 	}
 
 	glog.Infof("Collecting repositories for org %s PUSHED after %s", org, changedAfter)
-	pushedRepos, err := m.listByOrgAndSort(org, "pushed", changedAfter)
+	pushedRepos, err := m.listByOrgAndSort(ctx, org, listing, "pushed", changedAfter)
 	if err != nil {
 		return nil, err
 	}
 	repos = dedupAndMerge(repos, pushedRepos)
 
 	glog.Infof("Collecting repositories for org %s CREATED after %s", org, changedAfter)
-	createdRepos, err := m.listByOrgAndSort(org, "created", changedAfter)
+	createdRepos, err := m.listByOrgAndSort(ctx, org, listing, "created", changedAfter)
 	if err != nil {
 		return nil, err
 	}
@@ -73,101 +169,202 @@ func (m RepositoryDataCollector) ListRepositories(org string, changedAfter *time
 }
 
 // GetRepository retrieves the repository information by organization/name
-func (m RepositoryDataCollector) GetRepository(org string, name string) (*Repository, error) {
-	// retrieve data from the github using 3 goroutines to...
-	//  a. pull the base repository data
-	//  b. pull branch information
-	//  c. pull release information
-	grp, ctx := errgroup.WithContext(context.Background())
+func (m RepositoryDataCollector) GetRepository(ctx context.Context, org string, name string, opts scm.CollectOptions) (*Repository, error) {
+	// retrieve data from the github using goroutines to pull the base repository data along with each
+	// section, only the base repository data is required as a failed section is recorded against the repository
+	callerCtx := ctx
+	grp, ctx := errgroup.WithContext(ctx)
+	failures := &scm.SectionFailures{Org: org, Name: name}
 
 	var ghRepo *gogithub.Repository
+	var protection BranchProtection
 	grp.Go(func() error {
 		r, _, err := m.GitHubClient.Repositories.Get(ctx, org, name)
-		if err == nil {
-			ghRepo = r
+		if err != nil {
+			return err
+		}
+		ghRepo = r
+		if protection, err = m.collectBranchProtection(ctx, org, name, r.GetDefaultBranch()); err != nil {
+			failures.Fail(scm.SectionProtection, err)
 		}
-		return err
+		return nil
 	})
 
+	var truncated scm.Truncated
 	var branches []*gogithub.Branch
 	grp.Go(func() error {
-		b, err := m.GetBranches(org, name)
-		if err == nil {
-			branches = b
+		b, t, err := m.GetBranches(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionBranches, err)
+			return nil
 		}
-		return err
+		branches = b
+		truncated.Branches = t
+		return nil
 	})
 
 	var releases []*gogithub.RepositoryRelease
 	grp.Go(func() error {
-		r, err := m.GetReleases(org, name)
-		if err == nil {
-			releases = r
+		r, t, err := m.GetReleases(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionReleases, err)
+			return nil
 		}
-		return err
+		releases = r
+		truncated.Releases = t
+		return nil
 	})
 
 	var pullRequests []*gogithub.PullRequest
+	var reviews map[int][]*gogithub.PullRequestReview
 	grp.Go(func() error {
-		p, err := m.GetPullRequests(org, name)
-		if err == nil {
-			pullRequests = p
+		p, t, err := m.GetPullRequests(ctx, org, name, opts.PullRequestsSince)
+		if err != nil {
+			failures.Fail(scm.SectionPullRequests, err)
+			return nil
+		}
+		pullRequests = p
+		truncated.PullRequests = t
+		if reviews, err = m.GetReviews(ctx, org, name, p); err != nil {
+			failures.Fail(scm.SectionReviews, err)
 		}
-		return err
+		return nil
 	})
 
 	var languages map[string]int
 	grp.Go(func() error {
-		l, err := m.GetLanguages(org, name)
-		if err == nil {
-			languages = l
+		l, err := m.GetLanguages(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionLanguages, err)
+			return nil
 		}
-		return err
+		languages = l
+		return nil
 	})
 
 	var topics []string
 	grp.Go(func() error {
-		t, err := m.GetTopics(org, name)
-		if err == nil {
-			topics = t
+		t, err := m.GetTopics(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionTopics, err)
+			return nil
 		}
-		return err
+		topics = t
+		return nil
 	})
 
 	var contributors []*gogithub.ContributorStats
+	var contributorsPending bool
 	grp.Go(func() error {
-		c, err := m.GetContributorStats(org, name)
-		if err == nil {
-			contributors = c
+		c, pending, err := m.GetContributorStats(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionContributors, err)
+			return nil
+		}
+		contributors = c
+		contributorsPending = pending
+		return nil
+	})
+
+	var workflows []*gogithub.Workflow
+	var workflowRuns []*gogithub.WorkflowRun
+	grp.Go(func() error {
+		var err error
+		if workflows, workflowRuns, truncated.WorkflowRuns, err = m.collectWorkflows(ctx, org, name); err != nil {
+			failures.Fail(scm.SectionWorkflows, err)
+		}
+		return nil
+	})
+
+	var security SecurityAlerts
+	grp.Go(func() error {
+		var err error
+		if security, truncated.SecurityAlerts, err = m.collectSecurityAlerts(ctx, org, name); err != nil {
+			failures.Fail(scm.SectionSecurity, err)
+		}
+		return nil
+	})
+
+	var deployments []*gogithub.Deployment
+	var deploymentStatuses map[int64][]*gogithub.DeploymentStatus
+	grp.Go(func() error {
+		var err error
+		if deployments, deploymentStatuses, truncated.Deployments, err = m.collectDeployments(ctx, org, name, opts.DeploymentsSince); err != nil {
+			failures.Fail(scm.SectionDeployments, err)
+		}
+		return nil
+	})
+
+	var issues []*gogithub.Issue
+	var issueComments map[int][]*gogithub.IssueComment
+	grp.Go(func() error {
+		var err error
+		if issues, issueComments, truncated.Issues, err = m.collectIssues(ctx, org, name); err != nil {
+			failures.Fail(scm.SectionIssues, err)
 		}
-		return err
+		return nil
+	})
+
+	var hygieneFiles map[string]bool
+	grp.Go(func() error {
+		var err error
+		if hygieneFiles, err = m.collectHygieneFiles(ctx, org, name, opts.HygieneFiles); err != nil {
+			failures.Fail(scm.SectionHygiene, err)
+		}
+		return nil
 	})
 
 	if err := grp.Wait(); err != nil {
 		return nil, err
 	}
 
+	// sections fail when the caller gives up, report that rather than a repository missing its sections
+	if err := callerCtx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the branch activity is compared with the default branch so is collected once both are known, using the
+	// caller context as the group context is cancelled once the group finishes
+	branchActivity, err := m.collectBranchActivity(callerCtx, org, name, ghRepo.GetDefaultBranch(), branches)
+	if err != nil {
+		failures.Fail(scm.SectionBranchActivity, err)
+	}
+
 	// Build repository output
 	return &Repository{
-		ID:           *ghRepo.ID,
-		Org:          org,
-		Name:         name,
-		Topics:       topics,
-		Changed:      extractLastChangeTS(ghRepo),
-		Detail:       ghRepo,
-		Branches:     branches,
-		Releases:     releases,
-		PullRequests: pullRequests,
-		Languages:    languages,
-		Contributors: contributors,
+		ID:                  *ghRepo.ID,
+		Org:                 org,
+		Name:                name,
+		Topics:              topics,
+		Changed:             extractLastChangeTS(ghRepo),
+		Detail:              ghRepo,
+		Branches:            branches,
+		BranchActivity:      branchActivity,
+		Releases:            releases,
+		PullRequests:        pullRequests,
+		Reviews:             reviews,
+		Languages:           languages,
+		Contributors:        contributors,
+		ContributorsPending: contributorsPending,
+		Workflows:           workflows,
+		WorkflowRuns:        workflowRuns,
+		Security:            security,
+		Deployments:         deployments,
+		DeploymentStatuses:  deploymentStatuses,
+		Issues:              issues,
+		IssueComments:       issueComments,
+		HygieneFiles:        hygieneFiles,
+		Protection:          protection,
+		Truncated:           truncated,
+		PullRequestsSince:   opts.PullRequestsSince,
+		SectionErrors:       failures.Failed(),
 	}, nil
 }
 
-// GetBranches retrieves branch information by organization/repo
-func (m RepositoryDataCollector) GetBranches(org string, repo string) ([]*gogithub.Branch, error) {
-	// build context and options for branch call...maximum of 100
+// GetBranches retrieves branch information by organization/repo, flagging when truncated by the page limit
+func (m RepositoryDataCollector) GetBranches(ctx context.Context, org string, repo string) ([]*gogithub.Branch, bool, error) {
+	// build options for branch call...maximum of 100
 	// branches per page so going with that for now
-	ctx := context.Background()
 	opt := &gogithub.BranchListOptions{
 		ListOptions: gogithub.ListOptions{PerPage: 100},
 	}
@@ -176,17 +373,17 @@ func (m RepositoryDataCollector) GetBranches(org string, repo string) ([]*gogith
 	var loopCnt = 0
 	var allBranches []*gogithub.Branch
 	for {
-		// sanity check to stop looking for branches if we hit 1000 (10 pages)
+		// stop looking for branches once the page limit is hit
 		loopCnt++
-		if loopCnt > 10 {
-			glog.Warningf("Repository has more than 1000 branches: %s/%s", org, repo)
-			break
+		if pageLimitReached(m.PageLimits.Branches, loopCnt) {
+			glog.Warningf("Repository has more than %d branches: %s/%s", len(allBranches), org, repo)
+			return allBranches, true, nil
 		}
 
 		glog.V(2).Infof("Collecting branches for %s/%s, count per page = %d, page number = %d", org, repo, opt.ListOptions.PerPage, opt.Page)
 		branches, resp, err := m.GitHubClient.Repositories.ListBranches(ctx, org, repo, opt)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		if glog.V(3) {
@@ -203,31 +400,30 @@ func (m RepositoryDataCollector) GetBranches(org string, repo string) ([]*gogith
 		}
 		opt.Page = resp.NextPage
 	}
-	return allBranches, nil
+	return allBranches, false, nil
 }
 
-// GetReleases retrieves release information by organization/repo
-func (m RepositoryDataCollector) GetReleases(org string, repo string) ([]*gogithub.RepositoryRelease, error) {
-	// build context and options for release call...maximum of 100
+// GetReleases retrieves release information by organization/repo, flagging when truncated by the page limit
+func (m RepositoryDataCollector) GetReleases(ctx context.Context, org string, repo string) ([]*gogithub.RepositoryRelease, bool, error) {
+	// build options for release call...maximum of 100
 	// releases per page so going with that for now
-	ctx := context.Background()
 	opt := &gogithub.ListOptions{PerPage: 100}
 
 	// process all pages until finished
 	var loopCnt = 0
 	var allReleases []*gogithub.RepositoryRelease
 	for {
-		// sanity check to stop looking for branches if we hit 1000 (10 pages)
+		// stop looking for releases once the page limit is hit
 		loopCnt++
-		if loopCnt > 10 {
-			glog.Warningf("Repository has more than 1000 releases: %s/%s", org, repo)
-			break
+		if pageLimitReached(m.PageLimits.Releases, loopCnt) {
+			glog.Warningf("Repository has more than %d releases: %s/%s", len(allReleases), org, repo)
+			return allReleases, true, nil
 		}
 
 		glog.V(2).Infof("Collecting releases for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
 		releases, resp, err := m.GitHubClient.Repositories.ListReleases(ctx, org, repo, opt)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		if glog.V(3) {
@@ -244,34 +440,41 @@ func (m RepositoryDataCollector) GetReleases(org string, repo string) ([]*gogith
 		}
 		opt.Page = resp.NextPage
 	}
-	return allReleases, nil
+	return allReleases, false, nil
 }
 
-// GetPullRequests retrieves pull requests by organization/repo
-func (m RepositoryDataCollector) GetPullRequests(org string, repo string) ([]*gogithub.PullRequest, error) {
-	// build context and options for release call...maximum of 100
+// GetPullRequests retrieves pull requests by organization/repo, flagging when truncated by the page limit.
+// When a since time is supplied, only pull requests updated since then are retrieved.
+func (m RepositoryDataCollector) GetPullRequests(ctx context.Context, org string, repo string, since *time.Time) ([]*gogithub.PullRequest, bool, error) {
+	// build options for release call...maximum of 100
 	// pull requests per page so going with that for now
-	ctx := context.Background()
 	opt := &gogithub.PullRequestListOptions{
 		State:       "all",
 		ListOptions: gogithub.ListOptions{PerPage: 100},
 	}
 
+	// sort by most recently updated when collecting changes so paging can stop at the first older request
+	if since != nil {
+		glog.V(2).Infof("Collecting pull requests for %s/%s updated since %s", org, repo, since)
+		opt.Sort = "updated"
+		opt.Direction = "desc"
+	}
+
 	// process all pages until finished
 	var loopCnt = 0
 	var allPullRequests []*gogithub.PullRequest
 	for {
-		// sanity check to stop looking for requests if we hit 1000 (10 pages)
+		// stop looking for requests once the page limit is hit
 		loopCnt++
-		if loopCnt > 10 {
-			glog.Warningf("Repository has more than 1000 pull requests: %s/%s", org, repo)
-			break
+		if pageLimitReached(m.PageLimits.PullRequests, loopCnt) {
+			glog.Warningf("Repository has more than %d pull requests: %s/%s", len(allPullRequests), org, repo)
+			return allPullRequests, true, nil
 		}
 
 		glog.V(2).Infof("Collecting pull requests for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
 		pullRequests, resp, err := m.GitHubClient.PullRequests.List(ctx, org, repo, opt)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		if glog.V(3) {
@@ -281,19 +484,23 @@ func (m RepositoryDataCollector) GetPullRequests(org string, repo string) ([]*go
 			}
 		}
 
-		allPullRequests = append(allPullRequests, pullRequests...)
+		for _, pr := range pullRequests {
+			if since != nil && pr.UpdatedAt != nil && pr.UpdatedAt.Before(*since) {
+				return allPullRequests, false, nil
+			}
+			allPullRequests = append(allPullRequests, pr)
+		}
 
 		if resp.NextPage == 0 {
 			break
 		}
 		opt.Page = resp.NextPage
 	}
-	return allPullRequests, nil
+	return allPullRequests, false, nil
 }
 
 // GetLanguages retrieves language usage by organization/repo
-func (m RepositoryDataCollector) GetLanguages(org string, repo string) (map[string]int, error) {
-	ctx := context.Background()
+func (m RepositoryDataCollector) GetLanguages(ctx context.Context, org string, repo string) (map[string]int, error) {
 	glog.V(2).Infof("Collecting languages for %s/%s", org, repo)
 	languages, _, err := m.GitHubClient.Repositories.ListLanguages(ctx, org, repo)
 	if err != nil {
@@ -303,8 +510,7 @@ func (m RepositoryDataCollector) GetLanguages(org string, repo string) (map[stri
 }
 
 // GetTopics retrieves topics by organization/repo
-func (m RepositoryDataCollector) GetTopics(org string, repo string) ([]string, error) {
-	ctx := context.Background()
+func (m RepositoryDataCollector) GetTopics(ctx context.Context, org string, repo string) ([]string, error) {
 	glog.V(2).Infof("Collecting topics for %s/%s", org, repo)
 	topics, _, err := m.GitHubClient.Repositories.ListAllTopics(ctx, org, repo)
 	if err != nil {
@@ -313,27 +519,120 @@ func (m RepositoryDataCollector) GetTopics(org string, repo string) ([]string, e
 	return topics, nil
 }
 
-// GetContributorStats retrieves contributor stats by organization/repo
-func (m RepositoryDataCollector) GetContributorStats(org string, repo string) ([]*gogithub.ContributorStats, error) {
-	ctx := context.Background()
-	glog.V(2).Infof("Collecting contributors for %s/%s", org, repo)
-	contributors, _, err := m.GitHubClient.Repositories.ListContributorsStats(ctx, org, repo)
+// GetReviews retrieves the reviews submitted on each of the supplied pull requests by pull request number, the
+// reviews of several pull requests being retrieved at the same time
+func (m RepositoryDataCollector) GetReviews(ctx context.Context, org string, repo string, pullRequests []*gogithub.PullRequest) (map[int][]*gogithub.PullRequestReview, error) {
+	var mu sync.Mutex
+	reviews := make(map[int][]*gogithub.PullRequestReview)
+	grp, ctx := errgroup.WithContext(ctx)
+	slots := make(chan struct{}, reviewConcurrency)
+	for _, pr := range pullRequests {
+		number := pr.GetNumber()
+		slots <- struct{}{}
+		grp.Go(func() error {
+			defer func() { <-slots }()
+			prReviews, err := m.listReviews(ctx, org, repo, number)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			reviews[number] = prReviews
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := grp.Wait(); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// retrieves every page of the reviews submitted on the pull request
+func (m RepositoryDataCollector) listReviews(ctx context.Context, org string, repo string, number int) ([]*gogithub.PullRequestReview, error) {
+	var reviews []*gogithub.PullRequestReview
+	opt := &gogithub.ListOptions{PerPage: 100}
+	for {
+		glog.V(3).Infof("Collecting reviews for %s/%s#%d, page number = %d", org, repo, number, opt.Page)
+		prReviews, resp, err := m.GitHubClient.PullRequests.ListReviews(ctx, org, repo, number, opt)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, prReviews...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return reviews, nil
+}
+
+// GetBranchProtection retrieves the protection settings of the branch by organization/repo along with whether
+// the branch is protected
+func (m RepositoryDataCollector) GetBranchProtection(ctx context.Context, org string, repo string, branch string) (*gogithub.Protection, scm.FeatureStatus, error) {
+	glog.V(2).Infof("Collecting branch protection for %s/%s branch %s", org, repo, branch)
+	protection, _, err := m.GitHubClient.Repositories.GetBranchProtection(ctx, org, repo, branch)
 	if err != nil {
-		glog.Warning("Error collecting contributors: ", err)
+		return nil, featureStatus(err), err
+	}
+	return protection, scm.FeatureEnabled, nil
+}
+
+// collect the branch protection, reading the settings requires admin access so they may not be available.  The
+// error is returned when the status couldn't be determined.
+func (m RepositoryDataCollector) collectBranchProtection(ctx context.Context, org string, repo string, branch string) (BranchProtection, error) {
+	if branch == "" {
+		return BranchProtection{Status: scm.FeatureUnknown}, nil
+	}
+	settings, status, err := m.GetBranchProtection(ctx, org, repo, branch)
+	if err != nil && status == scm.FeatureUnknown {
+		return BranchProtection{Status: status}, err
+	}
+	return BranchProtection{Status: status, Settings: settings}, nil
+}
+
+// GetContributorStats retrieves contributor stats by organization/repo, retrying while GitHub computes the
+// statistics.  Pending is returned when GitHub is still computing the statistics after retrying so the count isn't
+// mistaken for zero.
+func (m RepositoryDataCollector) GetContributorStats(ctx context.Context, org string, repo string) (contributors []*gogithub.ContributorStats, pending bool, err error) {
+	attempts, delay := m.StatsRetry.Attempts, m.StatsRetry.Delay
+	if attempts <= 0 {
+		attempts = defaultStatsAttempts
+	}
+	if delay <= 0 {
+		delay = defaultStatsDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		glog.V(2).Infof("Collecting contributors for %s/%s, attempt = %d", org, repo, attempt)
+		contributors, _, err = m.GitHubClient.Repositories.ListContributorsStats(ctx, org, repo)
+		if err == nil {
+			return contributors, false, nil
+		}
+
+		var accepted *gogithub.AcceptedError
+		if !errors.As(err, &accepted) {
+			return nil, false, err
+		}
+		if attempt >= attempts {
+			glog.Warningf("Contributor statistics not ready after %d attempts: %s/%s", attempts, org, repo)
+			return nil, true, nil
+		}
+
+		// wait for GitHub to compute the statistics before asking again
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(delay):
+		}
 	}
-	return contributors, nil
 }
 
 // find repositories change after supplied time using supplied sort (updated, pushed, or created)
-func (m RepositoryDataCollector) listByOrgAndSort(org string, sort string, changedAfter *time.Time) ([]Repository, error) {
-	// build context and options for repository call...maximum of 100
+func (m RepositoryDataCollector) listByOrgAndSort(ctx context.Context, org string, listing ownerListing, sort string, changedAfter *time.Time) ([]Repository, error) {
+	// build options for repository call...maximum of 100
 	// repositories per page so going with that for now
-	ctx := context.Background()
-	opt := &gogithub.RepositoryListByOrgOptions{
-		Sort:        sort,
-		Direction:   "desc",
-		ListOptions: gogithub.ListOptions{Page: 1, PerPage: 100},
-	}
+	opt := &gogithub.ListOptions{Page: 1, PerPage: 100}
 
 	// process all pages until finished
 	var loopCnt = 0
@@ -348,9 +647,9 @@ func (m RepositoryDataCollector) listByOrgAndSort(org string, sort string, chang
 		if !glog.V(2) {
 			glog.Infof("...")
 		}
-		glog.V(2).Infof("Collecting repositories using sort %s, count per page = %d, page number = %d", sort, opt.ListOptions.PerPage, opt.Page)
+		glog.V(2).Infof("Collecting repositories using sort %s, count per page = %d, page number = %d", sort, opt.PerPage, opt.Page)
 
-		repos, resp, err := m.GitHubClient.Repositories.ListByOrg(ctx, org, opt)
+		repos, resp, err := m.listPage(ctx, org, listing, sort, *opt)
 		if err != nil {
 			return nil, err
 		}
@@ -369,12 +668,7 @@ func (m RepositoryDataCollector) listByOrgAndSort(org string, sort string, chang
 				return allRepos, nil
 			}
 
-			allRepos = append(allRepos, Repository{
-				ID:      *repository.ID,
-				Org:     org,
-				Name:    *repository.Name,
-				Changed: changed,
-			})
+			allRepos = append(allRepos, newListedRepository(org, repository))
 		}
 
 		if resp.NextPage == 0 {
@@ -385,6 +679,14 @@ func (m RepositoryDataCollector) listByOrgAndSort(org string, sort string, chang
 	return allRepos, nil
 }
 
+// determine if the page about to be collected is beyond the supplied page limit
+func pageLimitReached(limit int, page int) bool {
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	return limit > 0 && page > limit
+}
+
 // extract the latest modification timestamp for the repository
 func extractLastChangeTS(r *gogithub.Repository) *time.Time {
 	return latest(
@@ -420,18 +722,27 @@ func extractTime(t *gogithub.Timestamp) *time.Time {
 // append additions (based on repository ID) not already in the original slice
 func dedupAndMerge(orig []Repository, additions []Repository) []Repository {
 	repos := orig
+	found := make(map[int64]bool, len(orig))
+	for _, r := range orig {
+		found[r.ID] = true
+	}
 	for _, a := range additions {
-		add := true
-		for _, r := range repos {
-			if a.ID == r.ID {
-				add = false
-				break
-			}
-		}
-
-		if add {
+		if !found[a.ID] {
+			found[a.ID] = true
 			repos = append(repos, a)
 		}
 	}
 	return repos
 }
+
+// repository found when listing or searching the repositories of the owner
+func newListedRepository(org string, repository *gogithub.Repository) Repository {
+	return Repository{
+		ID:      repository.GetID(),
+		Org:     org,
+		Name:    repository.GetName(),
+		Topics:  repository.Topics,
+		Changed: extractLastChangeTS(repository),
+		Detail:  repository,
+	}
+}
@@ -1,15 +1,21 @@
 package github
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/go-github/v39/github"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
 )
 
 func init() {
@@ -42,7 +48,7 @@ func TestListRepositories(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repos, err := m.ListRepositories("testorg", nil)
+	repos, err := m.ListRepositories(context.Background(), "testorg", nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(repos))
@@ -90,7 +96,7 @@ func TestListRepositories_WithChangedAfterTimestamp(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repos, err := m.ListRepositories("testorg", &threeHourAgo)
+	repos, err := m.ListRepositories(context.Background(), "testorg", &threeHourAgo)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 3, len(repos))
@@ -142,7 +148,7 @@ func TestListRepositories_MultiplePages(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repos, err := m.ListRepositories("testorg", nil)
+	repos, err := m.ListRepositories(context.Background(), "testorg", nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, len(repos))
@@ -185,7 +191,7 @@ func TestListRepositories_ExceedPageLimit(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repos, err := m.ListRepositories("testorg", nil)
+	repos, err := m.ListRepositories(context.Background(), "testorg", nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, repos)
@@ -208,7 +214,7 @@ func TestListRepositories_APIError(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repos, err := m.ListRepositories("testorg", nil)
+	repos, err := m.ListRepositories(context.Background(), "testorg", nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, repos)
@@ -218,8 +224,26 @@ type TestRepositoryTopic struct {
 	Names []string
 }
 
+// mocked endpoints of the optional repository features, each of them not enabled for the repository
+func featuresNotEnabled(t *testing.T, options ...mock.MockBackendOption) []mock.MockBackendOption {
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeGitHubError(t, w, http.StatusNotFound, "Not Found")
+	})
+	return append(options,
+		mock.WithRequestMatchHandler(mock.GetReposActionsWorkflowsByOwnerByRepo, notFound),
+		mock.WithRequestMatchHandler(mock.GetReposCodeScanningAlertsByOwnerByRepo, notFound),
+		mock.WithRequestMatchHandler(getDependabotAlerts, notFound),
+		mock.WithRequestMatchHandler(
+			mock.GetReposIssuesByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusGone, "Issues are disabled for this repo")
+			}),
+		),
+	)
+}
+
 func TestGetRepository(t *testing.T) {
-	mockedHTTPClient := mock.NewMockedHTTPClient(
+	mockedHTTPClient := mock.NewMockedHTTPClient(featuresNotEnabled(t,
 		mock.WithRequestMatchPages(
 			mock.GetReposByOwnerByRepo,
 			github.Repository{
@@ -266,14 +290,28 @@ func TestGetRepository(t *testing.T) {
 			mock.GetReposPullsByOwnerByRepo,
 			[]github.PullRequest{
 				{
-					Title: github.String("pr1"),
+					Number: github.Int(1),
+					Title:  github.String("pr1"),
 				},
 				{
-					Title: github.String("pr2"),
+					Number: github.Int(2),
+					Title:  github.String("pr2"),
 				},
 			},
 			[]github.PullRequest{},
 		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// reviews are retrieved concurrently, so answer by the pull request in the path
+				reviews := []github.PullRequestReview{}
+				if strings.HasSuffix(r.URL.Path, "/pulls/1/reviews") {
+					reviews = append(reviews, github.PullRequestReview{ID: github.Int64(11), State: github.String("APPROVED")})
+				}
+				_, err := w.Write(mock.MustMarshal(reviews))
+				assert.NoError(t, err)
+			}),
+		),
 		mock.WithRequestMatch(
 			mock.GetReposStatsContributorsByOwnerByRepo,
 			[]github.ContributorStats{
@@ -285,12 +323,12 @@ func TestGetRepository(t *testing.T) {
 				},
 			},
 		),
-	)
+	)...)
 
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repo, err := m.GetRepository("testorg", "testrepo")
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, repo)
@@ -308,6 +346,10 @@ func TestGetRepository(t *testing.T) {
 	assert.Equal(t, 2, len(repo.PullRequests))
 	assert.Equal(t, "pr1", *repo.PullRequests[0].Title)
 	assert.Equal(t, "pr2", *repo.PullRequests[1].Title)
+	if assert.Equal(t, 1, len(repo.Reviews[1])) {
+		assert.Equal(t, "APPROVED", repo.Reviews[1][0].GetState())
+	}
+	assert.Equal(t, 0, len(repo.Reviews[2]))
 	assert.Equal(t, 2, len(repo.Topics))
 	assert.Equal(t, "topic1", repo.Topics[0])
 	assert.Equal(t, "topic2", repo.Topics[1])
@@ -315,6 +357,71 @@ func TestGetRepository(t *testing.T) {
 	assert.Equal(t, 5000, repo.Languages["Go"])
 	assert.Equal(t, 2000, repo.Languages["Bash"])
 	assert.Equal(t, 2, len(repo.Contributors))
+	assert.False(t, repo.ContributorsPending)
+	assert.Nil(t, repo.SectionErrors)
+}
+
+func TestGetReviews_Concurrent(t *testing.T) {
+	var running, maxRunning int32
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+
+				// the pull request number precedes the reviews in the path
+				parts := strings.Split(r.URL.Path, "/")
+				number := parts[len(parts)-2]
+				_, err := w.Write([]byte(`[{"id": ` + number + `, "state": "APPROVED"}]`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	var prs []*github.PullRequest
+	for i := 1; i <= 10; i++ {
+		prs = append(prs, &github.PullRequest{Number: github.Int(i)})
+	}
+	reviews, err := m.GetReviews(context.Background(), "testorg", "testrepo", prs)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(reviews))
+	for i := 1; i <= 10; i++ {
+		if assert.Equal(t, 1, len(reviews[i])) {
+			assert.Equal(t, int64(i), reviews[i][0].GetID())
+		}
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(reviewConcurrency))
+}
+
+func TestGetReviews_Error(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusInternalServerError, "github went belly up or something")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	reviews, err := m.GetReviews(context.Background(), "testorg", "testrepo", []*github.PullRequest{{Number: github.Int(1)}, {Number: github.Int(2)}})
+
+	assert.Error(t, err)
+	assert.Nil(t, reviews)
 }
 
 func TestGetRepository_RepositoryAPIError(t *testing.T) {
@@ -334,7 +441,7 @@ func TestGetRepository_RepositoryAPIError(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repo, err := m.GetRepository("testorg", "testrepo")
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
 
 	assert.Error(t, err)
 	assert.Nil(t, repo)
@@ -364,10 +471,13 @@ func TestGetRepository_BranchAPIError(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repo, err := m.GetRepository("testorg", "testrepo")
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
 
-	assert.Error(t, err)
-	assert.Nil(t, repo)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), repo.ID)
+	assert.Nil(t, repo.Branches)
+	assert.Error(t, repo.SectionErrors[scm.SectionBranches])
+	assert.Contains(t, repo.SectionErrors, scm.SectionBranches)
 }
 
 func TestGetRepository_ReleaseAPIError(t *testing.T) {
@@ -406,9 +516,35 @@ func TestGetRepository_ReleaseAPIError(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	repo, err := m.GetRepository("testorg", "testrepo")
+	repo, err := m.GetRepository(context.Background(), "testorg", "testrepo", scm.CollectOptions{})
 
-	assert.Error(t, err)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(repo.Branches))
+	assert.Nil(t, repo.Releases)
+	assert.Error(t, repo.SectionErrors[scm.SectionReleases])
+	assert.Contains(t, repo.SectionErrors, scm.SectionReleases)
+	assert.NotContains(t, repo.SectionErrors, scm.SectionBranches)
+}
+
+func TestGetRepository_Cancelled(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposByOwnerByRepo,
+			github.Repository{
+				ID:   github.Int64(123),
+				Name: github.String("testrepo"),
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	repo, err := m.GetRepository(ctx, "testorg", "testrepo", scm.CollectOptions{})
+
+	assert.ErrorIs(t, err, context.Canceled)
 	assert.Nil(t, repo)
 }
 
@@ -439,9 +575,10 @@ func TestGetBranches_MultiplePages(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	branches, err := m.GetBranches("testorg", "testrepo")
+	branches, truncated, err := m.GetBranches(context.Background(), "testorg", "testrepo")
 
 	assert.NoError(t, err)
+	assert.False(t, truncated)
 	assert.Equal(t, 4, len(branches))
 
 	assert.Equal(t, "branch-1", *branches[0].Name)
@@ -474,9 +611,10 @@ func TestGetBranches_ExceedPageSanityCheck(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	branches, err := m.GetBranches("testorg", "testrepo")
+	branches, truncated, err := m.GetBranches(context.Background(), "testorg", "testrepo")
 
 	assert.NoError(t, err)
+	assert.True(t, truncated)
 	assert.Equal(t, 10, len(branches))
 
 	assert.Equal(t, "branch-1", *branches[0].Name)
@@ -491,6 +629,76 @@ func TestGetBranches_ExceedPageSanityCheck(t *testing.T) {
 	assert.Equal(t, "branch-10", *branches[9].Name)
 }
 
+func TestGetBranches_ConfiguredPageLimit(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposBranchesByOwnerByRepo,
+			[]github.Branch{{Name: github.String("branch-1")}},
+			[]github.Branch{{Name: github.String("branch-2")}},
+			[]github.Branch{{Name: github.String("branch-3")}},
+			[]github.Branch{},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{Branches: 2}}
+
+	branches, truncated, err := m.GetBranches(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, 2, len(branches))
+}
+
+func TestGetBranches_Unlimited(t *testing.T) {
+	var pages []interface{}
+	for i := 0; i < 12; i++ {
+		pages = append(pages, []github.Branch{{Name: github.String(fmt.Sprintf("branch-%d", i+1))}})
+	}
+	pages = append(pages, []github.Branch{})
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(mock.GetReposBranchesByOwnerByRepo, pages...),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{Branches: -1}}
+
+	branches, truncated, err := m.GetBranches(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, 12, len(branches))
+	assert.Equal(t, "branch-12", *branches[11].Name)
+}
+
+func TestGetPullRequests_Since(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	old := time.Now().Add(-2 * time.Hour)
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposPullsByOwnerByRepo,
+			[]github.PullRequest{
+				{ID: github.Int64(3), Title: github.String("pr3"), UpdatedAt: &recent},
+				{ID: github.Int64(2), Title: github.String("pr2"), UpdatedAt: &old},
+			},
+			[]github.PullRequest{
+				{ID: github.Int64(1), Title: github.String("pr1"), UpdatedAt: &old},
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	pullRequests, truncated, err := m.GetPullRequests(context.Background(), "testorg", "testrepo", &since)
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, 1, len(pullRequests))
+	assert.Equal(t, int64(3), *pullRequests[0].ID)
+}
+
 func TestGetBranches_APIError(t *testing.T) {
 	mockedHTTPClient := mock.NewMockedHTTPClient(
 		mock.WithRequestMatchHandler(
@@ -508,9 +716,10 @@ func TestGetBranches_APIError(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	branches, err := m.GetBranches("testorg", "testrepo")
+	branches, truncated, err := m.GetBranches(context.Background(), "testorg", "testrepo")
 
 	assert.Error(t, err)
+	assert.False(t, truncated)
 	assert.Nil(t, branches)
 }
 
@@ -541,9 +750,10 @@ func TestGetReleases_MultiplePages(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	releases, err := m.GetReleases("testorg", "testrepo")
+	releases, truncated, err := m.GetReleases(context.Background(), "testorg", "testrepo")
 
 	assert.NoError(t, err)
+	assert.False(t, truncated)
 	assert.Equal(t, 4, len(releases))
 
 	assert.Equal(t, "release-1", *releases[0].Name)
@@ -576,9 +786,10 @@ func TestGetReleases_ExceedPageSanityCheck(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	releases, err := m.GetReleases("testorg", "testrepo")
+	releases, truncated, err := m.GetReleases(context.Background(), "testorg", "testrepo")
 
 	assert.NoError(t, err)
+	assert.True(t, truncated)
 	assert.Equal(t, 10, len(releases))
 
 	assert.Equal(t, "release-1", *releases[0].Name)
@@ -610,12 +821,149 @@ func TestGetReleases_APIError(t *testing.T) {
 	c := github.NewClient(mockedHTTPClient)
 	m := RepositoryDataCollector{GitHubClient: c}
 
-	releases, err := m.GetReleases("testorg", "testrepo")
+	releases, truncated, err := m.GetReleases(context.Background(), "testorg", "testrepo")
 
 	assert.Error(t, err)
+	assert.False(t, truncated)
 	assert.Nil(t, releases)
 }
 
+func TestGetContributorStats_RetryAccepted(t *testing.T) {
+	calls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls < 3 {
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+				_, err := w.Write([]byte(`[{"author": {"id": 1001}, "total": 10}]`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, StatsRetry: StatsRetry{Delay: time.Millisecond}}
+
+	contributors, pending, err := m.GetContributorStats(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 1, len(contributors))
+	assert.Equal(t, 10, contributors[0].GetTotal())
+}
+
+func TestGetContributorStats_StillPending(t *testing.T) {
+	calls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusAccepted)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, StatsRetry: StatsRetry{Attempts: 2, Delay: time.Millisecond}}
+
+	contributors, pending, err := m.GetContributorStats(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	assert.Equal(t, 2, calls)
+	assert.Nil(t, contributors)
+}
+
+func TestGetContributorStats_Cancelled(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposStatsContributorsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, StatsRetry: StatsRetry{Delay: time.Hour}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := m.GetContributorStats(ctx, "testorg", "testrepo")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGetBranchProtection(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposBranchesProtectionByOwnerByRepoByBranch,
+			github.Protection{
+				RequiredPullRequestReviews: &github.PullRequestReviewsEnforcement{RequiredApprovingReviewCount: 2},
+				EnforceAdmins:              &github.AdminEnforcement{Enabled: true},
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	protection, status, err := m.GetBranchProtection(context.Background(), "testorg", "testrepo", "main")
+
+	assert.NoError(t, err)
+	assert.Equal(t, scm.FeatureEnabled, status)
+	assert.Equal(t, 2, protection.RequiredPullRequestReviews.RequiredApprovingReviewCount)
+	assert.True(t, protection.EnforceAdmins.Enabled)
+}
+
+func TestCollectBranchProtection_NotProtected(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposBranchesProtectionByOwnerByRepoByBranch,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusNotFound, "Branch not protected")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	protection, err := m.collectBranchProtection(context.Background(), "testorg", "testrepo", "main")
+
+	assert.NoError(t, err)
+	assert.Equal(t, scm.FeatureNotEnabled, protection.Status)
+	assert.Nil(t, protection.Settings)
+}
+
+func TestCollectBranchProtection_Forbidden(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposBranchesProtectionByOwnerByRepoByBranch,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusForbidden, "Resource not accessible by integration")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	protection, err := m.collectBranchProtection(context.Background(), "testorg", "testrepo", "main")
+	assert.Error(t, err)
+	assert.Equal(t, scm.FeatureUnknown, protection.Status)
+
+	protection, err = m.collectBranchProtection(context.Background(), "testorg", "testrepo", "")
+	assert.NoError(t, err)
+	assert.Equal(t, scm.FeatureUnknown, protection.Status)
+}
+
 func TestExtractLastChangeTS(t *testing.T) {
 	oneHourAgo := time.Now().Add(time.Hour * -1)
 	twoHourAgo := time.Now().Add(time.Hour * -2)
@@ -0,0 +1,104 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+)
+
+const (
+	// most results the search API returns for a query, regardless of paging
+	searchResultLimit = 1000
+	// shortest date window searched before giving up on splitting a window matching too many repositories
+	minSearchWindow = time.Second
+)
+
+// searchQualifiers repository timestamps searched for changes, a repository changed when any of them is after the
+// changed after time
+var searchQualifiers = []string{"updated", "pushed", "created"}
+
+// searches for the repositories of the owner changed after the time, one search for each timestamp qualifier
+// merged into a single set of repositories
+func (m RepositoryDataCollector) searchChanged(ctx context.Context, org string, listing ownerListing, changedAfter time.Time) ([]Repository, error) {
+	owner := "org:" + org
+	if listing != listOrgRepos {
+		owner = "user:" + org
+	}
+	// forks are left out of search results unless asked for
+	owner += " fork:true"
+
+	// queries hold whole seconds, windows are split on whole seconds to match
+	from := changedAfter.UTC().Truncate(time.Second)
+	to := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	found := make(map[int64]bool)
+	var repos []Repository
+	for _, qualifier := range searchQualifiers {
+		glog.Infof("Searching repositories for org %s %s after %s", org, strings.ToUpper(qualifier), changedAfter)
+		results, err := m.searchWindow(ctx, org, owner, qualifier, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if !found[r.ID] {
+				found[r.ID] = true
+				repos = append(repos, r)
+			}
+		}
+	}
+	return repos, nil
+}
+
+// searches for the repositories of the owner with the qualifier's timestamp within the window, splitting the
+// window in half while it matches more repositories than the search API returns
+func (m RepositoryDataCollector) searchWindow(ctx context.Context, org string, owner string, qualifier string, from time.Time, to time.Time) ([]Repository, error) {
+	query := fmt.Sprintf("%s %s:%s..%s", owner, qualifier, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	opt := &gogithub.SearchOptions{ListOptions: gogithub.ListOptions{Page: 1, PerPage: 100}}
+
+	var repos []Repository
+	for {
+		glog.V(2).Infof("Searching repositories using query %s, page number = %d", query, opt.Page)
+		result, resp, err := m.GitHubClient.Search.Repositories(ctx, query, opt)
+		if err != nil {
+			return nil, err
+		}
+		if result.GetIncompleteResults() {
+			return nil, fmt.Errorf("repository search timed out before completing: %s", query)
+		}
+
+		if result.GetTotal() > searchResultLimit {
+			window := to.Sub(from)
+			if window <= minSearchWindow {
+				return nil, fmt.Errorf("more than %d repositories found within %s: %s", searchResultLimit, window, query)
+			}
+			glog.V(2).Infof("Repository search found %d repositories, splitting window: %s", result.GetTotal(), query)
+			mid := from.Add(window / 2).Truncate(time.Second)
+			earlier, err := m.searchWindow(ctx, org, owner, qualifier, from, mid)
+			if err != nil {
+				return nil, err
+			}
+			later, err := m.searchWindow(ctx, org, owner, qualifier, mid, to)
+			if err != nil {
+				return nil, err
+			}
+			return append(earlier, later...), nil
+		}
+
+		for _, repository := range result.Repositories {
+			repos = append(repos, newListedRepository(org, repository))
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		if resp.NextPage > searchResultLimit/opt.PerPage {
+			return nil, errors.New("repository search exceeded the result limit")
+		}
+		opt.Page = resp.NextPage
+	}
+	return repos, nil
+}
@@ -0,0 +1,213 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+// writes the search result for the repositories
+func writeSearchResult(t *testing.T, w http.ResponseWriter, total int, repos ...*github.Repository) {
+	_, err := w.Write(mock.MustMarshal(github.RepositoriesSearchResult{
+		Total:             github.Int(total),
+		IncompleteResults: github.Bool(false),
+		Repositories:      repos,
+	}))
+	assert.NoError(t, err)
+}
+
+// parses the date window of the qualifier from the search query
+func searchQueryWindow(t *testing.T, query string) (qualifier string, from time.Time, to time.Time) {
+	fields := strings.Fields(query)
+	parts := strings.SplitN(fields[len(fields)-1], ":", 2)
+	times := strings.Split(parts[1], "..")
+	from, err := time.Parse(time.RFC3339, times[0])
+	assert.NoError(t, err)
+	to, err = time.Parse(time.RFC3339, times[1])
+	assert.NoError(t, err)
+	return parts[0], from, to
+}
+
+func TestListRepositories_Search(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetSearchRepositories,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query().Get("q")
+				mu.Lock()
+				queries = append(queries, query)
+				mu.Unlock()
+
+				qualifier, _, _ := searchQueryWindow(t, query)
+				switch qualifier {
+				case "updated":
+					writeSearchResult(t, w, 2, &github.Repository{ID: github.Int64(1), Name: github.String("repo-1")}, &github.Repository{ID: github.Int64(2), Name: github.String("repo-2")})
+				case "pushed":
+					writeSearchResult(t, w, 2, &github.Repository{ID: github.Int64(2), Name: github.String("repo-2")}, &github.Repository{ID: github.Int64(3), Name: github.String("repo-3")})
+				default:
+					writeSearchResult(t, w, 0)
+				}
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, SearchDiscovery: true}
+
+	changedAfter := time.Now().Add(-24 * time.Hour)
+	repos, err := m.ListRepositories(context.Background(), "testorg", &changedAfter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(repos))
+	assert.Equal(t, "repo-1", repos[0].Name)
+	assert.Equal(t, "repo-2", repos[1].Name)
+	assert.Equal(t, "repo-3", repos[2].Name)
+	assert.Equal(t, "testorg", repos[2].Org)
+	assert.Equal(t, 3, len(queries))
+	for _, query := range queries {
+		assert.True(t, strings.HasPrefix(query, "org:testorg fork:true "), query)
+	}
+}
+
+func TestListRepositories_SearchSplitsWindow(t *testing.T) {
+	var mu sync.Mutex
+	var id int64
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetSearchRepositories,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				qualifier, from, to := searchQueryWindow(t, r.URL.Query().Get("q"))
+				if qualifier != "updated" {
+					writeSearchResult(t, w, 0)
+					return
+				}
+				// windows over 6 hours match more repositories than the search returns
+				if to.Sub(from) > 6*time.Hour {
+					writeSearchResult(t, w, 1500)
+					return
+				}
+				mu.Lock()
+				id++
+				repo := &github.Repository{ID: github.Int64(id), Name: github.String("repo")}
+				mu.Unlock()
+				writeSearchResult(t, w, 1, repo)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, SearchDiscovery: true}
+
+	changedAfter := time.Now().Add(-24 * time.Hour)
+	repos, err := m.ListRepositories(context.Background(), "testorg", &changedAfter)
+
+	assert.NoError(t, err)
+	assert.True(t, len(repos) >= 4)
+	assert.Equal(t, int(id), len(repos))
+}
+
+func TestListRepositories_SearchUser(t *testing.T) {
+	var query string
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("serviceaccount")},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetSearchRepositories,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Query().Get("q")
+				writeSearchResult(t, w, 0)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, SearchDiscovery: true, UserOwners: true}
+
+	changedAfter := time.Now().Add(-24 * time.Hour)
+	repos, err := m.ListRepositories(context.Background(), "testuser", &changedAfter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(repos))
+	assert.True(t, strings.HasPrefix(query, "user:testuser fork:true "), query)
+}
+
+func TestListRepositories_SearchFallback(t *testing.T) {
+	pushed := time.Now()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetSearchRepositories,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusUnprocessableEntity, "Validation Failed")
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetOrgsReposByOrg,
+			[]github.Repository{
+				{ID: github.Int64(123), Name: github.String("Repo-123"), PushedAt: &github.Timestamp{Time: pushed}},
+			},
+			[]github.Repository{
+				{ID: github.Int64(123), Name: github.String("Repo-123"), PushedAt: &github.Timestamp{Time: pushed}},
+			},
+			[]github.Repository{
+				{ID: github.Int64(123), Name: github.String("Repo-123"), PushedAt: &github.Timestamp{Time: pushed}},
+			},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, SearchDiscovery: true}
+
+	changedAfter := time.Now().Add(-24 * time.Hour)
+	repos, err := m.ListRepositories(context.Background(), "testorg", &changedAfter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(repos))
+	assert.Equal(t, "Repo-123", repos[0].Name)
+}
+
+func TestListRepositories_SearchIncomplete(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetSearchRepositories,
+			github.RepositoriesSearchResult{Total: github.Int(1), IncompleteResults: github.Bool(true)},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	_, err := m.searchChanged(context.Background(), "testorg", listOrgRepos, time.Now().Add(-time.Hour))
+
+	assert.Error(t, err)
+}
+
+func TestSearchWindow_TooManyResults(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetSearchRepositories,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeSearchResult(t, w, 1500)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	from := time.Date(2021, 10, 1, 10, 0, 0, 0, time.UTC)
+	_, err := m.searchWindow(context.Background(), "testorg", "org:testorg", "pushed", from, from.Add(4*time.Second))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "more than 1000 repositories")
+}
@@ -0,0 +1,152 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// SecurityAlerts open security alerts along with the status of the features raising them
+type SecurityAlerts struct {
+	CodeScanning       scm.FeatureStatus
+	CodeScanningAlerts []*gogithub.Alert
+	Dependabot         scm.FeatureStatus
+	DependabotAlerts   []*DependabotAlert
+}
+
+// DependabotAlert minimal Dependabot alert details, the alerts API isn't available in the GitHub client
+type DependabotAlert struct {
+	Number           int    `json:"number"`
+	State            string `json:"state"`
+	SecurityAdvisory struct {
+		Severity string `json:"severity"`
+	} `json:"security_advisory"`
+}
+
+// GetCodeScanningAlerts retrieves the open code scanning alerts by organization/repo along with whether code
+// scanning is enabled, flagging when truncated by the page limit
+func (m RepositoryDataCollector) GetCodeScanningAlerts(ctx context.Context, org string, repo string) ([]*gogithub.Alert, bool, scm.FeatureStatus, error) {
+	opt := &gogithub.AlertListOptions{
+		State:       "open",
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+
+	var allAlerts []*gogithub.Alert
+	for loopCnt := 1; ; loopCnt++ {
+		if pageLimitReached(m.PageLimits.SecurityAlerts, loopCnt) {
+			glog.Warningf("Repository has more than %d open code scanning alerts: %s/%s", len(allAlerts), org, repo)
+			return allAlerts, true, scm.FeatureEnabled, nil
+		}
+
+		glog.V(2).Infof("Collecting code scanning alerts for %s/%s, count per page = %d, page number = %d", org, repo, opt.PerPage, opt.Page)
+		alerts, resp, err := m.GitHubClient.CodeScanning.ListAlertsForRepo(ctx, org, repo, opt)
+		if err != nil {
+			return nil, false, featureStatus(err), err
+		}
+		allAlerts = append(allAlerts, alerts...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allAlerts, false, scm.FeatureEnabled, nil
+}
+
+// GetDependabotAlerts retrieves the open Dependabot alerts by organization/repo along with whether Dependabot
+// alerts are enabled, flagging when truncated by the page limit
+func (m RepositoryDataCollector) GetDependabotAlerts(ctx context.Context, org string, repo string) ([]*DependabotAlert, bool, scm.FeatureStatus, error) {
+	path := fmt.Sprintf("repos/%s/%s/dependabot/alerts?state=open&per_page=100", url.PathEscape(org), url.PathEscape(repo))
+
+	var allAlerts []*DependabotAlert
+	for loopCnt := 1; path != ""; loopCnt++ {
+		if pageLimitReached(m.PageLimits.SecurityAlerts, loopCnt) {
+			glog.Warningf("Repository has more than %d open dependabot alerts: %s/%s", len(allAlerts), org, repo)
+			return allAlerts, true, scm.FeatureEnabled, nil
+		}
+
+		glog.V(2).Infof("Collecting dependabot alerts for %s/%s: %s", org, repo, path)
+		req, err := m.GitHubClient.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			return nil, false, scm.FeatureUnknown, err
+		}
+
+		var alerts []*DependabotAlert
+		resp, err := m.GitHubClient.Do(ctx, req, &alerts)
+		if err != nil {
+			return nil, false, featureStatus(err), err
+		}
+		allAlerts = append(allAlerts, alerts...)
+		path = nextLink(resp)
+	}
+	return allAlerts, false, scm.FeatureEnabled, nil
+}
+
+// collect the open security alerts, alerts being left uncollected when the feature isn't enabled, flagging when
+// either is truncated by the page limit.  The error of a feature whose status couldn't be determined is returned
+// along with the alerts collected.
+func (m RepositoryDataCollector) collectSecurityAlerts(ctx context.Context, org string, repo string) (security SecurityAlerts, truncated bool, failure error) {
+	var err error
+	var codeScanningTruncated, dependabotTruncated bool
+	security.CodeScanningAlerts, codeScanningTruncated, security.CodeScanning, err = m.GetCodeScanningAlerts(ctx, org, repo)
+	if err != nil && security.CodeScanning == scm.FeatureUnknown {
+		failure = fmt.Errorf("code scanning alerts: %w", err)
+	}
+
+	security.DependabotAlerts, dependabotTruncated, security.Dependabot, err = m.GetDependabotAlerts(ctx, org, repo)
+	if err != nil && security.Dependabot == scm.FeatureUnknown && failure == nil {
+		failure = fmt.Errorf("dependabot alerts: %w", err)
+	}
+	return security, codeScanningTruncated || dependabotTruncated, failure
+}
+
+// messages GitHub answers a 403 with when a feature isn't enabled for the repository, e.g. "Advanced Security
+// must be enabled for this repository to use code scanning." or "Dependabot alerts are disabled for this
+// repository."
+var featureDisabledMessages = []string{"must be enabled", "not enabled", "disabled"}
+
+// determine the feature status from an error collecting its data, GitHub answering 404 when code scanning
+// has never run, 410 when issues are disabled and 403 with a message when a feature is disabled
+func featureStatus(err error) scm.FeatureStatus {
+	var errResp *gogithub.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return scm.FeatureUnknown
+	}
+	switch errResp.Response.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return scm.FeatureNotEnabled
+	case http.StatusForbidden:
+		msg := strings.ToLower(errResp.Message)
+		for _, disabled := range featureDisabledMessages {
+			if strings.Contains(msg, disabled) {
+				return scm.FeatureNotEnabled
+			}
+		}
+	}
+	return scm.FeatureUnknown
+}
+
+// determine if the error reports the feature isn't enabled for the repository rather than failing to collect it
+func featureUnavailable(err error) bool {
+	return featureStatus(err) == scm.FeatureNotEnabled
+}
+
+// url of the next page from the link header, the cursor based pages aren't tracked by the GitHub client
+func nextLink(resp *gogithub.Response) string {
+	for _, link := range strings.Split(resp.Header.Get("Link"), ",") {
+		segments := strings.Split(strings.TrimSpace(link), ";")
+		if len(segments) < 2 || strings.TrimSpace(segments[1]) != `rel="next"` {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(segments[0]), "<>")
+	}
+	return ""
+}
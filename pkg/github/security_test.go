@@ -0,0 +1,174 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+var getDependabotAlerts = mock.EndpointPattern{Pattern: "/repos/{owner}/{repo}/dependabot/alerts", Method: "GET"}
+
+// write an error response the way GitHub does
+func writeGitHubError(t *testing.T, w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	_, err := w.Write([]byte(`{"message": "` + msg + `"}`))
+	assert.NoError(t, err)
+}
+
+func TestGetCodeScanningAlerts(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposCodeScanningAlertsByOwnerByRepo,
+			[]github.Alert{{RuleID: github.String("rule-1")}, {RuleID: github.String("rule-2")}},
+			[]github.Alert{{RuleID: github.String("rule-3")}},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	alerts, truncated, status, err := m.GetCodeScanningAlerts(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, scm.FeatureEnabled, status)
+	assert.Equal(t, 3, len(alerts))
+}
+
+func TestGetCodeScanningAlerts_ExceedPageLimit(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchPages(
+			mock.GetReposCodeScanningAlertsByOwnerByRepo,
+			[]github.Alert{{RuleID: github.String("rule-1")}, {RuleID: github.String("rule-2")}},
+			[]github.Alert{{RuleID: github.String("rule-3")}},
+			[]github.Alert{{RuleID: github.String("rule-4")}},
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c, PageLimits: scm.PageLimits{SecurityAlerts: 2}}
+
+	alerts, truncated, status, err := m.GetCodeScanningAlerts(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, scm.FeatureEnabled, status)
+	assert.Equal(t, 3, len(alerts))
+}
+
+func TestGetCodeScanningAlerts_NotEnabled(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposCodeScanningAlertsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusNotFound, "no analysis found")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	alerts, _, status, err := m.GetCodeScanningAlerts(context.Background(), "testorg", "testrepo")
+
+	assert.Error(t, err)
+	assert.Equal(t, scm.FeatureNotEnabled, status)
+	assert.Nil(t, alerts)
+}
+
+func TestGetDependabotAlerts_MultiplePages(t *testing.T) {
+	calls := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			getDependabotAlerts,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				assert.Equal(t, "open", r.URL.Query().Get("state"))
+				if calls == 1 {
+					w.Header().Set("Link", `<https://api.github.com/repos/testorg/testrepo/dependabot/alerts?state=open&per_page=100&after=abc>; rel="next"`)
+					_, err := w.Write([]byte(`[{"number": 1, "state": "open", "security_advisory": {"severity": "critical"}}]`))
+					assert.NoError(t, err)
+					return
+				}
+				assert.Equal(t, "abc", r.URL.Query().Get("after"))
+				_, err := w.Write([]byte(`[{"number": 2, "state": "open", "security_advisory": {"severity": "low"}}]`))
+				assert.NoError(t, err)
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	alerts, truncated, status, err := m.GetDependabotAlerts(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, scm.FeatureEnabled, status)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, len(alerts))
+	assert.Equal(t, "critical", alerts[0].SecurityAdvisory.Severity)
+	assert.Equal(t, "low", alerts[1].SecurityAdvisory.Severity)
+}
+
+func TestCollectSecurityAlerts(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposCodeScanningAlertsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusForbidden, "Resource not accessible by integration")
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			getDependabotAlerts,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeGitHubError(t, w, http.StatusForbidden, "Dependabot alerts are disabled for this repository.")
+			}),
+		),
+	)
+
+	c := github.NewClient(mockedHTTPClient)
+	m := RepositoryDataCollector{GitHubClient: c}
+
+	security, truncated, err := m.collectSecurityAlerts(context.Background(), "testorg", "testrepo")
+
+	assert.Error(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, scm.FeatureUnknown, security.CodeScanning)
+	assert.Nil(t, security.CodeScanningAlerts)
+	assert.Equal(t, scm.FeatureNotEnabled, security.Dependabot)
+	assert.Nil(t, security.DependabotAlerts)
+}
+
+func TestFeatureStatus(t *testing.T) {
+	assert.Equal(t, scm.FeatureUnknown, featureStatus(errors.New("connection refused")))
+	assert.Equal(t, scm.FeatureNotEnabled, featureStatus(&github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusNotFound},
+	}))
+	assert.Equal(t, scm.FeatureNotEnabled, featureStatus(&github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  "Advanced Security must be enabled for this repository to use code scanning. Code scanning is not enabled.",
+	}))
+	assert.Equal(t, scm.FeatureNotEnabled, featureStatus(&github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  "Advanced Security must be enabled for this repository to use code scanning.",
+	}))
+	assert.Equal(t, scm.FeatureNotEnabled, featureStatus(&github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  "Dependabot alerts are disabled for this repository.",
+	}))
+	assert.Equal(t, scm.FeatureUnknown, featureStatus(&github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  "Resource not accessible by integration",
+	}))
+	assert.Equal(t, scm.FeatureUnknown, featureStatus(&github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusInternalServerError},
+	}))
+}
@@ -0,0 +1,157 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// DefaultBaseURL base url of the gitlab.com REST API
+const DefaultBaseURL = "https://gitlab.com/api/v4/"
+
+// perPage number of resources requested per page, the most GitLab returns
+const perPage = 100
+
+// Client GitLab REST API (v4) client authenticating with a personal, group or project access token
+type Client struct {
+	BaseURL    *url.URL
+	Token      string
+	HTTPClient *http.Client
+}
+
+// ErrorResponse error reported by the GitLab API
+type ErrorResponse struct {
+	StatusCode int
+	Message    string
+}
+
+// Error describes the status and message of the failed request
+func (e *ErrorResponse) Error() string {
+	return fmt.Sprintf("gitlab request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// NewClient builds a client for the GitLab API at the base url, gitlab.com when not supplied
+func NewClient(baseURL string, token string) (*Client, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{BaseURL: u, Token: token, HTTPClient: http.DefaultClient}, nil
+}
+
+// Get retrieves the resource at the path, relative to the base url, decoding the JSON response into v.  The
+// next page of the resource is returned, zero on the last page.
+func (c *Client) Get(ctx context.Context, path string, query url.Values, v interface{}) (int, error) {
+	u, err := c.BaseURL.Parse(path)
+	if err != nil {
+		return 0, err
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.Token)
+	}
+
+	glog.V(3).Infof("GitLab request: %s", u)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, newErrorResponse(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return 0, fmt.Errorf("unable to decode gitlab response from %s: %w", u.Path, err)
+	}
+
+	// the next page header is empty on the last page
+	next := 0
+	if header := resp.Header.Get("X-Next-Page"); header != "" {
+		if next, err = strconv.Atoi(header); err != nil {
+			return 0, fmt.Errorf("invalid gitlab next page %s: %w", header, err)
+		}
+	}
+	return next, nil
+}
+
+// client sending the requests, the default client when not configured
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// build the error for the failed response from the message of its body, GitLab reporting either a message or
+// an error
+func newErrorResponse(resp *http.Response) *ErrorResponse {
+	e := &ErrorResponse{StatusCode: resp.StatusCode, Message: resp.Status}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return e
+	}
+	var parsed struct {
+		Message interface{} `json:"message"`
+		Error   string      `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		return e
+	}
+	switch {
+	case parsed.Message != nil:
+		e.Message = fmt.Sprint(parsed.Message)
+	case parsed.Error != "":
+		e.Message = parsed.Error
+	}
+	return e
+}
+
+// close the response body, logging rather than failing when it can't be closed
+func closeBody(body io.Closer) {
+	if err := body.Close(); err != nil {
+		glog.V(2).Infof("Unable to close gitlab response body: %s", err)
+	}
+}
+
+// determine if the error is the resource not being found
+func isNotFound(err error) bool {
+	var e *ErrorResponse
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// query requesting the page of a resource, along with the supplied parameters
+func pageQuery(page int, params url.Values) url.Values {
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+	query.Set("page", strconv.Itoa(page))
+	return query
+}
+
+// escaped project path of the repository, usable as the project ID in request paths
+func projectPath(org string, name string) string {
+	return "projects/" + url.PathEscape(org+"/"+name)
+}
@@ -0,0 +1,90 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient("https://gitlab.example.com/api/v4", "token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://gitlab.example.com/api/v4/", client.BaseURL.String())
+	assert.Equal(t, "token", client.Token)
+}
+
+func TestNewClient_Default(t *testing.T) {
+	client, err := NewClient("", "token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBaseURL, client.BaseURL.String())
+}
+
+func TestGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v4/projects/group%2Fproject", r.URL.EscapedPath())
+		assert.Equal(t, "2", r.URL.Query().Get("page"))
+		assert.Equal(t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+		w.Header().Set("X-Next-Page", "3")
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL+"/api/v4/", "secret")
+	var p project
+	next, err := client.Get(context.Background(), projectPath("group", "project"), url.Values{"page": {"2"}}, &p)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, next)
+	assert.Equal(t, int64(42), p.ID)
+}
+
+func TestGet_LastPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Next-Page", "")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "secret")
+	var groups []group
+	next, err := client.Get(context.Background(), "groups", nil, &groups)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, next)
+}
+
+func TestGet_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "404 Project Not Found"}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "secret")
+	var p project
+	_, err := client.Get(context.Background(), projectPath("group", "missing"), nil, &p)
+
+	assert.True(t, isNotFound(err))
+	assert.EqualError(t, err, "gitlab request failed with status 404: 404 Project Not Found")
+}
+
+func TestGet_ErrorWithoutMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "invalid_token"}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "secret")
+	var groups []group
+	_, err := client.Get(context.Background(), "groups", nil, &groups)
+
+	assert.False(t, isNotFound(err))
+	assert.EqualError(t, err, "gitlab request failed with status 401: invalid_token")
+}
@@ -0,0 +1,154 @@
+package gitlab
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// system notes recording a merge request being approved, or the approval being withdrawn
+const (
+	noteApproved   = "approved this merge request"
+	noteUnapproved = "unapproved this merge request"
+)
+
+// user acting on a merge request
+type user struct {
+	Username string `json:"username"`
+}
+
+// merge request of a project, the state being opened, closed, locked or merged
+type mergeRequest struct {
+	ID        int64      `json:"id"`
+	IID       int        `json:"iid"`
+	State     string     `json:"state"`
+	Author    user       `json:"author"`
+	Reviewers []user     `json:"reviewers"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	MergedAt  *time.Time `json:"merged_at"`
+}
+
+// note left on a merge request, system notes recording events such as approvals.  The position is set for notes
+// left on the diff.
+type note struct {
+	ID        int64      `json:"id"`
+	Body      string     `json:"body"`
+	System    bool       `json:"system"`
+	Author    user       `json:"author"`
+	CreatedAt *time.Time `json:"created_at"`
+	Position  *struct {
+		HeadSHA string `json:"head_sha"`
+	} `json:"position"`
+}
+
+// retrieves the merge requests of the project as pull requests, most recently updated first, only those updated
+// after the time when supplied.  Flags when truncated by the page limit.
+func (m RepositoryDataCollector) getMergeRequests(ctx context.Context, org string, name string, since *time.Time) ([]scm.PullRequest, bool, error) {
+	params := url.Values{"state": {"all"}, "order_by": {"updated_at"}, "sort": {"desc"}}
+	if since != nil {
+		params.Set("updated_after", since.UTC().Format(time.RFC3339))
+	}
+
+	allPullRequests := []scm.PullRequest{}
+	for page, loopCnt := 1, 1; page != 0; loopCnt++ {
+		if pageLimitReached(m.PageLimits.PullRequests, loopCnt) {
+			glog.Warningf("Project has more than %d merge requests: %s/%s", len(allPullRequests), org, name)
+			return allPullRequests, true, nil
+		}
+
+		glog.V(2).Infof("Collecting merge requests for %s/%s, count per page = %d, page number = %d", org, name, perPage, page)
+		var mergeRequests []mergeRequest
+		next, err := m.Client.Get(ctx, projectPath(org, name)+"/merge_requests", pageQuery(page, params), &mergeRequests)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, mr := range mergeRequests {
+			allPullRequests = append(allPullRequests, mr.pullRequest())
+		}
+		page = next
+	}
+	return allPullRequests, false, nil
+}
+
+// retrieves the reviews of the merge requests by merge request number, approvals being recorded by system notes
+// and comments by the other notes.  The notes of each merge request are limited by the pull request page limit,
+// flagging when truncated.
+func (m RepositoryDataCollector) getReviews(ctx context.Context, org string, name string, pullRequests []scm.PullRequest) (map[int][]scm.Review, bool, error) {
+	reviews := make(map[int][]scm.Review)
+	truncated := false
+	for _, pr := range pullRequests {
+		path := projectPath(org, name) + "/merge_requests/" + strconv.Itoa(pr.Number) + "/notes"
+		params := url.Values{"order_by": {"created_at"}, "sort": {"asc"}}
+		for page, loopCnt := 1, 1; page != 0; loopCnt++ {
+			if pageLimitReached(m.PageLimits.PullRequests, loopCnt) {
+				glog.Warningf("Merge request has more than %d pages of notes: %s/%s!%d", loopCnt-1, org, name, pr.Number)
+				truncated = true
+				break
+			}
+
+			glog.V(3).Infof("Collecting notes for %s/%s!%d, page number = %d", org, name, pr.Number, page)
+			var notes []note
+			next, err := m.Client.Get(ctx, path, pageQuery(page, params), &notes)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, n := range notes {
+				if review, ok := n.review(); ok {
+					reviews[pr.Number] = append(reviews[pr.Number], review)
+				}
+			}
+			page = next
+		}
+	}
+	return reviews, truncated, nil
+}
+
+// the merge request as a pull request, merged merge requests being closed when merged
+func (mr mergeRequest) pullRequest() scm.PullRequest {
+	pr := scm.PullRequest{
+		ID:                 mr.ID,
+		Number:             mr.IID,
+		State:              "closed",
+		Author:             mr.Author.Username,
+		RequestedReviewers: len(mr.Reviewers),
+		Created:            mr.CreatedAt,
+		Updated:            mr.UpdatedAt,
+		Closed:             mr.ClosedAt,
+		Merged:             mr.MergedAt,
+	}
+	if mr.State == "opened" || mr.State == "locked" {
+		pr.State = "open"
+	}
+	if pr.Closed == nil && mr.State == "merged" {
+		pr.Closed = mr.MergedAt
+	}
+	return pr
+}
+
+// the note as a review, false when the note is a system note other than an approval
+func (n note) review() (scm.Review, bool) {
+	review := scm.Review{ID: n.ID, State: "COMMENTED", Author: n.Author.Username, Submitted: n.CreatedAt}
+	if n.Position != nil {
+		review.CommitID = n.Position.HeadSHA
+	}
+	if !n.System {
+		return review, true
+	}
+
+	switch n.Body {
+	case noteApproved:
+		review.State = "APPROVED"
+	case noteUnapproved:
+		review.State = "DISMISSED"
+	default:
+		return scm.Review{}, false
+	}
+	return review, true
+}
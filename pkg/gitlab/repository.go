@@ -0,0 +1,529 @@
+package gitlab
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// ProviderName name of the GitLab source control provider
+const ProviderName = "gitlab"
+
+// defaultPageLimit number of pages collected for a resource when no limit is configured
+const defaultPageLimit = 10
+
+// RepositoryDataCollector collects the projects of GitLab groups as repositories.  Groups are treated as
+// organizations, only the projects directly within a group being collected unless subgroups are included, the
+// projects of subgroups being named by their path within the group (e.g. subgroup/project).
+type RepositoryDataCollector struct {
+	Client           *Client
+	PageLimits       scm.PageLimits
+	IncludeSubgroups bool
+}
+
+// group of projects
+type group struct {
+	ID       int64  `json:"id"`
+	FullPath string `json:"full_path"`
+}
+
+// project settings and timestamps, the statistics only reported to members with at least reporter access
+type project struct {
+	ID                               int64      `json:"id"`
+	Path                             string     `json:"path"`
+	PathWithNamespace                string     `json:"path_with_namespace"`
+	DefaultBranch                    string     `json:"default_branch"`
+	Visibility                       string     `json:"visibility"`
+	Archived                         bool       `json:"archived"`
+	ForkedFromProject                *struct{}  `json:"forked_from_project"`
+	Topics                           []string   `json:"topics"`
+	TagList                          []string   `json:"tag_list"`
+	MergeMethod                      string     `json:"merge_method"`
+	SquashOption                     string     `json:"squash_option"`
+	OnlyAllowMergeIfPipelineSucceeds bool       `json:"only_allow_merge_if_pipeline_succeeds"`
+	CreatedAt                        *time.Time `json:"created_at"`
+	UpdatedAt                        *time.Time `json:"updated_at"`
+	LastActivityAt                   *time.Time `json:"last_activity_at"`
+	Statistics                       *struct {
+		RepositorySize int64 `json:"repository_size"`
+	} `json:"statistics"`
+}
+
+// branch along with its last commit
+type branch struct {
+	Name      string `json:"name"`
+	Protected bool   `json:"protected"`
+	Merged    bool   `json:"merged"`
+	Commit    struct {
+		CommittedDate *time.Time `json:"committed_date"`
+	} `json:"commit"`
+}
+
+// protection rules of a branch
+type protectedBranch struct {
+	AllowForcePush            bool `json:"allow_force_push"`
+	CodeOwnerApprovalRequired bool `json:"code_owner_approval_required"`
+}
+
+// merge request approval rules of a project
+type approvalSettings struct {
+	ApprovalsBeforeMerge int  `json:"approvals_before_merge"`
+	ResetApprovalsOnPush bool `json:"reset_approvals_on_push"`
+}
+
+// release of a project, upcoming when released in the future
+type release struct {
+	Name            string     `json:"name"`
+	TagName         string     `json:"tag_name"`
+	UpcomingRelease bool       `json:"upcoming_release"`
+	CreatedAt       *time.Time `json:"created_at"`
+	ReleasedAt      *time.Time `json:"released_at"`
+}
+
+// tag of a project, the release set when the tag has been released
+type tag struct {
+	Name   string `json:"name"`
+	Commit struct {
+		CommittedDate *time.Time `json:"committed_date"`
+	} `json:"commit"`
+	Release *struct {
+		TagName string `json:"tag_name"`
+	} `json:"release"`
+}
+
+// author of commits to a project
+type contributor struct {
+	Name    string `json:"name"`
+	Commits int    `json:"commits"`
+}
+
+// ListOrganizations retrieves the full paths of the top level groups the authenticated user is a member of
+func (m RepositoryDataCollector) ListOrganizations(ctx context.Context) ([]string, error) {
+	var orgs []string
+	for page := 1; page != 0; {
+		glog.V(2).Infof("Collecting groups, count per page = %d, page number = %d", perPage, page)
+		var groups []group
+		next, err := m.Client.Get(ctx, "groups", pageQuery(page, url.Values{"top_level_only": {"true"}}), &groups)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			orgs = append(orgs, g.FullPath)
+		}
+		page = next
+	}
+	return orgs, nil
+}
+
+// ListRepositories retrieves the set of projects within a group, along with those of its subgroups when included,
+// only those with activity after the time when supplied
+func (m RepositoryDataCollector) ListRepositories(ctx context.Context, org string, changedAfter *time.Time) ([]scm.Repository, error) {
+	params := url.Values{"order_by": {"last_activity_at"}, "sort": {"desc"}}
+	if m.IncludeSubgroups {
+		params.Set("include_subgroups", "true")
+	}
+	if changedAfter != nil {
+		glog.Infof("Collecting projects for group %s with activity after %s", org, changedAfter)
+		params.Set("last_activity_after", changedAfter.UTC().Format(time.RFC3339))
+	} else {
+		glog.Infof("Collecting all projects for group %s", org)
+	}
+
+	var repos []scm.Repository
+	for page := 1; page != 0; {
+		glog.V(2).Infof("Collecting projects for group %s, count per page = %d, page number = %d", org, perPage, page)
+		var projects []project
+		next, err := m.Client.Get(ctx, "groups/"+url.PathEscape(org)+"/projects", pageQuery(page, params), &projects)
+		if err != nil {
+			return nil, err
+		}
+		for i := range projects {
+			p := &projects[i]
+			repos = append(repos, scm.Repository{
+				ID:       p.ID,
+				Provider: ProviderName,
+				Org:      org,
+				Name:     p.name(org),
+				Topics:   p.topics(),
+				Changed:  p.changed(),
+				Detail:   p.detail(),
+			})
+		}
+		page = next
+	}
+	return repos, nil
+}
+
+// GetRepository retrieves the project information by group/path
+func (m RepositoryDataCollector) GetRepository(ctx context.Context, org string, name string, opts scm.CollectOptions) (*scm.Repository, error) {
+	// retrieve data from gitlab using goroutines to pull the project along with each section, only the project
+	// is required as a failed section is recorded against the repository
+	callerCtx := ctx
+	grp, ctx := errgroup.WithContext(ctx)
+	failures := &scm.SectionFailures{Org: org, Name: name}
+
+	var p *project
+	var protection scm.BranchProtection
+	grp.Go(func() error {
+		var err error
+		if p, err = m.getProject(ctx, org, name); err != nil {
+			return err
+		}
+		if protection, err = m.collectBranchProtection(ctx, org, name, p); err != nil {
+			failures.Fail(scm.SectionProtection, err)
+		}
+		return nil
+	})
+
+	var truncated scm.Truncated
+	var branches []branch
+	grp.Go(func() error {
+		b, t, err := m.getBranches(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionBranches, err)
+			return nil
+		}
+		branches = b
+		truncated.Branches = t
+		return nil
+	})
+
+	var releases []scm.Release
+	grp.Go(func() error {
+		r, t, err := m.getReleases(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionReleases, err)
+			return nil
+		}
+		releases = r
+		truncated.Releases = t
+		return nil
+	})
+
+	var pullRequests []scm.PullRequest
+	var reviews map[int][]scm.Review
+	grp.Go(func() error {
+		mrs, t, err := m.getMergeRequests(ctx, org, name, opts.PullRequestsSince)
+		if err != nil {
+			failures.Fail(scm.SectionPullRequests, err)
+			return nil
+		}
+		pullRequests = mrs
+		truncated.PullRequests = t
+		reviews, t, err = m.getReviews(ctx, org, name, mrs)
+		if err != nil {
+			failures.Fail(scm.SectionReviews, err)
+			return nil
+		}
+		truncated.PullRequests = truncated.PullRequests || t
+		return nil
+	})
+
+	var languages map[string]float64
+	grp.Go(func() error {
+		l, err := m.getLanguages(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionLanguages, err)
+			return nil
+		}
+		languages = l
+		return nil
+	})
+
+	var contributors []scm.Contributor
+	grp.Go(func() error {
+		c, t, err := m.getContributors(ctx, org, name)
+		if err != nil {
+			failures.Fail(scm.SectionContributors, err)
+			return nil
+		}
+		contributors = c
+		truncated.Contributors = t
+		return nil
+	})
+
+	if err := grp.Wait(); err != nil {
+		return nil, err
+	}
+
+	// sections fail when the caller gives up, report that rather than a repository missing its sections
+	if err := callerCtx.Err(); err != nil {
+		return nil, err
+	}
+
+	// languages are reported as percentages, sized from the repository size when the statistics are available
+	languageBytes := sizeLanguages(org, name, languages, p)
+
+	repo := &scm.Repository{
+		ID:                p.ID,
+		Provider:          ProviderName,
+		Org:               org,
+		Name:              name,
+		Topics:            p.topics(),
+		Changed:           p.changed(),
+		Detail:            p.detail(),
+		Protection:        protection,
+		Releases:          releases,
+		PullRequests:      pullRequests,
+		Reviews:           reviews,
+		Languages:         languageBytes,
+		Contributors:      contributors,
+		Security:          scm.SecurityAlerts{CodeScanning: scm.FeatureUnknown, Dependabot: scm.FeatureUnknown},
+		Truncated:         truncated,
+		PullRequestsSince: opts.PullRequestsSince,
+		SectionErrors:     failures.Failed(),
+	}
+	if branches != nil {
+		repo.BranchActivity = make(map[string]scm.BranchActivity)
+	}
+	for _, b := range branches {
+		repo.Branches = append(repo.Branches, scm.Branch{Name: b.Name, Protected: b.Protected})
+		if b.Name != p.DefaultBranch {
+			repo.BranchActivity[b.Name] = scm.BranchActivity{LastCommit: b.Commit.CommittedDate, Merged: b.Merged}
+		}
+	}
+	return repo, nil
+}
+
+// retrieves the project along with its statistics
+func (m RepositoryDataCollector) getProject(ctx context.Context, org string, name string) (*project, error) {
+	glog.V(2).Infof("Collecting project %s/%s", org, name)
+	var p project
+	if _, err := m.Client.Get(ctx, projectPath(org, name), url.Values{"statistics": {"true"}}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// retrieves the branches of the project, flagging when truncated by the page limit
+func (m RepositoryDataCollector) getBranches(ctx context.Context, org string, name string) ([]branch, bool, error) {
+	allBranches := []branch{}
+	for page, loopCnt := 1, 1; page != 0; loopCnt++ {
+		if pageLimitReached(m.PageLimits.Branches, loopCnt) {
+			glog.Warningf("Project has more than %d branches: %s/%s", len(allBranches), org, name)
+			return allBranches, true, nil
+		}
+
+		glog.V(2).Infof("Collecting branches for %s/%s, count per page = %d, page number = %d", org, name, perPage, page)
+		var branches []branch
+		next, err := m.Client.Get(ctx, projectPath(org, name)+"/repository/branches", pageQuery(page, nil), &branches)
+		if err != nil {
+			return nil, false, err
+		}
+		allBranches = append(allBranches, branches...)
+		page = next
+	}
+	return allBranches, false, nil
+}
+
+// collect the protection rules of the default branch, the status being unknown along with the error when they
+// can't be retrieved
+func (m RepositoryDataCollector) collectBranchProtection(ctx context.Context, org string, name string, p *project) (scm.BranchProtection, error) {
+	if p.DefaultBranch == "" {
+		return scm.BranchProtection{Status: scm.FeatureNotEnabled}, nil
+	}
+
+	glog.V(2).Infof("Collecting branch protection for %s/%s branch %s", org, name, p.DefaultBranch)
+	var protected protectedBranch
+	_, err := m.Client.Get(ctx, projectPath(org, name)+"/protected_branches/"+url.PathEscape(p.DefaultBranch), nil, &protected)
+	if isNotFound(err) {
+		return scm.BranchProtection{Status: scm.FeatureNotEnabled}, nil
+	}
+	if err != nil {
+		return scm.BranchProtection{Status: scm.FeatureUnknown}, err
+	}
+
+	settings := &scm.ProtectionSettings{
+		RequireCodeOwnerReviews: protected.CodeOwnerApprovalRequired,
+		RequireLinearHistory:    p.MergeMethod == "ff",
+		AllowForcePushes:        protected.AllowForcePush,
+	}
+	if p.OnlyAllowMergeIfPipelineSucceeds {
+		settings.RequiredStatusChecks = []string{"pipeline"}
+	}
+
+	// approval rules aren't available on every tier, the protection stands without them
+	var approvals approvalSettings
+	if _, err := m.Client.Get(ctx, projectPath(org, name)+"/approvals", nil, &approvals); err != nil {
+		glog.V(2).Infof("Unable to collect approval settings for %s/%s: %s", org, name, err)
+	} else {
+		settings.RequiredApprovingReviewCount = approvals.ApprovalsBeforeMerge
+		settings.DismissStaleReviews = approvals.ResetApprovalsOnPush
+	}
+	return scm.BranchProtection{Status: scm.FeatureEnabled, Settings: settings}, nil
+}
+
+// retrieves the releases of the project along with the tags never released, which are treated as releases
+// published when their commit was made.  Flags when truncated by the page limit.
+func (m RepositoryDataCollector) getReleases(ctx context.Context, org string, name string) ([]scm.Release, bool, error) {
+	allReleases := []scm.Release{}
+	truncated := false
+	for page, loopCnt := 1, 1; page != 0; loopCnt++ {
+		if pageLimitReached(m.PageLimits.Releases, loopCnt) {
+			glog.Warningf("Project has more than %d releases: %s/%s", len(allReleases), org, name)
+			truncated = true
+			break
+		}
+
+		glog.V(2).Infof("Collecting releases for %s/%s, count per page = %d, page number = %d", org, name, perPage, page)
+		var releases []release
+		next, err := m.Client.Get(ctx, projectPath(org, name)+"/releases", pageQuery(page, nil), &releases)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, r := range releases {
+			allReleases = append(allReleases, scm.Release{
+				Name:      r.Name,
+				Tag:       r.TagName,
+				Draft:     r.UpcomingRelease,
+				Created:   r.CreatedAt,
+				Published: r.ReleasedAt,
+			})
+		}
+		page = next
+	}
+
+	tagCount := 0
+	for page, loopCnt := 1, 1; page != 0; loopCnt++ {
+		if pageLimitReached(m.PageLimits.Releases, loopCnt) {
+			glog.Warningf("Project has more than %d tags: %s/%s", tagCount, org, name)
+			return allReleases, true, nil
+		}
+
+		glog.V(2).Infof("Collecting tags for %s/%s, count per page = %d, page number = %d", org, name, perPage, page)
+		var tags []tag
+		next, err := m.Client.Get(ctx, projectPath(org, name)+"/repository/tags", pageQuery(page, nil), &tags)
+		if err != nil {
+			return nil, false, err
+		}
+		tagCount += len(tags)
+		for _, t := range tags {
+			if t.Release != nil {
+				continue
+			}
+			allReleases = append(allReleases, scm.Release{
+				Name:      t.Name,
+				Tag:       t.Name,
+				Created:   t.Commit.CommittedDate,
+				Published: t.Commit.CommittedDate,
+			})
+		}
+		page = next
+	}
+	return allReleases, truncated, nil
+}
+
+// retrieves the languages of the project as percentages of the code
+func (m RepositoryDataCollector) getLanguages(ctx context.Context, org string, name string) (map[string]float64, error) {
+	glog.V(2).Infof("Collecting languages for %s/%s", org, name)
+	languages := make(map[string]float64)
+	if _, err := m.Client.Get(ctx, projectPath(org, name)+"/languages", nil, &languages); err != nil {
+		return nil, err
+	}
+	return languages, nil
+}
+
+// retrieves the contributors of the project along with their commit counts.  Flags when truncated by the page
+// limit.
+func (m RepositoryDataCollector) getContributors(ctx context.Context, org string, name string) ([]scm.Contributor, bool, error) {
+	var allContributors []scm.Contributor
+	for page, loopCnt := 1, 1; page != 0; loopCnt++ {
+		if pageLimitReached(m.PageLimits.Contributors, loopCnt) {
+			glog.Warningf("Project has more than %d contributors: %s/%s", len(allContributors), org, name)
+			return allContributors, true, nil
+		}
+
+		glog.V(2).Infof("Collecting contributors for %s/%s, count per page = %d, page number = %d", org, name, perPage, page)
+		var contributors []contributor
+		next, err := m.Client.Get(ctx, projectPath(org, name)+"/repository/contributors", pageQuery(page, nil), &contributors)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, c := range contributors {
+			allContributors = append(allContributors, scm.Contributor{Login: c.Name, Commits: c.Commits})
+		}
+		page = next
+	}
+	return allContributors, false, nil
+}
+
+// size the language percentages in bytes from the size of the repository, nil when the languages weren't
+// collected.  The statistics are only reported to members with at least reporter access, without them the
+// languages are weighted by their percentage in hundredths of a percent.
+func sizeLanguages(org string, name string, languages map[string]float64, p *project) map[string]int {
+	if languages == nil {
+		return nil
+	}
+
+	sized := make(map[string]int, len(languages))
+	if p.Statistics == nil {
+		glog.Warningf("Project statistics unavailable to size the languages, weighting them by percentage: %s/%s", org, name)
+		for language, pct := range languages {
+			sized[language] = int(math.Round(pct * 100))
+		}
+		return sized
+	}
+	for language, pct := range languages {
+		sized[language] = int(math.Round(pct / 100 * float64(p.Statistics.RepositorySize)))
+	}
+	return sized
+}
+
+// name of the project as a repository of the group, the path within the group for the projects of subgroups
+func (p *project) name(org string) string {
+	if name := strings.TrimPrefix(p.PathWithNamespace, org+"/"); name != p.PathWithNamespace {
+		return name
+	}
+	return p.Path
+}
+
+// settings of the project in the provider neutral form
+func (p *project) detail() scm.Detail {
+	return scm.Detail{
+		Created:       p.CreatedAt,
+		Updated:       p.UpdatedAt,
+		Pushed:        p.LastActivityAt,
+		DefaultBranch: p.DefaultBranch,
+		Squashable:    p.SquashOption != "never",
+		Rebaseable:    p.MergeMethod == "rebase_merge" || p.MergeMethod == "ff",
+		Archived:      p.Archived,
+		Fork:          p.ForkedFromProject != nil,
+		Private:       p.Visibility == "private",
+		Visibility:    p.Visibility,
+	}
+}
+
+// topics of the project, older GitLab versions reporting them as tags
+func (p *project) topics() []string {
+	if p.Topics != nil {
+		return p.Topics
+	}
+	return p.TagList
+}
+
+// latest of the project's creation, update and activity timestamps
+func (p *project) changed() *time.Time {
+	var latest *time.Time
+	for _, ts := range []*time.Time{p.CreatedAt, p.UpdatedAt, p.LastActivityAt} {
+		if ts != nil && (latest == nil || ts.After(*latest)) {
+			latest = ts
+		}
+	}
+	return latest
+}
+
+// determine if the page about to be collected is beyond the limit, the default limit applying when zero and no
+// limit when negative
+func pageLimitReached(limit int, page int) bool {
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	return limit > 0 && page > limit
+}
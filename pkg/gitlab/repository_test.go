@@ -0,0 +1,348 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// fake GitLab API answering requests by path with the JSON bodies, not found for any other path
+func testServer(t *testing.T, responses map[string]string) *RepositoryDataCollector {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+			key += "?page=" + page
+		}
+		body, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "404 Not Found"}`))
+			return
+		}
+		if next, ok := responses[key+"#next"]; ok {
+			w.Header().Set("X-Next-Page", next)
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/api/v4/", "token")
+	assert.NoError(t, err)
+	return &RepositoryDataCollector{Client: client}
+}
+
+const testProject = `{
+	"id": 42,
+	"path": "project",
+	"default_branch": "main",
+	"visibility": "internal",
+	"archived": false,
+	"forked_from_project": {"id": 7},
+	"topics": ["java", "production"],
+	"merge_method": "ff",
+	"squash_option": "never",
+	"only_allow_merge_if_pipeline_succeeds": true,
+	"created_at": "2021-01-01T10:00:00.000Z",
+	"updated_at": "2021-10-01T10:00:00.000Z",
+	"last_activity_at": "2021-10-15T10:00:00.000Z",
+	"statistics": {"repository_size": 2000}
+}`
+
+func TestListOrganizations(t *testing.T) {
+	m := testServer(t, map[string]string{
+		"/api/v4/groups":        `[{"id": 1, "full_path": "first"}]`,
+		"/api/v4/groups#next":   "2",
+		"/api/v4/groups?page=2": `[{"id": 2, "full_path": "second"}]`,
+	})
+
+	orgs, err := m.ListOrganizations(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, orgs)
+}
+
+func TestListRepositories(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v4/groups/group/projects", r.URL.Path)
+		query = r.URL.Query().Get("last_activity_after")
+		w.Write([]byte(`[` + testProject + `]`))
+	}))
+	defer server.Close()
+	client, _ := NewClient(server.URL+"/api/v4/", "token")
+	m := RepositoryDataCollector{Client: client}
+
+	changedAfter := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	repos, err := m.ListRepositories(context.Background(), "group", &changedAfter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2021-10-01T00:00:00Z", query)
+	if assert.Len(t, repos, 1) {
+		repo := repos[0]
+		assert.Equal(t, int64(42), repo.ID)
+		assert.Equal(t, "gitlab", repo.Provider)
+		assert.Equal(t, "group", repo.Org)
+		assert.Equal(t, "project", repo.Name)
+		assert.Equal(t, []string{"java", "production"}, repo.Topics)
+		assert.Equal(t, time.Date(2021, 10, 15, 10, 0, 0, 0, time.UTC), *repo.Changed)
+		assert.Equal(t, "main", repo.Detail.DefaultBranch)
+		assert.Equal(t, "internal", repo.Detail.Visibility)
+		assert.True(t, repo.Detail.Fork)
+		assert.False(t, repo.Detail.Private)
+		assert.False(t, repo.Detail.Squashable)
+		assert.True(t, repo.Detail.Rebaseable)
+	}
+}
+
+func TestListRepositories_IncludeSubgroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("include_subgroups"))
+		w.Write([]byte(`[
+			{"id": 42, "path": "project", "path_with_namespace": "group/project"},
+			{"id": 43, "path": "nested", "path_with_namespace": "group/subgroup/nested"}
+		]`))
+	}))
+	defer server.Close()
+	client, _ := NewClient(server.URL+"/api/v4/", "token")
+	m := RepositoryDataCollector{Client: client, IncludeSubgroups: true}
+
+	repos, err := m.ListRepositories(context.Background(), "group", nil)
+
+	assert.NoError(t, err)
+	if assert.Len(t, repos, 2) {
+		assert.Equal(t, "project", repos[0].Name)
+		assert.Equal(t, "subgroup/nested", repos[1].Name)
+	}
+}
+
+func TestGetRepository(t *testing.T) {
+	m := testServer(t, map[string]string{
+		"/api/v4/projects/group/project": testProject,
+		"/api/v4/projects/group/project/repository/branches": `[
+			{"name": "main", "protected": true, "merged": false, "commit": {"committed_date": "2021-10-15T10:00:00.000Z"}},
+			{"name": "feature", "protected": false, "merged": true, "commit": {"committed_date": "2021-09-01T10:00:00.000Z"}}
+		]`,
+		"/api/v4/projects/group/project/protected_branches/main": `{"name": "main", "allow_force_push": false, "code_owner_approval_required": true}`,
+		"/api/v4/projects/group/project/approvals":               `{"approvals_before_merge": 2, "reset_approvals_on_push": true}`,
+		"/api/v4/projects/group/project/releases": `[
+			{"name": "Next", "tag_name": "v2.0.0", "upcoming_release": true, "created_at": "2021-10-01T10:00:00.000Z", "released_at": "2021-12-01T10:00:00.000Z"},
+			{"name": "First", "tag_name": "v1.0.0", "created_at": "2021-06-01T10:00:00.000Z", "released_at": "2021-06-01T10:00:00.000Z"}
+		]`,
+		"/api/v4/projects/group/project/repository/tags": `[
+			{"name": "v1.0.0", "commit": {"committed_date": "2021-06-01T09:00:00.000Z"}, "release": {"tag_name": "v1.0.0"}},
+			{"name": "v0.9.0", "commit": {"committed_date": "2021-05-01T09:00:00.000Z"}}
+		]`,
+		"/api/v4/projects/group/project/merge_requests": `[
+			{"id": 1001, "iid": 2, "state": "opened", "author": {"username": "dev"}, "reviewers": [{"username": "lead"}], "created_at": "2021-10-10T10:00:00.000Z", "updated_at": "2021-10-11T10:00:00.000Z"},
+			{"id": 1000, "iid": 1, "state": "merged", "author": {"username": "dev"}, "created_at": "2021-10-01T10:00:00.000Z", "updated_at": "2021-10-02T10:00:00.000Z", "merged_at": "2021-10-02T10:00:00.000Z"}
+		]`,
+		"/api/v4/projects/group/project/merge_requests/2/notes": `[]`,
+		"/api/v4/projects/group/project/merge_requests/1/notes": `[
+			{"id": 1, "body": "looks good", "system": false, "author": {"username": "lead"}, "created_at": "2021-10-01T12:00:00.000Z", "position": {"head_sha": "abc"}},
+			{"id": 2, "body": "added 1 commit", "system": true, "author": {"username": "dev"}, "created_at": "2021-10-01T13:00:00.000Z"},
+			{"id": 3, "body": "approved this merge request", "system": true, "author": {"username": "lead"}, "created_at": "2021-10-01T14:00:00.000Z"}
+		]`,
+		"/api/v4/projects/group/project/languages":               `{"Go": 75.5, "Shell": 24.5}`,
+		"/api/v4/projects/group/project/repository/contributors": `[{"name": "Dev", "email": "dev@example.com", "commits": 12}]`,
+	})
+
+	repo, err := m.GetRepository(context.Background(), "group", "project", scm.CollectOptions{})
+
+	assert.NoError(t, err)
+	assert.Nil(t, repo.SectionErrors)
+	assert.Equal(t, int64(42), repo.ID)
+	assert.Equal(t, "gitlab", repo.Provider)
+	assert.Equal(t, []string{"java", "production"}, repo.Topics)
+
+	assert.Equal(t, []scm.Branch{{Name: "main", Protected: true}, {Name: "feature"}}, repo.Branches)
+	if assert.Contains(t, repo.BranchActivity, "feature") {
+		assert.True(t, repo.BranchActivity["feature"].Merged)
+	}
+	assert.NotContains(t, repo.BranchActivity, "main")
+
+	assert.Equal(t, scm.FeatureEnabled, repo.Protection.Status)
+	assert.Equal(t, &scm.ProtectionSettings{
+		RequiredApprovingReviewCount: 2,
+		DismissStaleReviews:          true,
+		RequireCodeOwnerReviews:      true,
+		RequiredStatusChecks:         []string{"pipeline"},
+		RequireLinearHistory:         true,
+	}, repo.Protection.Settings)
+
+	if assert.Len(t, repo.Releases, 3) {
+		assert.Equal(t, "v2.0.0", repo.Releases[0].Tag)
+		assert.True(t, repo.Releases[0].Draft)
+		assert.Equal(t, "v1.0.0", repo.Releases[1].Tag)
+		assert.Equal(t, "v0.9.0", repo.Releases[2].Tag)
+		assert.Equal(t, time.Date(2021, 5, 1, 9, 0, 0, 0, time.UTC), *repo.Releases[2].Published)
+	}
+
+	if assert.Len(t, repo.PullRequests, 2) {
+		assert.Equal(t, "open", repo.PullRequests[0].State)
+		assert.Equal(t, 1, repo.PullRequests[0].RequestedReviewers)
+		assert.Equal(t, "closed", repo.PullRequests[1].State)
+		assert.Equal(t, repo.PullRequests[1].Merged, repo.PullRequests[1].Closed)
+	}
+	assert.Empty(t, repo.Reviews[2])
+	if assert.Len(t, repo.Reviews[1], 2) {
+		assert.Equal(t, "COMMENTED", repo.Reviews[1][0].State)
+		assert.Equal(t, "abc", repo.Reviews[1][0].CommitID)
+		assert.Equal(t, "APPROVED", repo.Reviews[1][1].State)
+		assert.Equal(t, "lead", repo.Reviews[1][1].Author)
+	}
+
+	assert.Equal(t, map[string]int{"Go": 1510, "Shell": 490}, repo.Languages)
+	assert.Equal(t, []scm.Contributor{{Login: "Dev", Commits: 12}}, repo.Contributors)
+	assert.Equal(t, scm.FeatureUnknown, repo.Security.CodeScanning)
+	assert.Nil(t, repo.Workflows)
+	assert.Nil(t, repo.Issues)
+}
+
+func TestGetRepository_SectionFailures(t *testing.T) {
+	// no statistics, protection, releases, tags, merge requests or contributors
+	m := testServer(t, map[string]string{
+		"/api/v4/projects/group/project":                     `{"id": 42, "path": "project", "default_branch": "main", "visibility": "private"}`,
+		"/api/v4/projects/group/project/repository/branches": `[{"name": "main", "protected": false}]`,
+		"/api/v4/projects/group/project/languages":           `{"Go": 100}`,
+	})
+
+	repo, err := m.GetRepository(context.Background(), "group", "project", scm.CollectOptions{})
+
+	assert.NoError(t, err)
+	assert.True(t, repo.Detail.Private)
+	assert.True(t, repo.Detail.Squashable)
+	assert.Equal(t, scm.FeatureNotEnabled, repo.Protection.Status)
+	assert.Nil(t, repo.Protection.Settings)
+	assert.Equal(t, map[string]int{"Go": 10000}, repo.Languages)
+	assert.Len(t, repo.SectionErrors, 3)
+	assert.Contains(t, repo.SectionErrors, scm.SectionReleases)
+	assert.Contains(t, repo.SectionErrors, scm.SectionPullRequests)
+	assert.Contains(t, repo.SectionErrors, scm.SectionContributors)
+}
+
+func TestCollectBranchProtection_Unknown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "403 Forbidden"}`))
+	}))
+	defer server.Close()
+	client, _ := NewClient(server.URL+"/api/v4/", "token")
+	m := RepositoryDataCollector{Client: client}
+
+	protection, err := m.collectBranchProtection(context.Background(), "group", "project", &project{DefaultBranch: "main"})
+
+	assert.Error(t, err)
+	assert.Equal(t, scm.FeatureUnknown, protection.Status)
+	assert.Nil(t, protection.Settings)
+}
+
+func TestGetRepository_NotFound(t *testing.T) {
+	m := testServer(t, nil)
+
+	_, err := m.GetRepository(context.Background(), "group", "missing", scm.CollectOptions{})
+
+	assert.True(t, isNotFound(err))
+}
+
+func TestGetMergeRequests_PageLimit(t *testing.T) {
+	m := testServer(t, map[string]string{
+		"/api/v4/projects/group/project/merge_requests":        `[{"id": 1001, "iid": 2, "state": "closed"}]`,
+		"/api/v4/projects/group/project/merge_requests#next":   "2",
+		"/api/v4/projects/group/project/merge_requests?page=2": `[{"id": 1000, "iid": 1, "state": "locked"}]`,
+	})
+	m.PageLimits.PullRequests = 1
+
+	prs, truncated, err := m.getMergeRequests(context.Background(), "group", "project", nil)
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, []scm.PullRequest{{ID: 1001, Number: 2, State: "closed"}}, prs)
+}
+
+func TestGetReviews_PageLimit(t *testing.T) {
+	m := testServer(t, map[string]string{
+		"/api/v4/projects/group/project/merge_requests/1/notes":        `[{"id": 11, "body": "first", "author": {"username": "reviewer"}}]`,
+		"/api/v4/projects/group/project/merge_requests/1/notes#next":   "2",
+		"/api/v4/projects/group/project/merge_requests/1/notes?page=2": `[{"id": 12, "body": "second", "author": {"username": "reviewer"}}]`,
+		"/api/v4/projects/group/project/merge_requests/2/notes":        `[{"id": 21, "body": "only", "author": {"username": "reviewer"}}]`,
+	})
+	m.PageLimits.PullRequests = 1
+
+	reviews, truncated, err := m.getReviews(context.Background(), "group", "project", []scm.PullRequest{{Number: 1}, {Number: 2}})
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Len(t, reviews[1], 1)
+	assert.Equal(t, int64(11), reviews[1][0].ID)
+	assert.Len(t, reviews[2], 1)
+}
+
+func TestGetReleases_TagPageLimit(t *testing.T) {
+	m := testServer(t, map[string]string{
+		"/api/v4/projects/group/project/releases":               `[{"name": "Release 1", "tag_name": "v1.0.0"}]`,
+		"/api/v4/projects/group/project/repository/tags":        `[{"name": "v1.0.0", "release": {"tag_name": "v1.0.0"}}, {"name": "v0.9.0", "commit": {}}]`,
+		"/api/v4/projects/group/project/repository/tags#next":   "2",
+		"/api/v4/projects/group/project/repository/tags?page=2": `[{"name": "v0.8.0", "commit": {}}]`,
+	})
+	m.PageLimits.Releases = 1
+
+	releases, truncated, err := m.getReleases(context.Background(), "group", "project")
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	if assert.Len(t, releases, 2) {
+		assert.Equal(t, "v1.0.0", releases[0].Tag)
+		assert.Equal(t, "v0.9.0", releases[1].Tag)
+	}
+}
+
+func TestGetContributors_PageLimit(t *testing.T) {
+	m := testServer(t, map[string]string{
+		"/api/v4/projects/group/project/repository/contributors":        `[{"name": "Dev", "commits": 12}]`,
+		"/api/v4/projects/group/project/repository/contributors#next":   "2",
+		"/api/v4/projects/group/project/repository/contributors?page=2": `[{"name": "Other", "commits": 3}]`,
+	})
+	m.PageLimits.Contributors = 1
+
+	contributors, truncated, err := m.getContributors(context.Background(), "group", "project")
+
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, []scm.Contributor{{Login: "Dev", Commits: 12}}, contributors)
+}
+
+func Test_noteReview(t *testing.T) {
+	tests := []struct {
+		name     string
+		note     note
+		expected string
+		ok       bool
+	}{
+		{"comment", note{Body: "nit"}, "COMMENTED", true},
+		{"approval", note{Body: noteApproved, System: true}, "APPROVED", true},
+		{"approval withdrawn", note{Body: noteUnapproved, System: true}, "DISMISSED", true},
+		{"other system note", note{Body: "changed the description", System: true}, "", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			review, ok := tt.note.review()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, review.State)
+		})
+	}
+}
+
+func Test_pageLimitReached(t *testing.T) {
+	assert.False(t, pageLimitReached(0, 10))
+	assert.True(t, pageLimitReached(0, 11))
+	assert.True(t, pageLimitReached(2, 3))
+	assert.False(t, pageLimitReached(-1, 100))
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// oldestBranchCount number of the oldest branches listed in the branch age metrics
+const oldestBranchCount = 10
+
+// BranchAgeMetric defines structure for the age of the branches other than the default branch, by the time
+// since their last commit, along with the merged branches not yet deleted
+type BranchAgeMetric struct {
+	LessThan30DaysCount   int         `json:"lessThan30DaysCount" bson:"lessThan30DaysCount"`
+	From30To90DaysCount   int         `json:"from30To90DaysCount" bson:"from30To90DaysCount"`
+	Over90DaysCount       int         `json:"over90DaysCount" bson:"over90DaysCount"`
+	MergedNotDeletedCount int         `json:"mergedNotDeletedCount" bson:"mergedNotDeletedCount"`
+	MergedNotDeleted      []string    `json:"mergedNotDeleted" bson:"mergedNotDeleted"`
+	Oldest                []BranchAge `json:"oldest" bson:"oldest"`
+}
+
+// BranchAge defines structure for the age of a branch
+type BranchAge struct {
+	Name       string     `json:"name" bson:"name"`
+	LastCommit *time.Time `json:"lastCommit" bson:"lastCommit"`
+	AgeDays    int        `json:"ageDays" bson:"ageDays"`
+	Merged     bool       `json:"merged" bson:"merged"`
+}
+
+// newBranchAgeMetric branch age metrics from the branch activity, nil when the activity wasn't collected
+func newBranchAgeMetric(activity map[string]scm.BranchActivity, now time.Time) *BranchAgeMetric {
+	if activity == nil {
+		return nil
+	}
+
+	metric := &BranchAgeMetric{}
+	var ages []BranchAge
+	for name, a := range activity {
+		if a.Merged {
+			metric.MergedNotDeletedCount++
+			metric.MergedNotDeleted = append(metric.MergedNotDeleted, name)
+		}
+		if a.LastCommit == nil {
+			continue
+		}
+
+		age := BranchAge{Name: name, LastCommit: a.LastCommit, AgeDays: int(now.Sub(*a.LastCommit).Hours() / 24), Merged: a.Merged}
+		switch {
+		case age.AgeDays < 30:
+			metric.LessThan30DaysCount++
+		case age.AgeDays <= 90:
+			metric.From30To90DaysCount++
+		default:
+			metric.Over90DaysCount++
+		}
+		ages = append(ages, age)
+	}
+	sort.Strings(metric.MergedNotDeleted)
+
+	sort.Slice(ages, func(i, j int) bool {
+		if ages[i].AgeDays != ages[j].AgeDays {
+			return ages[i].AgeDays > ages[j].AgeDays
+		}
+		return ages[i].Name < ages[j].Name
+	})
+	if len(ages) > oldestBranchCount {
+		ages = ages[:oldestBranchCount]
+	}
+	metric.Oldest = ages
+	return metric
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func Test_newBranchAgeMetric(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		ts := now.AddDate(0, 0, -days)
+		return &ts
+	}
+	activity := map[string]scm.BranchActivity{
+		"feature-new":   {LastCommit: daysAgo(2)},
+		"feature-month": {LastCommit: daysAgo(45)},
+		"merged-old":    {LastCommit: daysAgo(200), Merged: true},
+		"merged-new":    {LastCommit: daysAgo(10), Merged: true},
+		"abandoned":     {LastCommit: daysAgo(120)},
+		"unknown":       {},
+	}
+
+	metric := newBranchAgeMetric(activity, now)
+
+	assert.Equal(t, 2, metric.LessThan30DaysCount)
+	assert.Equal(t, 1, metric.From30To90DaysCount)
+	assert.Equal(t, 2, metric.Over90DaysCount)
+	assert.Equal(t, 2, metric.MergedNotDeletedCount)
+	assert.Equal(t, []string{"merged-new", "merged-old"}, metric.MergedNotDeleted)
+	assert.Equal(t, 5, len(metric.Oldest))
+	assert.Equal(t, BranchAge{Name: "merged-old", LastCommit: daysAgo(200), AgeDays: 200, Merged: true}, metric.Oldest[0])
+	assert.Equal(t, "abandoned", metric.Oldest[1].Name)
+	assert.Equal(t, "feature-new", metric.Oldest[4].Name)
+}
+
+func Test_newBranchAgeMetric_OldestLimited(t *testing.T) {
+	now := time.Now()
+	activity := make(map[string]scm.BranchActivity)
+	for i := 0; i < oldestBranchCount+5; i++ {
+		ts := now.AddDate(0, 0, -i)
+		activity[fmt.Sprintf("branch-%02d", i)] = scm.BranchActivity{LastCommit: &ts}
+	}
+
+	metric := newBranchAgeMetric(activity, now)
+
+	assert.Equal(t, oldestBranchCount, len(metric.Oldest))
+	assert.Equal(t, "branch-14", metric.Oldest[0].Name)
+}
+
+func Test_newBranchAgeMetric_NotCollected(t *testing.T) {
+	assert.Nil(t, newBranchAgeMetric(nil, time.Now()))
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"regexp"
 	"time"
 )
@@ -25,10 +26,10 @@ type Key struct {
 
 // DataManager Interface for implementing a metric persistence layer
 type DataManager interface {
-	StoreMetrics(metrics GitRepositoryMetric) error
-	ReadMetrics(org string, repo string) (found bool, metric *GitRepositoryMetric, err error)
-	DeleteMetrics(org string, repo string) error
-	ListMetrics(options ListMetricOptions) ([]Key, error)
-	StoreCacheStats(org string, stats CacheStats)
-	ReadCacheStats(org string) (found bool, stats *CacheStats)
+	StoreMetrics(ctx context.Context, metrics GitRepositoryMetric) error
+	ReadMetrics(ctx context.Context, org string, repo string) (found bool, metric *GitRepositoryMetric, err error)
+	DeleteMetrics(ctx context.Context, org string, repo string) error
+	ListMetrics(ctx context.Context, options ListMetricOptions) ([]Key, error)
+	StoreCacheStats(ctx context.Context, org string, stats CacheStats)
+	ReadCacheStats(ctx context.Context, org string) (found bool, stats *CacheStats)
 }
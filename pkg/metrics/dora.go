@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// DORA metric sources, releases used when the repository has no deployments
+const (
+	doraSourceDeployments = "deployments"
+	doraSourceReleases    = "releases"
+)
+
+// DORAMetric defines structure for the DORA metrics of a deployment environment over a day window
+type DORAMetric struct {
+	WindowDays            int      `json:"windowDays" bson:"windowDays"`
+	Environment           string   `json:"environment" bson:"environment"`
+	Source                string   `json:"source" bson:"source"`
+	DeploymentCount       int      `json:"deploymentCount" bson:"deploymentCount"`
+	DeploymentsPerDay     float32  `json:"deploymentsPerDay" bson:"deploymentsPerDay"`
+	MedianLeadTimeMinutes *float64 `json:"medianLeadTimeMinutes" bson:"medianLeadTimeMinutes"`
+	ChangeFailureRatePct  *float32 `json:"changeFailureRatePct" bson:"changeFailureRatePct"`
+	MedianRestoreMinutes  *float64 `json:"medianRestoreMinutes" bson:"medianRestoreMinutes"`
+}
+
+// deployment outcome derived from the deployment statuses
+type deploymentOutcome struct {
+	sha        string
+	createdAt  time.Time
+	deployedAt *time.Time
+	failed     bool
+}
+
+// newDORAMetrics DORA metrics for each of the day windows ending now, calculated by deployment environment
+// or from the releases when the repository has no deployments.  Lead time is measured from a pull request
+// merge to the next successful deployment (or release) after it.
+func newDORAMetrics(r *scm.Repository, prs []PullRequestMetric, windows []int, now time.Time) []DORAMetric {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	var dora []DORAMetric
+	if len(r.Deployments) == 0 {
+		glog.V(2).Infof("No deployments found for %s/%s, calculating DORA metrics from releases", r.Org, r.Name)
+		published := releaseTimes(r.Releases)
+		for _, days := range windows {
+			dora = append(dora, releaseDORAMetric(published, prs, days, now))
+		}
+		return dora
+	}
+
+	byEnvironment := deploymentOutcomes(r.Deployments, r.DeploymentStatuses)
+	var environments []string
+	for env := range byEnvironment {
+		environments = append(environments, env)
+	}
+	sort.Strings(environments)
+
+	for _, days := range windows {
+		for _, env := range environments {
+			dora = append(dora, deploymentDORAMetric(env, byEnvironment[env], prs, days, now))
+		}
+	}
+	return dora
+}
+
+// DORA metric for the deployments to an environment over the day window
+func deploymentDORAMetric(env string, outcomes []deploymentOutcome, prs []PullRequestMetric, days int, now time.Time) DORAMetric {
+	start := now.AddDate(0, 0, -days)
+	metric := DORAMetric{WindowDays: days, Environment: env, Source: doraSourceDeployments}
+
+	var deployed []time.Time
+	var restore []float64
+	var completed, failed int
+	for i, o := range outcomes {
+		if o.deployedAt != nil {
+			deployed = append(deployed, *o.deployedAt)
+		}
+		if o.createdAt.Before(start) {
+			continue
+		}
+		if o.deployedAt != nil {
+			metric.DeploymentCount++
+		}
+		if o.deployedAt == nil && !o.failed {
+			continue
+		}
+		completed++
+		if !o.failed {
+			continue
+		}
+		failed++
+
+		// restored by the next successful deployment that wasn't itself a failure
+		for _, next := range outcomes[i+1:] {
+			if next.deployedAt != nil && !next.failed {
+				restore = append(restore, next.deployedAt.Sub(o.createdAt).Minutes())
+				break
+			}
+		}
+	}
+
+	metric.DeploymentsPerDay = float32(metric.DeploymentCount) / float32(days)
+	metric.MedianLeadTimeMinutes = medianOrNil(leadTimes(deployed, prs, start))
+	metric.MedianRestoreMinutes = medianOrNil(restore)
+	if completed > 0 {
+		pct := float32(failed) * 100 / float32(completed)
+		metric.ChangeFailureRatePct = &pct
+	}
+	return metric
+}
+
+// DORA metric for the releases published over the day window, failures can't be determined from releases
+func releaseDORAMetric(published []time.Time, prs []PullRequestMetric, days int, now time.Time) DORAMetric {
+	start := now.AddDate(0, 0, -days)
+	metric := DORAMetric{WindowDays: days, Source: doraSourceReleases}
+	for _, p := range published {
+		if !p.Before(start) {
+			metric.DeploymentCount++
+		}
+	}
+	metric.DeploymentsPerDay = float32(metric.DeploymentCount) / float32(days)
+	metric.MedianLeadTimeMinutes = medianOrNil(leadTimes(published, prs, start))
+	return metric
+}
+
+// deployment outcomes by environment in creation order.  A deployment failed when its latest status is a
+// failure or error, or when rolled back by the next deployment redeploying an earlier commit.
+func deploymentOutcomes(deployments []scm.Deployment, statuses map[int64][]scm.DeploymentStatus) map[string][]deploymentOutcome {
+	byEnvironment := make(map[string][]deploymentOutcome)
+	for _, d := range deployments {
+		if d.Created == nil {
+			continue
+		}
+		outcome := deploymentOutcome{sha: d.SHA, createdAt: *d.Created}
+
+		var latest *scm.DeploymentStatus
+		for i, s := range statuses[d.ID] {
+			if s.Created == nil {
+				continue
+			}
+			if latest == nil || s.Created.After(*latest.Created) {
+				latest = &statuses[d.ID][i]
+			}
+			if s.State == "success" && (outcome.deployedAt == nil || s.Created.Before(*outcome.deployedAt)) {
+				outcome.deployedAt = s.Created
+			}
+		}
+		if latest != nil && (latest.State == "failure" || latest.State == "error") {
+			outcome.failed = true
+		}
+
+		env := d.Environment
+		byEnvironment[env] = append(byEnvironment[env], outcome)
+	}
+
+	for _, outcomes := range byEnvironment {
+		sort.Slice(outcomes, func(i, j int) bool {
+			return outcomes[i].createdAt.Before(outcomes[j].createdAt)
+		})
+		for i := 1; i < len(outcomes)-1; i++ {
+			next := outcomes[i+1].sha
+			if next == outcomes[i].sha {
+				continue
+			}
+			for _, earlier := range outcomes[:i] {
+				if earlier.sha == next {
+					outcomes[i].failed = true
+					break
+				}
+			}
+		}
+	}
+	return byEnvironment
+}
+
+// published times of the releases, drafts excluded
+func releaseTimes(releases []scm.Release) []time.Time {
+	var published []time.Time
+	for _, r := range releases {
+		if r.Draft {
+			continue
+		}
+		if ts := releaseTime(r); ts != nil {
+			published = append(published, *ts)
+		}
+	}
+	return published
+}
+
+// minutes from each merged pull request to the next deployment after the merge, limited to deployments since
+// the start of the window
+func leadTimes(deployed []time.Time, prs []PullRequestMetric, start time.Time) []float64 {
+	sorted := append([]time.Time{}, deployed...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Before(sorted[j])
+	})
+
+	var lead []float64
+	for _, pr := range prs {
+		if pr.MergedAt == nil {
+			continue
+		}
+		idx := sort.Search(len(sorted), func(i int) bool {
+			return !sorted[i].Before(*pr.MergedAt)
+		})
+		if idx == len(sorted) || sorted[idx].Before(start) {
+			continue
+		}
+		lead = append(lead, sorted[idx].Sub(*pr.MergedAt).Minutes())
+	}
+	return lead
+}
+
+// median of the supplied values, nil when none supplied
+func medianOrNil(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	m := median(values)
+	return &m
+}
+
+// largest of the day windows, zero when none supplied
+func maxWindow(windows []int) int {
+	days := 0
+	for _, w := range windows {
+		if w > days {
+			days = w
+		}
+	}
+	return days
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func testDeployment(id int64, env string, sha string, createdAt time.Time) scm.Deployment {
+	return scm.Deployment{
+		ID:          id,
+		Environment: env,
+		SHA:         sha,
+		Created:     &createdAt,
+	}
+}
+
+func testDeploymentStatus(state string, createdAt time.Time) scm.DeploymentStatus {
+	return scm.DeploymentStatus{
+		State:   state,
+		Created: &createdAt,
+	}
+}
+
+func Test_newDORAMetrics_Deployments(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	merged := now.Add(-4 * day)
+	r := scm.Repository{
+		Deployments: []scm.Deployment{
+			testDeployment(4, "production", "a", now.Add(-2*day)),
+			testDeployment(3, "production", "c", now.Add(-3*day)),
+			testDeployment(2, "production", "b", now.Add(-5*day)),
+			testDeployment(1, "production", "a", now.Add(-10*day)),
+			testDeployment(5, "staging", "c", now.Add(-3*day)),
+		},
+		DeploymentStatuses: map[int64][]scm.DeploymentStatus{
+			1: {testDeploymentStatus("inactive", now.Add(-3*day)), testDeploymentStatus("success", now.Add(-10*day+10*time.Minute))},
+			2: {testDeploymentStatus("failure", now.Add(-5*day+time.Minute))},
+			3: {testDeploymentStatus("success", now.Add(-3*day+5*time.Minute))},
+			4: {testDeploymentStatus("success", now.Add(-2*day+time.Minute)), testDeploymentStatus("in_progress", now.Add(-2*day))},
+			5: {testDeploymentStatus("success", now.Add(-3*day+time.Minute))},
+		},
+	}
+	prs := []PullRequestMetric{
+		{Number: 1, MergedAt: &merged},
+		{Number: 2},
+	}
+
+	dora := newDORAMetrics(&r, prs, []int{7, 30}, now)
+
+	assert.Equal(t, 4, len(dora))
+
+	// deployment 2 failed and deployment 3 was rolled back by redeploying an earlier commit
+	week := dora[0]
+	assert.Equal(t, 7, week.WindowDays)
+	assert.Equal(t, "production", week.Environment)
+	assert.Equal(t, "deployments", week.Source)
+	assert.Equal(t, 2, week.DeploymentCount)
+	assert.Equal(t, float32(2)/7, week.DeploymentsPerDay)
+	assert.Equal(t, 1445.0, *week.MedianLeadTimeMinutes)
+	assert.InDelta(t, 66.67, *week.ChangeFailureRatePct, 0.01)
+	assert.Equal(t, 2881.0, *week.MedianRestoreMinutes)
+
+	assert.Equal(t, 7, dora[1].WindowDays)
+	assert.Equal(t, "staging", dora[1].Environment)
+	assert.Equal(t, 1, dora[1].DeploymentCount)
+	assert.Equal(t, float32(0), *dora[1].ChangeFailureRatePct)
+	assert.Nil(t, dora[1].MedianRestoreMinutes)
+
+	month := dora[2]
+	assert.Equal(t, 30, month.WindowDays)
+	assert.Equal(t, "production", month.Environment)
+	assert.Equal(t, 3, month.DeploymentCount)
+	assert.Equal(t, float32(50), *month.ChangeFailureRatePct)
+}
+
+func Test_newDORAMetrics_Releases(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	merged := now.Add(-3 * day)
+	published, created, older := now.Add(-day), now.Add(-2*day), now.Add(-20*day)
+	r := scm.Repository{
+		Releases: []scm.Release{
+			{ID: 1, Published: &published},
+			{ID: 2, Draft: true, Created: &created},
+			{ID: 3, Published: &older},
+		},
+	}
+	prs := []PullRequestMetric{{Number: 1, MergedAt: &merged}}
+
+	dora := newDORAMetrics(&r, prs, []int{7, 30}, now)
+
+	assert.Equal(t, 2, len(dora))
+	assert.Equal(t, "releases", dora[0].Source)
+	assert.Equal(t, "", dora[0].Environment)
+	assert.Equal(t, 1, dora[0].DeploymentCount)
+	assert.Equal(t, 2880.0, *dora[0].MedianLeadTimeMinutes)
+	assert.Nil(t, dora[0].ChangeFailureRatePct)
+	assert.Nil(t, dora[0].MedianRestoreMinutes)
+	assert.Equal(t, 2, dora[1].DeploymentCount)
+}
+
+func Test_newDORAMetrics_NoWindows(t *testing.T) {
+	r := scm.Repository{}
+	assert.Nil(t, newDORAMetrics(&r, nil, nil, time.Now()))
+}
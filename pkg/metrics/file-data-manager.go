@@ -1,9 +1,11 @@
 package metrics
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -17,14 +19,14 @@ type FileDataManager struct {
 }
 
 // StoreMetrics Persist the supplied metrics
-func (fdm FileDataManager) StoreMetrics(metrics GitRepositoryMetric) error {
+func (fdm FileDataManager) StoreMetrics(ctx context.Context, metrics GitRepositoryMetric) error {
 	filename := fdm.repositoryFileName(metrics.Org, metrics.RepositoryName)
 	glog.V(2).Infof("Writing metric data for repository %s to file %s", metrics.RepositoryName, filename)
 	return fdm.writeFile(filename, metrics)
 }
 
 // ReadMetrics Read the metrics for supplied repository
-func (fdm FileDataManager) ReadMetrics(org string, repo string) (found bool, metric *GitRepositoryMetric, err error) {
+func (fdm FileDataManager) ReadMetrics(ctx context.Context, org string, repo string) (found bool, metric *GitRepositoryMetric, err error) {
 	metric = &GitRepositoryMetric{}
 	filename := fdm.repositoryFileName(org, repo)
 	glog.V(2).Infof("Reading metric data for repository %s/%s from file %s", org, repo, filename)
@@ -36,7 +38,7 @@ func (fdm FileDataManager) ReadMetrics(org string, repo string) (found bool, met
 }
 
 // DeleteMetrics Delete the metrics for the supplied repository
-func (fdm FileDataManager) DeleteMetrics(org string, repo string) error {
+func (fdm FileDataManager) DeleteMetrics(ctx context.Context, org string, repo string) error {
 	filename := fdm.repositoryFileName(org, repo)
 	glog.V(2).Infof("Deleting metric data for repository %s/%s from file %s", org, repo, filename)
 	err := os.Remove(filename)
@@ -47,7 +49,7 @@ func (fdm FileDataManager) DeleteMetrics(org string, repo string) error {
 }
 
 // ListMetrics List the known repositories with metrics that match the supplied options
-func (fdm FileDataManager) ListMetrics(opts ListMetricOptions) ([]Key, error) {
+func (fdm FileDataManager) ListMetrics(ctx context.Context, opts ListMetricOptions) ([]Key, error) {
 	glog.V(2).Infof("Listing repository metrics found in %s", fdm.DataDir)
 	files, err := ioutil.ReadDir(fdm.DataDir)
 	if err != nil {
@@ -64,8 +66,8 @@ func (fdm FileDataManager) ListMetrics(opts ListMetricOptions) ([]Key, error) {
 			glog.V(2).Infof("Filtered file: %s", name)
 			continue
 		}
-		org := matches[1]
-		repo := matches[2]
+		org := unescapeFileName(matches[1])
+		repo := unescapeFileName(matches[2])
 
 		if opts.orgFilter != nil && !opts.orgFilter.MatchString(org) {
 			glog.V(2).Infof("Filtered entry, org value (%s) didn't match supplied filter(%s)", org, opts.orgFilter)
@@ -84,7 +86,7 @@ func (fdm FileDataManager) ListMetrics(opts ListMetricOptions) ([]Key, error) {
 }
 
 // StoreCacheStats store the statistics for overall cache statistics
-func (fdm FileDataManager) StoreCacheStats(org string, stats CacheStats) {
+func (fdm FileDataManager) StoreCacheStats(ctx context.Context, org string, stats CacheStats) {
 	filename := fdm.cacheStatsFileName(org)
 	glog.V(2).Infof("Writing cache stats to file %s", filename)
 	err := fdm.writeFile(filename, stats)
@@ -94,7 +96,7 @@ func (fdm FileDataManager) StoreCacheStats(org string, stats CacheStats) {
 }
 
 // ReadCacheStats read the overall cache statistics
-func (fdm FileDataManager) ReadCacheStats(org string) (found bool, stats *CacheStats) {
+func (fdm FileDataManager) ReadCacheStats(ctx context.Context, org string) (found bool, stats *CacheStats) {
 	filename := fdm.cacheStatsFileName(org)
 	stats = &CacheStats{}
 	glog.V(2).Infof("Reading cache stats from file %s", filename)
@@ -110,12 +112,22 @@ func (fdm FileDataManager) ReadCacheStats(org string) (found bool, stats *CacheS
 
 // builds the file name for the supplied repository
 func (fdm FileDataManager) repositoryFileName(org string, repoName string) string {
-	return filepath.Join(fdm.DataDir, "org-"+org+".repo-"+repoName+".json")
+	return filepath.Join(fdm.DataDir, "org-"+url.PathEscape(org)+".repo-"+url.PathEscape(repoName)+".json")
 }
 
 // builds the overall cache statistics
 func (fdm FileDataManager) cacheStatsFileName(org string) string {
-	return filepath.Join(fdm.DataDir, "org-"+org+".cache-stats.json")
+	return filepath.Join(fdm.DataDir, "org-"+url.PathEscape(org)+".cache-stats.json")
+}
+
+// org or repository name from the escaped part of a file name, names such as GitLab subgroup projects holding a
+// slash being escaped to stay within the data directory
+func unescapeFileName(name string) string {
+	unescaped, err := url.PathUnescape(name)
+	if err != nil {
+		return name
+	}
+	return unescaped
 }
 
 // write file with supplied name using supplied struct as data
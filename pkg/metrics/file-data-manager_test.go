@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -24,7 +25,7 @@ func TestStoreAndReadAndDeleteMetrics(t *testing.T) {
 	dataMgr := FileDataManager{
 		DataDir: ".",
 	}
-	err := dataMgr.StoreMetrics(m)
+	err := dataMgr.StoreMetrics(context.Background(), m)
 
 	assert.NoError(t, err)
 
@@ -34,14 +35,14 @@ func TestStoreAndReadAndDeleteMetrics(t *testing.T) {
 	_, err = os.Stat(path)
 	assert.NoError(t, err, "expected file is missing or has error")
 	if err == nil {
-		found, afterMetrics, err := dataMgr.ReadMetrics(o, r)
+		found, afterMetrics, err := dataMgr.ReadMetrics(context.Background(), o, r)
 
 		assert.True(t, found)
 		assert.NoError(t, err)
 		assert.Equal(t, m.ID, afterMetrics.ID)
 		assert.Equal(t, m.RepositoryName, afterMetrics.RepositoryName)
 
-		err = dataMgr.DeleteMetrics(o, r)
+		err = dataMgr.DeleteMetrics(context.Background(), o, r)
 
 		assert.NoError(t, err)
 
@@ -50,9 +51,24 @@ func TestStoreAndReadAndDeleteMetrics(t *testing.T) {
 	}
 }
 
+func TestStoreAndListMetrics_NestedName(t *testing.T) {
+	fdm := FileDataManager{DataDir: t.TempDir()}
+	err := fdm.StoreMetrics(context.Background(), GitRepositoryMetric{Org: "group", RepositoryName: "subgroup/project"})
+	assert.NoError(t, err)
+
+	keys, err := fdm.ListMetrics(context.Background(), ListMetricOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Key{{Org: "group", Name: "subgroup/project"}}, keys)
+
+	found, metric, err := fdm.ReadMetrics(context.Background(), "group", "subgroup/project")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "subgroup/project", metric.RepositoryName)
+}
+
 func TestReadMetrics_NotFound(t *testing.T) {
 	dataMgr := FileDataManager{DataDir: "."}
-	found, metrics, err := dataMgr.ReadMetrics("testorg", "test-read-repo")
+	found, metrics, err := dataMgr.ReadMetrics(context.Background(), "testorg", "test-read-repo")
 
 	assert.False(t, found)
 	assert.Nil(t, metrics)
@@ -61,7 +77,7 @@ func TestReadMetrics_NotFound(t *testing.T) {
 
 func TestDeleteMetrics_NotFound(t *testing.T) {
 	dataMgr := FileDataManager{DataDir: "."}
-	err := dataMgr.DeleteMetrics("testorg", "test-del-repo")
+	err := dataMgr.DeleteMetrics(context.Background(), "testorg", "test-del-repo")
 	assert.NoError(t, err)
 }
 
@@ -134,7 +150,7 @@ func TestListRepositories(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			dataMgr := FileDataManager{DataDir: "."}
-			keys, err := dataMgr.ListMetrics(tt.options)
+			keys, err := dataMgr.ListMetrics(context.Background(), tt.options)
 
 			assert.NoError(t, err)
 			assert.Equal(t, len(tt.expected), len(keys))
@@ -163,7 +179,7 @@ func TestStoreAndReadCacheStats(t *testing.T) {
 	dataMgr := FileDataManager{
 		DataDir: ".",
 	}
-	dataMgr.StoreCacheStats("test", s)
+	dataMgr.StoreCacheStats(context.Background(), "test", s)
 
 	path := filepath.Join(".", "org-test.cache-stats.json")
 	defer os.Remove(path)
@@ -171,7 +187,7 @@ func TestStoreAndReadCacheStats(t *testing.T) {
 	_, err := os.Stat(path)
 	assert.NoError(t, err, "expected file is missing or has error")
 	if err == nil {
-		found, afterStats := dataMgr.ReadCacheStats("test")
+		found, afterStats := dataMgr.ReadCacheStats(context.Background(), "test")
 
 		assert.True(t, found)
 		assert.NoError(t, err)
@@ -184,7 +200,7 @@ func TestReadCacheStats_NotFound(t *testing.T) {
 		DataDir: ".",
 	}
 
-	found, stats := dataMgr.ReadCacheStats("test")
+	found, stats := dataMgr.ReadCacheStats(context.Background(), "test")
 
 	assert.False(t, found)
 	assert.Nil(t, stats)
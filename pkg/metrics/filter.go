@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// Selection whether repositories having an attribute (archived, fork or template) are updated
+type Selection string
+
+const (
+	// SelectInclude update repositories regardless of the attribute, the default
+	SelectInclude Selection = "include"
+	// SelectExclude skip repositories having the attribute
+	SelectExclude Selection = "exclude"
+	// SelectOnly only update repositories having the attribute
+	SelectOnly Selection = "only"
+)
+
+// RepositoryFilter selects the repositories of an organization to update, the zero value selecting all of them
+type RepositoryFilter struct {
+	// Include only repositories with names matching, all names when nil
+	Include *regexp.Regexp
+	// Exclude repositories with names matching, none when nil
+	Exclude *regexp.Regexp
+	// RequiredTopics topics a repository must have all of
+	RequiredTopics []string
+	// ForbiddenTopics topics a repository must have none of
+	ForbiddenTopics []string
+	Archived        Selection
+	Forks           Selection
+	Templates       Selection
+	// Visibilities allowed (public, private or internal), all when empty
+	Visibilities []string
+}
+
+// ParseSelection parse the selection name, an empty name including all repositories
+func ParseSelection(name string) (Selection, error) {
+	switch s := Selection(name); s {
+	case "":
+		return SelectInclude, nil
+	case SelectInclude, SelectExclude, SelectOnly:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown repository selection: %s", name)
+	}
+}
+
+// Includes determine if the listed repository passes the filter
+func (f RepositoryFilter) Includes(r scm.Repository) bool {
+	if f.Include != nil && !f.Include.MatchString(r.Name) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(r.Name) {
+		return false
+	}
+	for _, topic := range f.RequiredTopics {
+		if !hasTopic(r.Topics, topic) {
+			return false
+		}
+	}
+	for _, topic := range f.ForbiddenTopics {
+		if hasTopic(r.Topics, topic) {
+			return false
+		}
+	}
+	return f.Archived.selects(r.Detail.Archived) &&
+		f.Forks.selects(r.Detail.Fork) &&
+		f.Templates.selects(r.Detail.Template) &&
+		f.allowsVisibility(visibility(r))
+}
+
+// determine if a repository with or without the attribute is selected
+func (s Selection) selects(has bool) bool {
+	switch s {
+	case SelectExclude:
+		return !has
+	case SelectOnly:
+		return has
+	default:
+		return true
+	}
+}
+
+// determine if the visibility is allowed
+func (f RepositoryFilter) allowsVisibility(v string) bool {
+	if len(f.Visibilities) == 0 {
+		return true
+	}
+	for _, allowed := range f.Visibilities {
+		if strings.EqualFold(allowed, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibility of the repository, derived from the private flag when not reported
+func visibility(r scm.Repository) string {
+	if v := r.Detail.Visibility; v != "" {
+		return v
+	}
+	if r.Detail.Private {
+		return "private"
+	}
+	return "public"
+}
+
+// determine if the topic is one of the topics, ignoring case
+func hasTopic(topics []string, topic string) bool {
+	for _, t := range topics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+	return false
+}
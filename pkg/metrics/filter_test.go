@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func TestParseSelection(t *testing.T) {
+	for name, expected := range map[string]Selection{"": SelectInclude, "include": SelectInclude, "exclude": SelectExclude, "only": SelectOnly} {
+		s, err := ParseSelection(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, s)
+	}
+
+	_, err := ParseSelection("sometimes")
+	assert.Error(t, err)
+}
+
+func TestRepositoryFilter_ZeroValue(t *testing.T) {
+	repo := scm.Repository{Name: "sandbox", Topics: []string{"sandbox"}, Detail: scm.Detail{
+		Archived:   true,
+		Fork:       true,
+		Template:   true,
+		Visibility: "internal",
+	}}
+
+	assert.True(t, RepositoryFilter{}.Includes(repo))
+	assert.True(t, RepositoryFilter{}.Includes(scm.Repository{Name: "nodetail"}))
+}
+
+func TestRepositoryFilter_Names(t *testing.T) {
+	filter := RepositoryFilter{Include: regexp.MustCompile("^svc-"), Exclude: regexp.MustCompile("-sandbox$")}
+
+	assert.True(t, filter.Includes(scm.Repository{Name: "svc-orders"}))
+	assert.False(t, filter.Includes(scm.Repository{Name: "svc-orders-sandbox"}))
+	assert.False(t, filter.Includes(scm.Repository{Name: "lib-orders"}))
+}
+
+func TestRepositoryFilter_Topics(t *testing.T) {
+	filter := RepositoryFilter{RequiredTopics: []string{"production", "java"}, ForbiddenTopics: []string{"deprecated"}}
+
+	assert.True(t, filter.Includes(scm.Repository{Name: "repo1", Topics: []string{"Java", "production", "api"}}))
+	assert.False(t, filter.Includes(scm.Repository{Name: "repo2", Topics: []string{"java"}}))
+	assert.False(t, filter.Includes(scm.Repository{Name: "repo3", Topics: []string{"java", "production", "deprecated"}}))
+	assert.False(t, filter.Includes(scm.Repository{Name: "repo4"}))
+}
+
+func TestRepositoryFilter_Attributes(t *testing.T) {
+	archived := scm.Repository{Name: "archived", Detail: scm.Detail{Archived: true}}
+	fork := scm.Repository{Name: "fork", Detail: scm.Detail{Fork: true}}
+	template := scm.Repository{Name: "template", Detail: scm.Detail{Template: true}}
+	plain := scm.Repository{Name: "plain", Detail: scm.Detail{}}
+
+	exclude := RepositoryFilter{Archived: SelectExclude, Forks: SelectExclude, Templates: SelectExclude}
+	assert.False(t, exclude.Includes(archived))
+	assert.False(t, exclude.Includes(fork))
+	assert.False(t, exclude.Includes(template))
+	assert.True(t, exclude.Includes(plain))
+
+	onlyForks := RepositoryFilter{Forks: SelectOnly}
+	assert.False(t, onlyForks.Includes(archived))
+	assert.True(t, onlyForks.Includes(fork))
+	assert.False(t, onlyForks.Includes(plain))
+}
+
+func TestRepositoryFilter_Visibilities(t *testing.T) {
+	filter := RepositoryFilter{Visibilities: []string{"private", "Internal"}}
+
+	assert.True(t, filter.Includes(scm.Repository{Name: "internal", Detail: scm.Detail{Visibility: "internal", Private: true}}))
+	assert.True(t, filter.Includes(scm.Repository{Name: "private", Detail: scm.Detail{Private: true}}))
+	assert.False(t, filter.Includes(scm.Repository{Name: "public", Detail: scm.Detail{Visibility: "public"}}))
+	assert.False(t, filter.Includes(scm.Repository{Name: "unknown"}))
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// defaultPortfolio portfolio of the required hygiene files applying to repositories without their own
+const defaultPortfolio = "default"
+
+// HygienePolicy governance files looked for in each repository along with the names of the files required by
+// portfolio, the default portfolio applying to repositories without a portfolio of their own
+type HygienePolicy struct {
+	Files    []scm.HygieneFile   `json:"files"`
+	Required map[string][]string `json:"required"`
+}
+
+// HygieneMetric defines structure for the governance files present in a repository
+type HygieneMetric struct {
+	Files     map[string]bool `json:"files" bson:"files"`
+	Required  []string        `json:"required" bson:"required"`
+	Missing   []string        `json:"missing" bson:"missing"`
+	Compliant bool            `json:"compliant" bson:"compliant"`
+}
+
+// DefaultHygienePolicy policy looking for the default governance files, all of them required
+func DefaultHygienePolicy() HygienePolicy {
+	var names []string
+	for _, f := range scm.DefaultHygieneFiles {
+		names = append(names, f.Name)
+	}
+	return HygienePolicy{
+		Files:    scm.DefaultHygieneFiles,
+		Required: map[string][]string{defaultPortfolio: names},
+	}
+}
+
+// ReadHygienePolicy read the hygiene policy from the JSON file, using the default files when the policy
+// doesn't define any.  Required files must be one of the files looked for.
+func ReadHygienePolicy(filename string) (HygienePolicy, error) {
+	policy := HygienePolicy{}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return policy, err
+	}
+	if err = json.Unmarshal(data, &policy); err != nil {
+		return policy, fmt.Errorf("invalid hygiene policy %s: %w", filename, err)
+	}
+	if len(policy.Files) == 0 {
+		policy.Files = scm.DefaultHygieneFiles
+	}
+
+	names := make(map[string]bool)
+	for _, f := range policy.Files {
+		names[f.Name] = true
+	}
+	for portfolio, required := range policy.Required {
+		for _, name := range required {
+			if !names[name] {
+				return policy, fmt.Errorf("invalid hygiene policy %s: unknown file %s required by portfolio %s", filename, name, portfolio)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// newHygieneMetric hygiene metrics from the governance files present, the required files being those of the
+// repository portfolio.  Nil when the files weren't collected.
+func newHygieneMetric(files map[string]bool, policy HygienePolicy, portfolio string) *HygieneMetric {
+	if files == nil {
+		return nil
+	}
+
+	required, found := policy.Required[portfolio]
+	if !found {
+		required = policy.Required[defaultPortfolio]
+	}
+
+	metric := &HygieneMetric{Files: files, Required: append([]string{}, required...)}
+	sort.Strings(metric.Required)
+	for _, name := range metric.Required {
+		if !files[name] {
+			metric.Missing = append(metric.Missing, name)
+		}
+	}
+	metric.Compliant = len(metric.Missing) == 0
+	return metric
+}
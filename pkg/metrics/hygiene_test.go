@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func TestDefaultHygienePolicy(t *testing.T) {
+	policy := DefaultHygienePolicy()
+
+	assert.Equal(t, scm.DefaultHygieneFiles, policy.Files)
+	assert.Equal(t, len(scm.DefaultHygieneFiles), len(policy.Required[defaultPortfolio]))
+}
+
+func TestReadHygienePolicy(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hygiene.json")
+	err := ioutil.WriteFile(filename, []byte(`{"required": {"default": ["readme"], "payments": ["readme", "security"]}}`), 0600)
+	assert.NoError(t, err)
+
+	policy, err := ReadHygienePolicy(filename)
+
+	assert.NoError(t, err)
+	assert.Equal(t, scm.DefaultHygieneFiles, policy.Files)
+	assert.Equal(t, []string{"readme", "security"}, policy.Required["payments"])
+}
+
+func TestReadHygienePolicy_UnknownFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hygiene.json")
+	err := ioutil.WriteFile(filename, []byte(`{"files": [{"name": "readme", "paths": ["README.md"]}], "required": {"default": ["license"]}}`), 0600)
+	assert.NoError(t, err)
+
+	_, err = ReadHygienePolicy(filename)
+
+	assert.Error(t, err)
+	if err != nil {
+		assert.Contains(t, err.Error(), "unknown file license required by portfolio default")
+	}
+}
+
+func TestReadHygienePolicy_Invalid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hygiene.json")
+	err := ioutil.WriteFile(filename, []byte(`{"required": ["readme"]}`), 0600)
+	assert.NoError(t, err)
+
+	_, err = ReadHygienePolicy(filename)
+
+	assert.Error(t, err)
+}
+
+func Test_newHygieneMetric(t *testing.T) {
+	policy := HygienePolicy{
+		Required: map[string][]string{
+			defaultPortfolio: {"readme"},
+			"payments":       {"security", "readme", "codeowners"},
+		},
+	}
+	files := map[string]bool{"readme": true, "security": false, "codeowners": true}
+
+	metric := newHygieneMetric(files, policy, "payments")
+
+	assert.Equal(t, files, metric.Files)
+	assert.Equal(t, []string{"codeowners", "readme", "security"}, metric.Required)
+	assert.Equal(t, []string{"security"}, metric.Missing)
+	assert.False(t, metric.Compliant)
+
+	metric = newHygieneMetric(files, policy, "")
+
+	assert.Equal(t, []string{"readme"}, metric.Required)
+	assert.Nil(t, metric.Missing)
+	assert.True(t, metric.Compliant)
+}
+
+func Test_newHygieneMetric_NotCollected(t *testing.T) {
+	assert.Nil(t, newHygieneMetric(nil, DefaultHygienePolicy(), ""))
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// IssueMetric defines structure for the issue tracking metrics of a repository
+type IssueMetric struct {
+	OpenCount                    int            `json:"openCount" bson:"openCount"`
+	ClosedCount                  int            `json:"closedCount" bson:"closedCount"`
+	OpenBugCount                 int            `json:"openBugCount" bson:"openBugCount"`
+	ClosedBugCount               int            `json:"closedBugCount" bson:"closedBugCount"`
+	MedianMinutesToClose         float64        `json:"medianMinutesToClose" bson:"medianMinutesToClose"`
+	MedianMinutesToFirstResponse float64        `json:"medianMinutesToFirstResponse" bson:"medianMinutesToFirstResponse"`
+	OpenAge                      IssueAgeMetric `json:"openAge" bson:"openAge"`
+}
+
+// IssueAgeMetric defines structure for the number of open issues by age
+type IssueAgeMetric struct {
+	LessThanWeek    int `json:"lessThanWeek" bson:"lessThanWeek"`
+	LessThanMonth   int `json:"lessThanMonth" bson:"lessThanMonth"`
+	LessThanQuarter int `json:"lessThanQuarter" bson:"lessThanQuarter"`
+	QuarterOrOlder  int `json:"quarterOrOlder" bson:"quarterOrOlder"`
+}
+
+// newIssueMetric issue metrics from the issues and their comments by issue number, bugs being the issues with
+// any of the bug labels (ignoring case).  The first response is the first comment by someone other than the
+// issue author.  Nil when the issues weren't collected.
+func newIssueMetric(issues []scm.Issue, comments map[int][]scm.IssueComment, bugLabels []string, now time.Time) *IssueMetric {
+	if issues == nil {
+		return nil
+	}
+
+	metric := &IssueMetric{}
+	var toClose, toResponse []float64
+	for _, issue := range issues {
+		bug := hasLabel(issue, bugLabels)
+		if issue.State == "closed" {
+			metric.ClosedCount++
+			if bug {
+				metric.ClosedBugCount++
+			}
+			if issue.Created != nil && issue.Closed != nil {
+				toClose = append(toClose, issue.Closed.Sub(*issue.Created).Minutes())
+			}
+		} else {
+			metric.OpenCount++
+			if bug {
+				metric.OpenBugCount++
+			}
+			if issue.Created != nil {
+				addIssueAge(&metric.OpenAge, now.Sub(*issue.Created))
+			}
+		}
+
+		if response := firstResponse(issue, comments[issue.Number]); response != nil && issue.Created != nil {
+			toResponse = append(toResponse, response.Sub(*issue.Created).Minutes())
+		}
+	}
+
+	metric.MedianMinutesToClose = median(toClose)
+	metric.MedianMinutesToFirstResponse = median(toResponse)
+	return metric
+}
+
+// determine if the issue has any of the labels, ignoring case
+func hasLabel(issue scm.Issue, labels []string) bool {
+	for _, l := range issue.Labels {
+		for _, label := range labels {
+			if strings.EqualFold(l, label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// time of the first comment on the issue by someone other than the author, nil when no one has responded
+func firstResponse(issue scm.Issue, comments []scm.IssueComment) *time.Time {
+	var first *time.Time
+	for _, c := range comments {
+		if c.Created == nil || c.Author == issue.Author {
+			continue
+		}
+		if first == nil || c.Created.Before(*first) {
+			first = c.Created
+		}
+	}
+	return first
+}
+
+// add the open issue to the age distribution
+func addIssueAge(ages *IssueAgeMetric, age time.Duration) {
+	switch {
+	case age < 7*24*time.Hour:
+		ages.LessThanWeek++
+	case age < 30*24*time.Hour:
+		ages.LessThanMonth++
+	case age < 90*24*time.Hour:
+		ages.LessThanQuarter++
+	default:
+		ages.QuarterOrOlder++
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+func testIssue(number int, state string, author string, createdAt time.Time, labels ...string) scm.Issue {
+	return scm.Issue{
+		Number:  number,
+		State:   state,
+		Author:  author,
+		Labels:  labels,
+		Created: &createdAt,
+	}
+}
+
+func testIssueComment(author string, createdAt time.Time) scm.IssueComment {
+	return scm.IssueComment{
+		Author:  author,
+		Created: &createdAt,
+	}
+}
+
+func Test_newIssueMetric(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	closed := testIssue(1, "closed", "alice", now.Add(-10*day), "Bug")
+	closedAt := now.Add(-8 * day)
+	closed.Closed = &closedAt
+	issues := []scm.Issue{
+		closed,
+		testIssue(2, "open", "bob", now.Add(-2*day), "defect"),
+		testIssue(3, "open", "carol", now.Add(-20*day), "enhancement"),
+		testIssue(4, "open", "dave", now.Add(-60*day)),
+		testIssue(5, "open", "erin", now.Add(-200*day)),
+	}
+	comments := map[int][]scm.IssueComment{
+		1: {
+			testIssueComment("bob", now.Add(-9*day)),
+			testIssueComment("alice", now.Add(-10*day+time.Minute)),
+			testIssueComment("carol", now.Add(-10*day+time.Hour)),
+		},
+		2: {testIssueComment("bob", now.Add(-day))},
+		3: {testIssueComment("alice", now.Add(-20*day+2*time.Hour))},
+	}
+
+	metric := newIssueMetric(issues, comments, []string{"bug", "defect"}, now)
+
+	assert.Equal(t, 4, metric.OpenCount)
+	assert.Equal(t, 1, metric.ClosedCount)
+	assert.Equal(t, 1, metric.OpenBugCount)
+	assert.Equal(t, 1, metric.ClosedBugCount)
+	assert.Equal(t, 2880.0, metric.MedianMinutesToClose)
+	assert.Equal(t, 90.0, metric.MedianMinutesToFirstResponse)
+	assert.Equal(t, IssueAgeMetric{LessThanWeek: 1, LessThanMonth: 1, LessThanQuarter: 1, QuarterOrOlder: 1}, metric.OpenAge)
+}
+
+func Test_newIssueMetric_NotCollected(t *testing.T) {
+	assert.Nil(t, newIssueMetric(nil, nil, []string{"bug"}, time.Now()))
+}
+
+func Test_newIssueMetric_NoIssues(t *testing.T) {
+	metric := newIssueMetric([]scm.Issue{}, nil, []string{"bug"}, time.Now())
+
+	assert.Equal(t, &IssueMetric{}, metric)
+}
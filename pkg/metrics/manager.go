@@ -1,29 +1,51 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
 
-	"github.com/day2devops/ea-metric-extractor/pkg/github"
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
 )
 
+// overlap applied to the previous metric as of time when collecting pull requests changed since, covering
+// requests updated while the previous collection was running
+const pullRequestOverlap = time.Hour
+
 // Options to use when updating repository metrics
 type Options struct {
 	ForceAllRepoEval  bool
 	ForceMetricUpdate bool
+	// RepoTimeout limits the time spent updating a single repository, no limit when zero
+	RepoTimeout time.Duration
+	// DORAWindows day windows to calculate DORA metrics and release version bumps over, not calculated when empty
+	DORAWindows []int
+	// BugLabels issue labels identifying bugs in the issue metrics
+	BugLabels []string
+	// Hygiene governance files looked for and required by portfolio, not looked for when no files defined
+	Hygiene HygienePolicy
+	// Filter repositories of an organization to update, repositories excluded having their metrics removed
+	// when evaluating all repositories
+	Filter RepositoryFilter
 }
 
 // Processor defines methods for metric management
 type Processor interface {
-	RepositoriesForOrg(orgNa string, options Options) error
-	Repository(orgNa string, repoNa string) error
+	RepositoriesForOrg(ctx context.Context, orgNa string, options Options) error
+	RepositoriesForOrgs(ctx context.Context, orgNas []string, options Options) error
+	Repository(ctx context.Context, orgNa string, repoNa string, options Options) error
+	DeleteRepository(ctx context.Context, orgNa string, repoNa string) error
 }
 
 // ProcessorCreator interface for creation of repository processors
 type ProcessorCreator interface {
-	NewProcessor(collector github.DataCollector, dataMgr DataManager) Processor
+	NewProcessor(collector scm.DataCollector, dataMgr DataManager) Processor
 }
 
 // ProcessorFactory factory implementation for implementing repository processor creator interface
@@ -31,7 +53,7 @@ type ProcessorFactory struct {
 }
 
 // NewProcessor construct instance of Processor
-func (ProcessorFactory) NewProcessor(collector github.DataCollector, dataMgr DataManager) Processor {
+func (ProcessorFactory) NewProcessor(collector scm.DataCollector, dataMgr DataManager) Processor {
 	return Manager{
 		DataCollector: collector,
 		DataManager:   dataMgr,
@@ -40,17 +62,17 @@ func (ProcessorFactory) NewProcessor(collector github.DataCollector, dataMgr Dat
 
 // Manager used to manage git hub metrics
 type Manager struct {
-	DataCollector github.DataCollector
+	DataCollector scm.DataCollector
 	DataManager   DataManager
 }
 
 // RepositoriesForOrg process all repositories for an organization
-func (m Manager) RepositoriesForOrg(orgNa string, options Options) error {
+func (m Manager) RepositoriesForOrg(ctx context.Context, orgNa string, options Options) error {
 	glog.Infof("Updating metrics for repositories in org %s", orgNa)
 
 	// read statistcs for metric data
 	now := time.Now().UTC()
-	found, stats := m.DataManager.ReadCacheStats(orgNa)
+	found, stats := m.DataManager.ReadCacheStats(ctx, orgNa)
 	if !found {
 		stats = &CacheStats{}
 	}
@@ -61,84 +83,262 @@ func (m Manager) RepositoriesForOrg(orgNa string, options Options) error {
 		changedAfter = nil
 	}
 
-	repositories, err := m.DataCollector.ListRepositories(orgNa, changedAfter)
+	repositories, err := m.DataCollector.ListRepositories(ctx, orgNa, changedAfter)
 	if err != nil {
 		return err
 	}
 
+	// summarize the sections that failed to collect however the update ends
+	failedSections := make(map[string][]string)
+	defer logFailedSections(orgNa, failedSections)
+
 	activeOrgRepos := make(map[string]bool)
 	for _, r := range repositories {
+		if !options.Filter.Includes(r) {
+			glog.V(2).Infof("Skipping repository excluded by filters: %s/%s", r.Org, r.Name)
+			continue
+		}
 		if options.ForceAllRepoEval {
 			activeOrgRepos[r.Name] = true
 		}
-		if !options.ForceMetricUpdate && m.skipRepositoryNotUpdated(r) {
-			glog.V(2).Infof("Skipping metric updates for repository: %s/%s", r.Org, r.Name)
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		var previous *GitRepositoryMetric
+		if !options.ForceMetricUpdate {
+			previous = m.previousMetrics(ctx, r.Org, r.Name)
+			if m.skipRepositoryNotUpdated(r, previous) {
+				glog.V(2).Infof("Skipping metric updates for repository: %s/%s", r.Org, r.Name)
+				continue
+			}
+		}
+		failed, err := m.repositoryWithTimeout(ctx, orgNa, r.Name, previous, options)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			// only the repository ran out of time, the others are still updated
+			failedSections[r.Name] = []string{"timed out"}
 			continue
 		}
-		if err = m.Repository(orgNa, r.Name); err != nil {
+		if err != nil {
 			return err
 		}
+		if len(failed) > 0 {
+			failedSections[r.Name] = failed
+		}
 	}
 
 	// when evaluating all repositories, look for repositories in cache to cleanup
 	if options.ForceAllRepoEval {
-		if err = m.cleanOldRepositories(orgNa, activeOrgRepos); err != nil {
+		if err = m.cleanOldRepositories(ctx, orgNa, activeOrgRepos); err != nil {
 			return err
 		}
 	}
 
+	// don't record the update when cancelled, repositories may not all be processed
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
 	// write statistics for metric data
 	stats.UpdatedAt = &now
-	m.DataManager.StoreCacheStats(orgNa, *stats)
+	m.DataManager.StoreCacheStats(ctx, orgNa, *stats)
+	return nil
+}
+
+// RepositoriesForOrgs process all repositories for each organization, discovering the organizations the
+// collector can see when none are supplied.  A failed organization doesn't stop the others, the error naming
+// each organization that failed.
+func (m Manager) RepositoriesForOrgs(ctx context.Context, orgNas []string, options Options) error {
+	if len(orgNas) == 0 {
+		glog.Info("Discovering organizations")
+		orgs, err := m.DataCollector.ListOrganizations(ctx)
+		if err != nil {
+			return err
+		}
+		orgNas = orgs
+	}
+
+	var failed []string
+	updated := 0
+	for _, orgNa := range orgNas {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := m.RepositoriesForOrg(ctx, orgNa, options); err != nil {
+			glog.Errorf("Error updating metrics for org %s: %s", orgNa, err)
+			failed = append(failed, orgNa)
+			continue
+		}
+		updated++
+	}
+
+	// summarize the run across the organizations
+	glog.Infof("Updated metrics for %d of %d orgs", updated, len(orgNas))
+	if len(failed) > 0 {
+		glog.Warningf("Failed to update metrics for orgs: %s", strings.Join(failed, ", "))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("metric update failed for %d of %d orgs: %s", len(failed), len(orgNas), strings.Join(failed, ", "))
+	}
 	return nil
 }
 
 // Repository handles metric gathering for the given repository
-func (m Manager) Repository(orgNa string, repoNa string) error {
+func (m Manager) Repository(ctx context.Context, orgNa string, repoNa string, options Options) error {
+	var previous *GitRepositoryMetric
+	if !options.ForceMetricUpdate {
+		previous = m.previousMetrics(ctx, orgNa, repoNa)
+	}
+	failed, err := m.repository(ctx, orgNa, repoNa, previous, options)
+	if len(failed) > 0 {
+		logFailedSections(orgNa, map[string][]string{repoNa: failed})
+	}
+	return err
+}
+
+// DeleteRepository deletes the metrics of a repository that no longer exists
+func (m Manager) DeleteRepository(ctx context.Context, orgNa string, repoNa string) error {
+	glog.Infof("Deleting metrics for repository: %s/%s", orgNa, repoNa)
+	return m.DataManager.DeleteMetrics(ctx, orgNa, repoNa)
+}
+
+// Update the repository metrics, only collecting pull requests changed since the previous metrics when found
+func (m Manager) repository(ctx context.Context, orgNa string, repoNa string, previous *GitRepositoryMetric, options Options) ([]string, error) {
 	// Get the core repository details
 	glog.Infof("Updating metrics for repository: %s/%s", orgNa, repoNa)
-	repository, err := m.DataCollector.GetRepository(orgNa, repoNa)
+	opts := scm.CollectOptions{}
+	if previous != nil {
+		since := previous.AsOf.Add(-pullRequestOverlap)
+		opts.PullRequestsSince = &since
+	}
+	if days := maxWindow(options.DORAWindows); days > 0 {
+		since := time.Now().UTC().AddDate(0, 0, -days)
+		opts.DeploymentsSince = &since
+	}
+	opts.HygieneFiles = options.Hygiene.Files
+	repository, err := m.DataCollector.GetRepository(ctx, orgNa, repoNa, opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Keep the previous figures when contributor statistics weren't ready or sections failed to collect, even
+	// when forcing updates
+	if (repository.ContributorsPending || len(repository.SectionErrors) > 0) && previous == nil {
+		previous = m.previousMetrics(ctx, orgNa, repoNa)
 	}
 
 	// Extract metrics and store them
-	repoMetrics := newGitRepositoryMetric(repository)
-	err = m.DataManager.StoreMetrics(repoMetrics)
-	return err
+	repoMetrics := newGitRepositoryMetric(repository, previous)
+	repoMetrics.DORA = newDORAMetrics(repository, repoMetrics.PullRequests, options.DORAWindows, *repoMetrics.AsOf)
+	if doraSectionFailed(repository) && previous != nil {
+		repoMetrics.DORA = previous.DORA
+	}
+	repoMetrics.Releases = newReleaseMetric(repository.Releases, options.DORAWindows, *repoMetrics.AsOf)
+	if _, failed := repository.SectionErrors[scm.SectionReleases]; failed && previous != nil {
+		repoMetrics.Releases = previous.Releases
+	}
+	repoMetrics.Issues = newIssueMetric(repository.Issues, repository.IssueComments, options.BugLabels, *repoMetrics.AsOf)
+	if _, failed := repository.SectionErrors[scm.SectionIssues]; failed && previous != nil {
+		repoMetrics.Issues = previous.Issues
+		repoMetrics.Truncated.Issues = previous.Truncated.Issues
+	}
+	repoMetrics.Hygiene = newHygieneMetric(repository.HygieneFiles, options.Hygiene, repoMetrics.Portfolio)
+	if _, failed := repository.SectionErrors[scm.SectionHygiene]; failed && previous != nil {
+		repoMetrics.Hygiene = previous.Hygiene
+	}
+	err = m.DataManager.StoreMetrics(ctx, repoMetrics)
+	return repository.FailedSections(), err
+}
+
+// Update the repository metrics, limiting the time spent when a timeout is supplied
+func (m Manager) repositoryWithTimeout(ctx context.Context, orgNa string, repoNa string, previous *GitRepositoryMetric, options Options) ([]string, error) {
+	timeout := options.RepoTimeout
+	if timeout <= 0 {
+		return m.repository(ctx, orgNa, repoNa, previous, options)
+	}
+
+	repoCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	failed, err := m.repository(repoCtx, orgNa, repoNa, previous, options)
+	if err != nil && ctx.Err() == nil && repoCtx.Err() == context.DeadlineExceeded {
+		glog.Warningf("Timed out after %s updating metrics for repository: %s/%s", timeout, orgNa, repoNa)
+	}
+	return failed, err
+}
+
+// Determine if a section the DORA metrics are derived from failed to collect
+func doraSectionFailed(r *scm.Repository) bool {
+	for _, section := range []string{scm.SectionReleases, scm.SectionPullRequests, scm.SectionReviews, scm.SectionDeployments} {
+		if _, failed := r.SectionErrors[section]; failed {
+			return true
+		}
+	}
+	return false
+}
+
+// Log a summary of the sections that failed to collect by repository name
+func logFailedSections(orgNa string, failedSections map[string][]string) {
+	if len(failedSections) == 0 {
+		return
+	}
+
+	var repoNas []string
+	for repoNa := range failedSections {
+		repoNas = append(repoNas, repoNa)
+	}
+	sort.Strings(repoNas)
+
+	var summary []string
+	for _, repoNa := range repoNas {
+		summary = append(summary, fmt.Sprintf("%s (%s)", repoNa, strings.Join(failedSections[repoNa], ", ")))
+	}
+	glog.Warningf("Sections failed to collect for %d repositories in org %s: %s", len(repoNas), orgNa, strings.Join(summary, "; "))
+}
+
+// Read the previously stored metrics for the repository, nil when not found or unreadable
+func (m Manager) previousMetrics(ctx context.Context, orgNa string, repoNa string) *GitRepositoryMetric {
+	found, metrics, err := m.DataManager.ReadMetrics(ctx, orgNa, repoNa)
+	if err != nil {
+		glog.V(3).Infof("Error reading metrics for repository %s/%s (%s)", orgNa, repoNa, err.Error())
+		return nil
+	}
+	if !found || metrics.AsOf == nil {
+		glog.V(3).Infof("Previous metrics not found for repository %s/%s", orgNa, repoNa)
+		return nil
+	}
+	return metrics
 }
 
 // Determine if repository should be skipped since it hasn't been updated
-func (m Manager) skipRepositoryNotUpdated(r github.Repository) bool {
+func (m Manager) skipRepositoryNotUpdated(r scm.Repository, previous *GitRepositoryMetric) bool {
 	// Don't skip if updated timestamp not available for comparison
 	if r.Changed == nil {
 		glog.V(3).Infof("NOT Skipping: Update timestamp not found for repository %s", r.Name)
 		return false
 	}
 
-	// Attempt to read metrics...not skipping if error or not found
-	found, metrics, err := m.DataManager.ReadMetrics(r.Org, r.Name)
-	if !found || err != nil {
-		glog.V(3).Infof("NOT Skipping: Metrics found: %t", found)
-		if err != nil {
-			glog.V(3).Infof("NOT Skipping: Error reading metrics (%s)", err.Error())
-		}
+	// Not skipping if metrics not found
+	if previous == nil {
+		glog.V(3).Infof("NOT Skipping: Metrics not found for repository %s", r.Name)
 		return false
 	}
 
 	// Skip when repository updated before the cached as of timestamp
-	glog.V(3).Infof("Comparing update time of %s to metric update time %s", r.Changed, metrics.AsOf)
-	return r.Changed.Before(*metrics.AsOf)
+	glog.V(3).Infof("Comparing update time of %s to metric update time %s", r.Changed, previous.AsOf)
+	return r.Changed.Before(*previous.AsOf)
 }
 
 // Find repositories that have metric data that no longer exist and delete them
-func (m Manager) cleanOldRepositories(org string, activeOrgRepos map[string]bool) error {
+func (m Manager) cleanOldRepositories(ctx context.Context, org string, activeOrgRepos map[string]bool) error {
 	// Pull list of repositories that exist within the cache for organization
 	orgFilter, err := regexp.Compile("^" + org + "$")
 	if err != nil {
 		return err
 	}
-	cacheKeys, err := m.DataManager.ListMetrics(ListMetricOptions{orgFilter: orgFilter})
+	cacheKeys, err := m.DataManager.ListMetrics(ctx, ListMetricOptions{orgFilter: orgFilter})
 	if err != nil {
 		return err
 	}
@@ -147,7 +347,7 @@ func (m Manager) cleanOldRepositories(org string, activeOrgRepos map[string]bool
 	for _, cacheKey := range cacheKeys {
 		if _, found := activeOrgRepos[cacheKey.Name]; !found {
 			glog.Infof("Deleting metrics for repository: %s/%s", org, cacheKey.Name)
-			if err = m.DataManager.DeleteMetrics(org, cacheKey.Name); err != nil {
+			if err = m.DataManager.DeleteMetrics(ctx, org, cacheKey.Name); err != nil {
 				return err
 			}
 		}
@@ -1,20 +1,21 @@
 package metrics
 
 import (
+	"context"
 	"errors"
+	"regexp"
 	"testing"
 	"time"
 
-	gogithub "github.com/google/go-github/v39/github"
 	"github.com/nyarly/spies"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
-	"github.com/day2devops/ea-metric-extractor/pkg/github"
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
 )
 
 func TestNewProcessor(t *testing.T) {
-	collector := github.RepositoryDataCollector{}
+	collector := &DataCollectorSpy{Spy: spies.NewSpy()}
 	dataMgr := FileDataManager{}
 
 	rp := ProcessorFactory{}.NewProcessor(collector, dataMgr)
@@ -24,9 +25,9 @@ func TestNewProcessor(t *testing.T) {
 }
 
 func TestRepositoriesForOrg(t *testing.T) {
-	repos := []github.Repository{
-		{ID: int64(123), Org: "testorg", Name: "test-repo1", Detail: &gogithub.Repository{}},
-		{ID: int64(124), Org: "testorg", Name: "test-repo2", Detail: &gogithub.Repository{}},
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+		{ID: int64(124), Org: "testorg", Name: "test-repo2"},
 	}
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
 	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
@@ -48,7 +49,7 @@ func TestRepositoriesForOrg(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.RepositoriesForOrg("testorg", Options{true, true})
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{ForceAllRepoEval: true, ForceMetricUpdate: true})
 
 	assert.NoError(t, err)
 
@@ -73,15 +74,138 @@ func TestRepositoriesForOrg(t *testing.T) {
 	assert.Equal(t, "old-repo2", dataMgrSpy.CallsTo("DeleteMetrics")[1].PassedArgs().String(1))
 }
 
+func TestRepositoriesForOrg_Filtered(t *testing.T) {
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+		{ID: int64(124), Org: "testorg", Name: "test-repo2", Detail: scm.Detail{Archived: true}},
+		{ID: int64(125), Org: "testorg", Name: "sandbox-repo3"},
+	}
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, &repos[0], nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+	dataMgrSpy.MatchMethod("StoreCacheStats", spies.AnyArgs, nil)
+	dataMgrSpy.MatchMethod("ListMetrics", spies.AnyArgs, []Key{
+		{Org: "testorg", Name: "test-repo1"},
+		{Org: "testorg", Name: "test-repo2"},
+		{Org: "testorg", Name: "sandbox-repo3"},
+	}, nil)
+	dataMgrSpy.MatchMethod("DeleteMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	filter := RepositoryFilter{Exclude: regexp.MustCompile("^sandbox-"), Archived: SelectExclude}
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{ForceAllRepoEval: true, ForceMetricUpdate: true, Filter: filter})
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(dataCollectorSpy.CallsTo("GetRepository")))
+	assert.Equal(t, "test-repo1", dataCollectorSpy.CallsTo("GetRepository")[0].PassedArgs().String(1))
+
+	assert.Equal(t, 2, len(dataMgrSpy.CallsTo("DeleteMetrics")))
+	assert.Equal(t, "test-repo2", dataMgrSpy.CallsTo("DeleteMetrics")[0].PassedArgs().String(1))
+	assert.Equal(t, "sandbox-repo3", dataMgrSpy.CallsTo("DeleteMetrics")[1].PassedArgs().String(1))
+}
+
+func TestRepositoriesForOrgs(t *testing.T) {
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListRepositories", func(args mock.Arguments) bool { return args.String(0) == "badorg" }, nil, errors.New("list repo error"))
+	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, []scm.Repository{}, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreCacheStats", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.RepositoriesForOrgs(context.Background(), []string{"org1", "badorg", "org2"}, Options{})
+
+	assert.Error(t, err)
+	assert.Equal(t, "metric update failed for 1 of 3 orgs: badorg", err.Error())
+	assert.Equal(t, 0, len(dataCollectorSpy.CallsTo("ListOrganizations")))
+
+	assert.Equal(t, 3, len(dataCollectorSpy.CallsTo("ListRepositories")))
+	assert.Equal(t, "org1", dataCollectorSpy.CallsTo("ListRepositories")[0].PassedArgs().String(0))
+	assert.Equal(t, "badorg", dataCollectorSpy.CallsTo("ListRepositories")[1].PassedArgs().String(0))
+	assert.Equal(t, "org2", dataCollectorSpy.CallsTo("ListRepositories")[2].PassedArgs().String(0))
+
+	assert.Equal(t, 3, len(dataMgrSpy.CallsTo("ReadCacheStats")))
+	assert.Equal(t, 2, len(dataMgrSpy.CallsTo("StoreCacheStats")))
+	assert.Equal(t, "org1", dataMgrSpy.CallsTo("StoreCacheStats")[0].PassedArgs().String(0))
+	assert.Equal(t, "org2", dataMgrSpy.CallsTo("StoreCacheStats")[1].PassedArgs().String(0))
+}
+
+func TestRepositoriesForOrgs_DiscoverOrgs(t *testing.T) {
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListOrganizations", spies.AnyArgs, []string{"org1", "org2"}, nil)
+	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, []scm.Repository{}, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreCacheStats", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.RepositoriesForOrgs(context.Background(), nil, Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(dataCollectorSpy.CallsTo("ListOrganizations")))
+	assert.Equal(t, 2, len(dataCollectorSpy.CallsTo("ListRepositories")))
+	assert.Equal(t, "org1", dataCollectorSpy.CallsTo("ListRepositories")[0].PassedArgs().String(0))
+	assert.Equal(t, "org2", dataCollectorSpy.CallsTo("ListRepositories")[1].PassedArgs().String(0))
+	assert.Equal(t, 2, len(dataMgrSpy.CallsTo("StoreCacheStats")))
+}
+
+func TestRepositoriesForOrgs_DiscoverOrgsError(t *testing.T) {
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListOrganizations", spies.AnyArgs, nil, errors.New("list org error"))
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   &DataManagerSpy{Spy: spies.NewSpy()},
+	}
+
+	err := metricMgr.RepositoriesForOrgs(context.Background(), nil, Options{})
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(dataCollectorSpy.CallsTo("ListRepositories")))
+}
+
+func TestRepositoriesForOrgs_Cancelled(t *testing.T) {
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, []scm.Repository{}, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   &DataManagerSpy{Spy: spies.NewSpy()},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := metricMgr.RepositoriesForOrgs(ctx, []string{"org1", "org2"}, Options{})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, len(dataCollectorSpy.CallsTo("ListRepositories")))
+}
+
 func TestRepositoriesForOrg_SkipSinceNotUpdated(t *testing.T) {
 	oneHourAgo := time.Now().Add(time.Hour * -1)
 	twoHourAgo := time.Now().Add(time.Hour * -2)
-	repos := []github.Repository{
-		{ID: int64(123), Org: "testorg", Name: "test-repo1", Changed: &oneHourAgo, Detail: &gogithub.Repository{}},
-		{ID: int64(124), Org: "testorg", Name: "test-repo2", Changed: &twoHourAgo, Detail: &gogithub.Repository{}},
-		{ID: int64(125), Org: "testorg", Name: "test-repo3", Changed: &twoHourAgo, Detail: &gogithub.Repository{}},
-		{ID: int64(126), Org: "testorg", Name: "test-repo4", Detail: &gogithub.Repository{}},
-		{ID: int64(127), Org: "testorg", Name: "test-repo5", Changed: &twoHourAgo, Detail: &gogithub.Repository{}},
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1", Changed: &oneHourAgo},
+		{ID: int64(124), Org: "testorg", Name: "test-repo2", Changed: &twoHourAgo},
+		{ID: int64(125), Org: "testorg", Name: "test-repo3", Changed: &twoHourAgo},
+		{ID: int64(126), Org: "testorg", Name: "test-repo4"},
+		{ID: int64(127), Org: "testorg", Name: "test-repo5", Changed: &twoHourAgo},
 	}
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
 	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
@@ -104,6 +228,10 @@ func TestRepositoriesForOrg_SkipSinceNotUpdated(t *testing.T) {
 		return args.String(1) == "test-repo3"
 	}, false, nil, nil)
 
+	dataMgrSpy.MatchMethod("ReadMetrics", func(args mock.Arguments) bool {
+		return args.String(1) == "test-repo4"
+	}, false, nil, nil)
+
 	dataMgrSpy.MatchMethod("ReadMetrics", func(args mock.Arguments) bool {
 		return args.String(1) == "test-repo5"
 	}, false, nil, errors.New("repo read error"))
@@ -113,7 +241,7 @@ func TestRepositoriesForOrg_SkipSinceNotUpdated(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.RepositoriesForOrg("testorg", Options{})
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{})
 
 	assert.NoError(t, err)
 
@@ -131,7 +259,7 @@ func TestRepositoriesForOrg_SkipSinceNotUpdated(t *testing.T) {
 	assert.Equal(t, "test-repo5", dataCollectorSpy.CallsTo("GetRepository")[3].PassedArgs().String(1))
 
 	assert.Equal(t, 4, len(dataMgrSpy.CallsTo("StoreMetrics")))
-	assert.Equal(t, 4, len(dataMgrSpy.CallsTo("ReadMetrics")))
+	assert.Equal(t, 5, len(dataMgrSpy.CallsTo("ReadMetrics")))
 	assert.Equal(t, 0, len(dataMgrSpy.CallsTo("ListMetrics")))
 	assert.Equal(t, 0, len(dataMgrSpy.CallsTo("DeleteMetrics")))
 	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("ReadCacheStats")))
@@ -150,7 +278,7 @@ func TestRepositoriesForOrg_ListRepositoriesError(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.RepositoriesForOrg("testorg", Options{})
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{})
 
 	assert.Error(t, err)
 
@@ -167,9 +295,9 @@ func TestRepositoriesForOrg_ListRepositoriesError(t *testing.T) {
 }
 
 func TestRepositoriesForOrg_ListMetricsError(t *testing.T) {
-	repos := []github.Repository{
-		{ID: int64(123), Org: "testorg", Name: "test-repo1", Detail: &gogithub.Repository{}},
-		{ID: int64(124), Org: "testorg", Name: "test-repo2", Detail: &gogithub.Repository{}},
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+		{ID: int64(124), Org: "testorg", Name: "test-repo2"},
 	}
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
 	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
@@ -186,7 +314,7 @@ func TestRepositoriesForOrg_ListMetricsError(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.RepositoriesForOrg("testorg", Options{true, true})
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{ForceAllRepoEval: true, ForceMetricUpdate: true})
 
 	assert.Error(t, err)
 	assert.Equal(t, "list metric error", err.Error())
@@ -208,9 +336,9 @@ func TestRepositoriesForOrg_ListMetricsError(t *testing.T) {
 }
 
 func TestRepositoriesForOrg_DeleteMetricsError(t *testing.T) {
-	repos := []github.Repository{
-		{ID: int64(123), Org: "testorg", Name: "test-repo1", Detail: &gogithub.Repository{}},
-		{ID: int64(124), Org: "testorg", Name: "test-repo2", Detail: &gogithub.Repository{}},
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+		{ID: int64(124), Org: "testorg", Name: "test-repo2"},
 	}
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
 	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
@@ -232,7 +360,7 @@ func TestRepositoriesForOrg_DeleteMetricsError(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.RepositoriesForOrg("testorg", Options{true, true})
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{ForceAllRepoEval: true, ForceMetricUpdate: true})
 
 	assert.Error(t, err)
 	assert.Equal(t, "delete metric error", err.Error())
@@ -254,9 +382,9 @@ func TestRepositoriesForOrg_DeleteMetricsError(t *testing.T) {
 }
 
 func TestRepositoriesForOrg_GetRepositoryError(t *testing.T) {
-	repos := []github.Repository{
-		{ID: int64(123), Org: "testorg", Name: "test-repo1", Detail: &gogithub.Repository{}},
-		{ID: int64(124), Org: "testorg", Name: "test-repo2", Detail: &gogithub.Repository{}},
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+		{ID: int64(124), Org: "testorg", Name: "test-repo2"},
 	}
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
 	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
@@ -270,7 +398,7 @@ func TestRepositoriesForOrg_GetRepositoryError(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.RepositoriesForOrg("testorg", Options{})
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{})
 
 	assert.Error(t, err)
 
@@ -288,12 +416,65 @@ func TestRepositoriesForOrg_GetRepositoryError(t *testing.T) {
 	assert.Equal(t, 0, len(dataMgrSpy.CallsTo("StoreCacheStats")))
 }
 
+func TestRepositoriesForOrg_Cancelled(t *testing.T) {
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+	}
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := metricMgr.RepositoriesForOrg(ctx, "testorg", Options{ForceAllRepoEval: true, ForceMetricUpdate: true})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(dataCollectorSpy.CallsTo("GetRepository")))
+	assert.Equal(t, 0, len(dataMgrSpy.CallsTo("StoreMetrics")))
+	assert.Equal(t, 0, len(dataMgrSpy.CallsTo("DeleteMetrics")))
+	assert.Equal(t, 0, len(dataMgrSpy.CallsTo("StoreCacheStats")))
+}
+
+func TestRepositoriesForOrg_RepoTimeout(t *testing.T) {
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+		{ID: int64(456), Org: "testorg", Name: "test-repo2"},
+	}
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, &scm.Repository{ID: int64(456), Org: "testorg", Name: "test-repo2"}, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+	dataMgrSpy.MatchMethod("ListMetrics", spies.AnyArgs, []Key{}, nil)
+	dataMgrSpy.MatchMethod("StoreCacheStats", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: blockingDataCollector{DataCollectorSpy: dataCollectorSpy, blocked: "test-repo1"},
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{ForceAllRepoEval: true, ForceMetricUpdate: true, RepoTimeout: 10 * time.Millisecond})
+
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(dataMgrSpy.CallsTo("StoreMetrics"))) {
+		assert.Equal(t, "test-repo2", dataMgrSpy.CallsTo("StoreMetrics")[0].PassedArgs().Get(0).(GitRepositoryMetric).RepositoryName)
+	}
+	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("ListMetrics")))
+	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("StoreCacheStats")))
+}
+
 func TestRepository(t *testing.T) {
-	repo := &github.Repository{
-		ID:     int64(123),
-		Org:    "testorg",
-		Name:   "testrepo",
-		Detail: &gogithub.Repository{},
+	repo := &scm.Repository{
+		ID:   int64(123),
+		Org:  "testorg",
+		Name: "testrepo",
 	}
 
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
@@ -307,7 +488,7 @@ func TestRepository(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.Repository("testorg", "testrepo")
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{})
 
 	assert.NoError(t, err)
 
@@ -318,6 +499,170 @@ func TestRepository(t *testing.T) {
 	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("StoreMetrics")))
 }
 
+func TestRepository_IncrementalPullRequests(t *testing.T) {
+	asOf := time.Now().Add(-24 * time.Hour)
+	repo := &scm.Repository{
+		ID:   int64(123),
+		Org:  "testorg",
+		Name: "testrepo",
+	}
+
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, repo, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("ReadMetrics", spies.AnyArgs, true, &GitRepositoryMetric{AsOf: &asOf}, nil)
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("ReadMetrics")))
+	opts := dataCollectorSpy.CallsTo("GetRepository")[0].PassedArgs().Get(2).(scm.CollectOptions)
+	assert.NotNil(t, opts.PullRequestsSince)
+	assert.Equal(t, asOf.Add(-pullRequestOverlap), *opts.PullRequestsSince)
+}
+
+func TestRepository_ForceMetricUpdateCollectsAllPullRequests(t *testing.T) {
+	repo := &scm.Repository{
+		ID:   int64(123),
+		Org:  "testorg",
+		Name: "testrepo",
+	}
+
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, repo, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{ForceMetricUpdate: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(dataMgrSpy.CallsTo("ReadMetrics")))
+	opts := dataCollectorSpy.CallsTo("GetRepository")[0].PassedArgs().Get(2).(scm.CollectOptions)
+	assert.Nil(t, opts.PullRequestsSince)
+}
+
+func TestRepository_ContributorsPendingKeepsCommitCount(t *testing.T) {
+	asOf := time.Now().Add(-24 * time.Hour)
+	repo := &scm.Repository{
+		ID:                  int64(123),
+		Org:                 "testorg",
+		Name:                "testrepo",
+		ContributorsPending: true,
+	}
+
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, repo, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("ReadMetrics", spies.AnyArgs, true, &GitRepositoryMetric{CommitCount: 42, AsOf: &asOf}, nil)
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{ForceMetricUpdate: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("ReadMetrics")))
+	stored := dataMgrSpy.CallsTo("StoreMetrics")[0].PassedArgs().Get(0).(GitRepositoryMetric)
+	assert.Equal(t, 42, stored.CommitCount)
+	assert.True(t, stored.CommitsStale)
+}
+
+func TestRepository_FailedSectionsKeepPreviousMetrics(t *testing.T) {
+	asOf := time.Now().Add(-24 * time.Hour)
+	repo := &scm.Repository{
+		ID:   int64(123),
+		Org:  "testorg",
+		Name: "testrepo",
+		SectionErrors: map[string]error{
+			scm.SectionPullRequests: errors.New("pull request error"),
+			scm.SectionReleases:     errors.New("releases error"),
+			scm.SectionIssues:       errors.New("issues error"),
+			scm.SectionHygiene:      errors.New("hygiene error"),
+		},
+	}
+	previous := &GitRepositoryMetric{
+		PullRequests: []PullRequestMetric{{Number: 1, Status: "open"}},
+		Releases:     &ReleaseMetric{LatestVersion: "v1.2.0"},
+		DORA:         []DORAMetric{{WindowDays: 30, Source: "releases"}},
+		Issues:       &IssueMetric{OpenCount: 5},
+		Hygiene:      &HygieneMetric{Files: map[string]bool{"README.md": true}, Compliant: true},
+		AsOf:         &asOf,
+	}
+
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, repo, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("ReadMetrics", spies.AnyArgs, true, previous, nil)
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{ForceMetricUpdate: true, DORAWindows: []int{30}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("ReadMetrics")))
+	stored := dataMgrSpy.CallsTo("StoreMetrics")[0].PassedArgs().Get(0).(GitRepositoryMetric)
+	assert.Equal(t, previous.PullRequests, stored.PullRequests)
+	assert.Equal(t, previous.DORA, stored.DORA)
+	assert.Equal(t, previous.Releases, stored.Releases)
+	assert.Equal(t, previous.Issues, stored.Issues)
+	assert.Equal(t, previous.Hygiene, stored.Hygiene)
+	assert.Equal(t, SectionStatus{Error: "pull request error"}, stored.Sections[scm.SectionPullRequests])
+}
+
+func TestRepositoriesForOrg_FailedSections(t *testing.T) {
+	repos := []scm.Repository{
+		{ID: int64(123), Org: "testorg", Name: "test-repo1"},
+		{ID: int64(124), Org: "testorg", Name: "test-repo2"},
+	}
+	failedRepo := repos[0]
+	failedRepo.SectionErrors = map[string]error{scm.SectionReleases: errors.New("releases error")}
+
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("ListRepositories", spies.AnyArgs, repos, nil)
+	dataCollectorSpy.MatchMethod("GetRepository", func(args mock.Arguments) bool { return args.String(1) == "test-repo1" }, &failedRepo, nil)
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, &repos[1], nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+	dataMgrSpy.MatchMethod("StoreCacheStats", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.RepositoriesForOrg(context.Background(), "testorg", Options{ForceMetricUpdate: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(dataMgrSpy.CallsTo("StoreMetrics")))
+	stored := dataMgrSpy.CallsTo("StoreMetrics")[0].PassedArgs().Get(0).(GitRepositoryMetric)
+	assert.False(t, stored.Sections[scm.SectionReleases].Collected)
+	assert.True(t, stored.Sections[scm.SectionBranches].Collected)
+	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("StoreCacheStats")))
+}
+
 func TestRepository_RepoManagerError(t *testing.T) {
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
 	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, nil, errors.New("get repo error"))
@@ -330,7 +675,7 @@ func TestRepository_RepoManagerError(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.Repository("testorg", "testrepo")
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{})
 
 	assert.Error(t, err)
 
@@ -342,11 +687,10 @@ func TestRepository_RepoManagerError(t *testing.T) {
 }
 
 func TestRepository_DataManagerError(t *testing.T) {
-	repo := &github.Repository{
-		ID:     int64(123),
-		Org:    "testorg",
-		Name:   "testrepo",
-		Detail: &gogithub.Repository{},
+	repo := &scm.Repository{
+		ID:   int64(123),
+		Org:  "testorg",
+		Name: "testrepo",
 	}
 
 	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
@@ -360,7 +704,7 @@ func TestRepository_DataManagerError(t *testing.T) {
 		DataManager:   dataMgrSpy,
 	}
 
-	err := metricMgr.Repository("testorg", "testrepo")
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{})
 
 	assert.Error(t, err)
 
@@ -373,25 +717,48 @@ func TestRepository_DataManagerError(t *testing.T) {
 
 type DataCollectorSpy struct {
 	*spies.Spy
-	github.DataCollector
+	scm.DataCollector
+}
+
+func (rdcs *DataCollectorSpy) ListOrganizations(ctx context.Context) ([]string, error) {
+	res := rdcs.Called()
+	orgs := res.Get(0)
+	if orgs == nil {
+		return nil, res.Error(1)
+	}
+	return orgs.([]string), res.Error(1)
 }
 
-func (rdcs *DataCollectorSpy) ListRepositories(org string, changedSince *time.Time) ([]github.Repository, error) {
+func (rdcs *DataCollectorSpy) ListRepositories(ctx context.Context, org string, changedSince *time.Time) ([]scm.Repository, error) {
 	res := rdcs.Called(org, changedSince)
 	repos := res.Get(0)
 	if repos == nil {
 		return nil, res.Error(1)
 	}
-	return repos.([]github.Repository), res.Error(1)
+	return repos.([]scm.Repository), res.Error(1)
 }
 
-func (rdcs *DataCollectorSpy) GetRepository(org string, name string) (*github.Repository, error) {
-	res := rdcs.Called(org, name)
+func (rdcs *DataCollectorSpy) GetRepository(ctx context.Context, org string, name string, opts scm.CollectOptions) (*scm.Repository, error) {
+	res := rdcs.Called(org, name, opts)
 	repo := res.Get(0)
 	if repo == nil {
 		return nil, res.Error(1)
 	}
-	return repo.(*github.Repository), res.Error(1)
+	return repo.(*scm.Repository), res.Error(1)
+}
+
+// collector whose repository retrieval blocks until the context is done
+type blockingDataCollector struct {
+	*DataCollectorSpy
+	blocked string
+}
+
+func (bdc blockingDataCollector) GetRepository(ctx context.Context, org string, name string, opts scm.CollectOptions) (*scm.Repository, error) {
+	if name != bdc.blocked {
+		return bdc.DataCollectorSpy.GetRepository(ctx, org, name, opts)
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
 }
 
 type DataManagerSpy struct {
@@ -399,12 +766,12 @@ type DataManagerSpy struct {
 	DataManager
 }
 
-func (dms *DataManagerSpy) StoreMetrics(metrics GitRepositoryMetric) error {
+func (dms *DataManagerSpy) StoreMetrics(ctx context.Context, metrics GitRepositoryMetric) error {
 	res := dms.Called(metrics)
 	return res.Error(0)
 }
 
-func (dms *DataManagerSpy) ReadMetrics(org string, repo string) (bool, *GitRepositoryMetric, error) {
+func (dms *DataManagerSpy) ReadMetrics(ctx context.Context, org string, repo string) (bool, *GitRepositoryMetric, error) {
 	res := dms.Called(org, repo)
 	metrics := res.Get(1)
 	if metrics == nil {
@@ -413,12 +780,12 @@ func (dms *DataManagerSpy) ReadMetrics(org string, repo string) (bool, *GitRepos
 	return res.Bool(0), metrics.(*GitRepositoryMetric), res.Error(2)
 }
 
-func (dms *DataManagerSpy) DeleteMetrics(org string, repo string) error {
+func (dms *DataManagerSpy) DeleteMetrics(ctx context.Context, org string, repo string) error {
 	res := dms.Called(org, repo)
 	return res.Error(0)
 }
 
-func (dms *DataManagerSpy) ListMetrics(options ListMetricOptions) ([]Key, error) {
+func (dms *DataManagerSpy) ListMetrics(ctx context.Context, options ListMetricOptions) ([]Key, error) {
 	res := dms.Called(options)
 	repos := res.Get(0)
 	if repos == nil {
@@ -427,11 +794,11 @@ func (dms *DataManagerSpy) ListMetrics(options ListMetricOptions) ([]Key, error)
 	return repos.([]Key), res.Error(1)
 }
 
-func (dms *DataManagerSpy) StoreCacheStats(org string, stats CacheStats) {
+func (dms *DataManagerSpy) StoreCacheStats(ctx context.Context, org string, stats CacheStats) {
 	dms.Called(org, stats)
 }
 
-func (dms *DataManagerSpy) ReadCacheStats(org string) (bool, *CacheStats) {
+func (dms *DataManagerSpy) ReadCacheStats(ctx context.Context, org string) (bool, *CacheStats) {
 	res := dms.Called(org)
 	stats := res.Get(1)
 	if stats == nil {
@@ -439,3 +806,75 @@ func (dms *DataManagerSpy) ReadCacheStats(org string) (bool, *CacheStats) {
 	}
 	return res.Bool(0), stats.(*CacheStats)
 }
+
+func TestDeleteRepository(t *testing.T) {
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("DeleteMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{DataManager: dataMgrSpy}
+
+	err := metricMgr.DeleteRepository(context.Background(), "testorg", "testrepo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(dataMgrSpy.CallsTo("DeleteMetrics")))
+	assert.Equal(t, "testorg", dataMgrSpy.CallsTo("DeleteMetrics")[0].PassedArgs().String(0))
+	assert.Equal(t, "testrepo", dataMgrSpy.CallsTo("DeleteMetrics")[0].PassedArgs().String(1))
+}
+
+func TestRepository_DORAWindows(t *testing.T) {
+	repo := &scm.Repository{
+		ID:   int64(123),
+		Org:  "testorg",
+		Name: "testrepo",
+	}
+
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, repo, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	before := time.Now().UTC()
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{ForceMetricUpdate: true, DORAWindows: []int{7, 90, 30}})
+
+	assert.NoError(t, err)
+	opts := dataCollectorSpy.CallsTo("GetRepository")[0].PassedArgs().Get(2).(scm.CollectOptions)
+	assert.NotNil(t, opts.DeploymentsSince)
+	assert.False(t, opts.DeploymentsSince.Before(before.AddDate(0, 0, -90)))
+	assert.True(t, opts.DeploymentsSince.Before(before.AddDate(0, 0, -89)))
+
+	stored := dataMgrSpy.CallsTo("StoreMetrics")[0].PassedArgs().Get(0).(GitRepositoryMetric)
+	assert.Equal(t, 3, len(stored.DORA))
+	assert.Equal(t, "releases", stored.DORA[0].Source)
+}
+
+func TestRepository_NoDORAWindows(t *testing.T) {
+	repo := &scm.Repository{
+		ID:   int64(123),
+		Org:  "testorg",
+		Name: "testrepo",
+	}
+
+	dataCollectorSpy := &DataCollectorSpy{Spy: spies.NewSpy()}
+	dataCollectorSpy.MatchMethod("GetRepository", spies.AnyArgs, repo, nil)
+
+	dataMgrSpy := &DataManagerSpy{Spy: spies.NewSpy()}
+	dataMgrSpy.MatchMethod("StoreMetrics", spies.AnyArgs, nil)
+
+	metricMgr := Manager{
+		DataCollector: dataCollectorSpy,
+		DataManager:   dataMgrSpy,
+	}
+
+	err := metricMgr.Repository(context.Background(), "testorg", "testrepo", Options{ForceMetricUpdate: true})
+
+	assert.NoError(t, err)
+	opts := dataCollectorSpy.CallsTo("GetRepository")[0].PassedArgs().Get(2).(scm.CollectOptions)
+	assert.Nil(t, opts.DeploymentsSince)
+	assert.Nil(t, dataMgrSpy.CallsTo("StoreMetrics")[0].PassedArgs().Get(0).(GitRepositoryMetric).DORA)
+}
@@ -1,39 +1,50 @@
 package metrics
 
 import (
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/golang/glog"
-	gogithub "github.com/google/go-github/v39/github"
 
-	"github.com/day2devops/ea-metric-extractor/pkg/github"
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
 )
 
 // GitRepositoryMetric defines structure for tracking GH metrics
 type GitRepositoryMetric struct {
-	ID             int64               `json:"id" bson:"id"`
-	Org            string              `json:"org" bson:"org"`
-	RepositoryName string              `json:"repositoryName" bson:"repositoryName"`
-	Portfolio      string              `json:"portfolio" bson:"portfolio"`
-	Product        string              `json:"product" bson:"product"`
-	Team           string              `json:"team" bson:"team"`
-	Created        *time.Time          `json:"created" bson:"created"`
-	Updated        *time.Time          `json:"updated" bson:"updated"`
-	Pushed         *time.Time          `json:"pushed" bson:"pushed"`
-	DefaultBranch  string              `json:"defaultBranch" bson:"defaultBranch"`
-	Squashable     bool                `json:"squashable" bson:"squashable"`
-	Rebaseable     bool                `json:"rebaseable" bson:"rebaseable"`
-	Protected      bool                `json:"protected" bson:"protected"`
-	BranchCount    int                 `json:"branchCount" bson:"branchCount"`
-	ReleaseCount   int                 `json:"releaseCount" bson:"releaseCount"`
-	CommitCount    int                 `json:"commitCount" bson:"commitCount"`
-	CodeByteCount  int                 `json:"codeByteCount" bson:"codeByteCount"`
-	Languages      map[string]int      `json:"languages" bson:"languages"`
-	PullRequests   []PullRequestMetric `json:"pullRequests" bson:"pullRequests"`
-	Build          BuildMetric         `json:"build" bson:"build"`
-	CodeQuality    CodeQualityMetric   `json:"codeQuality" bson:"codeQuality"`
-	AsOf           *time.Time          `json:"asOf" bson:"asOf"`
+	ID             int64                    `json:"id" bson:"id"`
+	Provider       string                   `json:"provider" bson:"provider"`
+	Org            string                   `json:"org" bson:"org"`
+	RepositoryName string                   `json:"repositoryName" bson:"repositoryName"`
+	Portfolio      string                   `json:"portfolio" bson:"portfolio"`
+	Product        string                   `json:"product" bson:"product"`
+	Team           string                   `json:"team" bson:"team"`
+	Created        *time.Time               `json:"created" bson:"created"`
+	Updated        *time.Time               `json:"updated" bson:"updated"`
+	Pushed         *time.Time               `json:"pushed" bson:"pushed"`
+	DefaultBranch  string                   `json:"defaultBranch" bson:"defaultBranch"`
+	Squashable     bool                     `json:"squashable" bson:"squashable"`
+	Rebaseable     bool                     `json:"rebaseable" bson:"rebaseable"`
+	Protected      bool                     `json:"protected" bson:"protected"`
+	Protection     BranchProtectionMetric   `json:"protection" bson:"protection"`
+	BranchCount    int                      `json:"branchCount" bson:"branchCount"`
+	BranchAges     *BranchAgeMetric         `json:"branchAges" bson:"branchAges"`
+	ReleaseCount   int                      `json:"releaseCount" bson:"releaseCount"`
+	Releases       *ReleaseMetric           `json:"releases" bson:"releases"`
+	CommitCount    int                      `json:"commitCount" bson:"commitCount"`
+	CommitsStale   bool                     `json:"commitsStale" bson:"commitsStale"`
+	CodeByteCount  int                      `json:"codeByteCount" bson:"codeByteCount"`
+	Languages      map[string]int           `json:"languages" bson:"languages"`
+	PullRequests   []PullRequestMetric      `json:"pullRequests" bson:"pullRequests"`
+	Reviews        ReviewMetric             `json:"reviews" bson:"reviews"`
+	Build          *BuildMetric             `json:"build" bson:"build"`
+	CodeQuality    CodeQualityMetric        `json:"codeQuality" bson:"codeQuality"`
+	DORA           []DORAMetric             `json:"dora" bson:"dora"`
+	Issues         *IssueMetric             `json:"issues" bson:"issues"`
+	Hygiene        *HygieneMetric           `json:"hygiene" bson:"hygiene"`
+	Truncated      TruncatedMetric          `json:"truncated" bson:"truncated"`
+	Sections       map[string]SectionStatus `json:"sections" bson:"sections"`
+	AsOf           *time.Time               `json:"asOf" bson:"asOf"`
 }
 
 // PullRequestMetric defines structure for pull request metrics
@@ -44,34 +55,118 @@ type PullRequestMetric struct {
 	ClosedAt    *time.Time `json:"closedAt" bson:"closedAt"`
 	MergedAt    *time.Time `json:"mergedAt" bson:"mergedAt"`
 	MinutesOpen float64    `json:"minutesOpen" bson:"minutesOpen"`
+	// review health, minute figures are nil until the pull request is reviewed/approved
+	MinutesToFirstReview   *float64 `json:"minutesToFirstReview" bson:"minutesToFirstReview"`
+	MinutesToApproval      *float64 `json:"minutesToApproval" bson:"minutesToApproval"`
+	ReviewRounds           int      `json:"reviewRounds" bson:"reviewRounds"`
+	ApprovalCount          int      `json:"approvalCount" bson:"approvalCount"`
+	RequestedReviewerCount int      `json:"requestedReviewerCount" bson:"requestedReviewerCount"`
+	MergedWithoutApproval  bool     `json:"mergedWithoutApproval" bson:"mergedWithoutApproval"`
 }
 
-// BuildMetric defines structure for build metrics
+// ReviewMetric defines structure for repository level pull request review metrics
+type ReviewMetric struct {
+	MedianMinutesToFirstReview float64 `json:"medianMinutesToFirstReview" bson:"medianMinutesToFirstReview"`
+	MedianMinutesToApproval    float64 `json:"medianMinutesToApproval" bson:"medianMinutesToApproval"`
+	MedianReviewRounds         float64 `json:"medianReviewRounds" bson:"medianReviewRounds"`
+	MedianApprovalCount        float64 `json:"medianApprovalCount" bson:"medianApprovalCount"`
+	MergedWithoutApprovalCount int     `json:"mergedWithoutApprovalCount" bson:"mergedWithoutApprovalCount"`
+}
+
+// BranchProtectionMetric defines structure for the default branch protection rules, the rules are only
+// populated when the status is enabled (reading them requires admin access to the repository)
+type BranchProtectionMetric struct {
+	Status                       string   `json:"status" bson:"status"`
+	RequiredApprovingReviewCount int      `json:"requiredApprovingReviewCount" bson:"requiredApprovingReviewCount"`
+	DismissStaleReviews          bool     `json:"dismissStaleReviews" bson:"dismissStaleReviews"`
+	RequireCodeOwnerReviews      bool     `json:"requireCodeOwnerReviews" bson:"requireCodeOwnerReviews"`
+	RequiredStatusChecks         []string `json:"requiredStatusChecks" bson:"requiredStatusChecks"`
+	StrictStatusChecks           bool     `json:"strictStatusChecks" bson:"strictStatusChecks"`
+	EnforceAdmins                bool     `json:"enforceAdmins" bson:"enforceAdmins"`
+	RequireLinearHistory         bool     `json:"requireLinearHistory" bson:"requireLinearHistory"`
+	AllowForcePushes             bool     `json:"allowForcePushes" bson:"allowForcePushes"`
+	AllowDeletions               bool     `json:"allowDeletions" bson:"allowDeletions"`
+}
+
+// BuildMetric defines structure for build metrics, counting the GitHub Actions workflow runs created in the
+// last day, week (7 days) and month (30 days)
 type BuildMetric struct {
-	BuildsTodayCount         int     `json:"buildsTodayCount" bson:"buildsTodayCount"`
-	BuildsWeekCount          int     `json:"buildsWeekCount" bson:"buildsWeekCount"`
+	BuildsTodayCount         int                   `json:"buildsTodayCount" bson:"buildsTodayCount"`
+	BuildsWeekCount          int                   `json:"buildsWeekCount" bson:"buildsWeekCount"`
+	BuildsMonthCount         int                   `json:"buildsMonthCount" bson:"buildsMonthCount"`
+	AvgBuildMinutesLastMonth float32               `json:"avgBuildMinutesLastMonth" bson:"avgBuildMinutesLastMonth"`
+	SuccessPctLastMonth      float32               `json:"successPctLastMonth" bson:"successPctLastMonth"`
+	Workflows                []WorkflowBuildMetric `json:"workflows" bson:"workflows"`
+}
+
+// WorkflowBuildMetric defines structure for the build metrics of a single workflow over the last month
+type WorkflowBuildMetric struct {
+	Name                     string  `json:"name" bson:"name"`
 	BuildsMonthCount         int     `json:"buildsMonthCount" bson:"buildsMonthCount"`
 	AvgBuildMinutesLastMonth float32 `json:"avgBuildMinutesLastMonth" bson:"avgBuildMinutesLastMonth"`
+	SuccessPctLastMonth      float32 `json:"successPctLastMonth" bson:"successPctLastMonth"`
 }
 
-// CodeQualityMetric defines structure for code quality metrics
+// CodeQualityMetric defines structure for code quality metrics.  The blocker, critical, major and issue counts
+// come from the open code scanning alerts and the dependabot counts from the open Dependabot alerts, with each
+// source recording where the counts came from and whether it's enabled.  Test figures have no source yet.
 type CodeQualityMetric struct {
-	BlockerCount    int     `json:"blockerCount" bson:"blockerCount"`
-	CriticalCount   int     `json:"criticalCount" bson:"criticalCount"`
-	MajorCount      int     `json:"majorCount" bson:"majorCount"`
-	IssueCount      int     `json:"issueCount" bson:"issueCount"`
-	TestCount       int     `json:"testCount" bson:"testCount"`
-	TestErrorCount  int     `json:"testErrorCount" bson:"testErrorCount"`
-	TestFailCount   int     `json:"testFailCount" bson:"testFailCount"`
-	TestCoveragePct float32 `json:"testCoveragePct" bson:"testCoveragePct"`
-}
-
-// newGitRepositoryMetric extract desired metrics for the supplied repository
-func newGitRepositoryMetric(r *github.Repository) GitRepositoryMetric {
+	BlockerCount            int                 `json:"blockerCount" bson:"blockerCount"`
+	CriticalCount           int                 `json:"criticalCount" bson:"criticalCount"`
+	MajorCount              int                 `json:"majorCount" bson:"majorCount"`
+	IssueCount              int                 `json:"issueCount" bson:"issueCount"`
+	TestCount               int                 `json:"testCount" bson:"testCount"`
+	TestErrorCount          int                 `json:"testErrorCount" bson:"testErrorCount"`
+	TestFailCount           int                 `json:"testFailCount" bson:"testFailCount"`
+	TestCoveragePct         float32             `json:"testCoveragePct" bson:"testCoveragePct"`
+	DependabotCriticalCount int                 `json:"dependabotCriticalCount" bson:"dependabotCriticalCount"`
+	DependabotHighCount     int                 `json:"dependabotHighCount" bson:"dependabotHighCount"`
+	DependabotModerateCount int                 `json:"dependabotModerateCount" bson:"dependabotModerateCount"`
+	DependabotLowCount      int                 `json:"dependabotLowCount" bson:"dependabotLowCount"`
+	CodeScanning            QualitySourceMetric `json:"codeScanning" bson:"codeScanning"`
+	Dependabot              QualitySourceMetric `json:"dependabot" bson:"dependabot"`
+}
+
+// QualitySourceMetric defines structure recording the source of code quality counts and its status, the
+// counts being left at zero unless the status is enabled
+type QualitySourceMetric struct {
+	Source string `json:"source" bson:"source"`
+	Status string `json:"status" bson:"status"`
+}
+
+// code quality sources
+const (
+	sourceCodeScanning = "github-code-scanning"
+	sourceDependabot   = "github-dependabot"
+)
+
+// TruncatedMetric defines structure flagging collections only partially collected due to page limits
+type TruncatedMetric struct {
+	Branches       bool `json:"branches" bson:"branches"`
+	Releases       bool `json:"releases" bson:"releases"`
+	PullRequests   bool `json:"pullRequests" bson:"pullRequests"`
+	WorkflowRuns   bool `json:"workflowRuns" bson:"workflowRuns"`
+	Deployments    bool `json:"deployments" bson:"deployments"`
+	Issues         bool `json:"issues" bson:"issues"`
+	SecurityAlerts bool `json:"securityAlerts" bson:"securityAlerts"`
+	Contributors   bool `json:"contributors" bson:"contributors"`
+}
+
+// SectionStatus defines structure for the collection status of a repository section, the figures of a section
+// that failed to collect are kept from the previous metrics when available
+type SectionStatus struct {
+	Collected bool   `json:"collected" bson:"collected"`
+	Error     string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// newGitRepositoryMetric extract desired metrics for the supplied repository, merging the pull requests
+// into the previous metrics when only pull requests changed since then were collected
+func newGitRepositoryMetric(r *scm.Repository, previous *GitRepositoryMetric) GitRepositoryMetric {
 	// Populate the base metrics from the repository object
 	glog.V(3).Infof("Extracting metric data from repository %+v", r)
 	metrics := GitRepositoryMetric{
 		ID:             r.ID,
+		Provider:       r.Provider,
 		Org:            r.Org,
 		RepositoryName: r.Name,
 	}
@@ -79,22 +174,29 @@ func newGitRepositoryMetric(r *github.Repository) GitRepositoryMetric {
 	metrics.Portfolio = parseTopic(r, "portfolio-")
 	metrics.Product = parseTopic(r, "product-")
 	metrics.Team = parseTopic(r, "team-")
-	metrics.Created = extractTime(r.Detail.CreatedAt)
-	metrics.Updated = extractTime(r.Detail.UpdatedAt)
-	metrics.Pushed = extractTime(r.Detail.PushedAt)
-	metrics.DefaultBranch = extractString(r.Detail.DefaultBranch)
-	metrics.Squashable = extractBool(r.Detail.AllowSquashMerge)
-	metrics.Rebaseable = extractBool(r.Detail.AllowRebaseMerge)
+	metrics.Created = r.Detail.Created
+	metrics.Updated = r.Detail.Updated
+	metrics.Pushed = r.Detail.Pushed
+	metrics.DefaultBranch = r.Detail.DefaultBranch
+	metrics.Squashable = r.Detail.Squashable
+	metrics.Rebaseable = r.Detail.Rebaseable
 
 	// Process branch information if found
 	metrics.BranchCount = len(r.Branches)
 	metrics.Protected = defaultBranchProtected(r, metrics.DefaultBranch)
+	metrics.Protection = newBranchProtectionMetric(r.Protection)
+	metrics.BranchAges = newBranchAgeMetric(r.BranchActivity, time.Now().UTC())
 
 	// Process release information if found
 	metrics.ReleaseCount = len(r.Releases)
 
 	// Process pull request data
-	metrics.PullRequests = mapPullRequests(r.PullRequests)
+	metrics.PullRequests = mapPullRequests(r.PullRequests, r.Reviews)
+	if r.PullRequestsSince != nil && previous != nil {
+		glog.V(2).Infof("Merging %d changed pull requests into previous metrics for %s/%s", len(metrics.PullRequests), r.Org, r.Name)
+		metrics.PullRequests = mergePullRequests(previous.PullRequests, metrics.PullRequests)
+	}
+	metrics.Reviews = summarizeReviews(metrics.PullRequests)
 
 	// Process language data
 	metrics.Languages = r.Languages
@@ -102,63 +204,361 @@ func newGitRepositoryMetric(r *github.Repository) GitRepositoryMetric {
 		metrics.CodeByteCount += cnt
 	}
 
-	// Process commits
+	// Process commits, keeping the previous count when statistics weren't ready or failed to collect rather
+	// than reporting zero
 	for _, c := range r.Contributors {
-		metrics.CommitCount += c.GetTotal()
+		metrics.CommitCount += c.Commits
+	}
+	if _, failed := r.SectionErrors[scm.SectionContributors]; r.ContributorsPending || failed {
+		glog.V(2).Infof("Contributor statistics unavailable for %s/%s, marking commit count stale", r.Org, r.Name)
+		metrics.CommitsStale = true
+		if previous != nil {
+			metrics.CommitCount = previous.CommitCount
+		}
 	}
 
-	// Mock data for NOW on other metrics
-	metrics.Build = BuildMetric{
-		BuildsTodayCount:         10,
-		BuildsWeekCount:          25,
-		BuildsMonthCount:         200,
-		AvgBuildMinutesLastMonth: 2.5,
+	// Flag counts incomplete due to page limits
+	metrics.Truncated = TruncatedMetric{
+		Branches:       r.Truncated.Branches,
+		Releases:       r.Truncated.Releases,
+		PullRequests:   r.Truncated.PullRequests,
+		WorkflowRuns:   r.Truncated.WorkflowRuns,
+		Deployments:    r.Truncated.Deployments,
+		Issues:         r.Truncated.Issues,
+		SecurityAlerts: r.Truncated.SecurityAlerts,
+		Contributors:   r.Truncated.Contributors,
 	}
-	metrics.CodeQuality = CodeQualityMetric{
-		BlockerCount:    0,
-		CriticalCount:   0,
-		MajorCount:      0,
-		IssueCount:      4,
-		TestCount:       25,
-		TestErrorCount:  0,
-		TestFailCount:   0,
-		TestCoveragePct: 83.4,
+	if r.PullRequestsSince != nil && previous != nil {
+		metrics.Truncated.PullRequests = metrics.Truncated.PullRequests || previous.Truncated.PullRequests
 	}
 
-	// Add as of timestamp and return
+	// Process workflow runs, left unset when the repository has no workflows
 	now := time.Now().UTC()
+	metrics.Build = newBuildMetric(r.Workflows, r.WorkflowRuns, now)
+
+	// Process code scanning and dependabot alerts
+	metrics.CodeQuality = newCodeQualityMetric(r.Security)
+
+	// Record the status of each section, keeping the previous figures of failed sections rather than reporting
+	// them empty
+	metrics.Sections = newSectionStatuses(r.SectionErrors)
+	if len(r.SectionErrors) > 0 && previous != nil {
+		glog.V(2).Infof("Keeping previous metrics of failed sections %v for %s/%s", r.FailedSections(), r.Org, r.Name)
+		keepFailedSections(&metrics, r.SectionErrors, previous)
+	}
+
+	// Add as of timestamp and return
 	metrics.AsOf = &now
 	return metrics
 }
 
-// map pull request metrics
-func mapPullRequests(prs []*gogithub.PullRequest) []PullRequestMetric {
+// map pull request metrics, including the review metrics from the reviews found by pull request number
+func mapPullRequests(prs []scm.PullRequest, reviews map[int][]scm.Review) []PullRequestMetric {
 	var prMetrics []PullRequestMetric
 	for _, pr := range prs {
-		prMetric := PullRequestMetric{Number: *pr.ID}
-		prMetric.Status = extractString(pr.State)
-		prMetric.CreatedAt = pr.CreatedAt
-		prMetric.ClosedAt = pr.ClosedAt
-		prMetric.MergedAt = pr.MergedAt
+		prMetric := PullRequestMetric{Number: pr.ID}
+		prMetric.Status = pr.State
+		prMetric.CreatedAt = pr.Created
+		prMetric.ClosedAt = pr.Closed
+		prMetric.MergedAt = pr.Merged
 
-		compTS := pr.MergedAt
-		if compTS == nil {
-			compTS = pr.ClosedAt
-		}
-		if compTS == nil {
-			now := time.Now().UTC()
-			compTS = &now
-		}
-		diff := compTS.Sub(*pr.CreatedAt).Minutes()
-		prMetric.MinutesOpen = diff
+		prMetric.MinutesOpen = minutesOpen(prMetric)
+		applyReviews(&prMetric, pr, reviews[pr.Number])
 
 		prMetrics = append(prMetrics, prMetric)
 	}
 	return prMetrics
 }
 
+// merge changed pull request metrics into the previous metrics by number, refreshing the
+// time open for previous requests still open
+func mergePullRequests(previous []PullRequestMetric, changed []PullRequestMetric) []PullRequestMetric {
+	merged := append([]PullRequestMetric{}, changed...)
+	found := make(map[int64]bool)
+	for _, pr := range changed {
+		found[pr.Number] = true
+	}
+
+	for _, pr := range previous {
+		if found[pr.Number] {
+			continue
+		}
+		if pr.Status == "open" && pr.CreatedAt != nil {
+			pr.MinutesOpen = minutesOpen(pr)
+		}
+		merged = append(merged, pr)
+	}
+
+	// keep the newest pull requests first as listed by GitHub
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].CreatedAt == nil || merged[j].CreatedAt == nil {
+			return merged[j].CreatedAt == nil && merged[i].CreatedAt != nil
+		}
+		return merged[i].CreatedAt.After(*merged[j].CreatedAt)
+	})
+	return merged
+}
+
+// apply the review metrics for the pull request, ignoring pending reviews and those by the pull request author
+func applyReviews(prMetric *PullRequestMetric, pr scm.PullRequest, reviews []scm.Review) {
+	prMetric.RequestedReviewerCount = pr.RequestedReviewers
+
+	var submitted []scm.Review
+	for _, review := range reviews {
+		if review.Submitted == nil || review.State == "PENDING" {
+			continue
+		}
+		if pr.Author != "" && review.Author == pr.Author {
+			continue
+		}
+		submitted = append(submitted, review)
+	}
+	sort.SliceStable(submitted, func(i, j int) bool {
+		return submitted[i].Submitted.Before(*submitted[j].Submitted)
+	})
+
+	rounds := make(map[string]bool)
+	latest := make(map[string]string)
+	for _, review := range submitted {
+		rounds[review.CommitID] = true
+		if prMetric.MinutesToFirstReview == nil && pr.Created != nil {
+			minutes := review.Submitted.Sub(*pr.Created).Minutes()
+			prMetric.MinutesToFirstReview = &minutes
+		}
+		if review.State == "APPROVED" && prMetric.MinutesToApproval == nil && pr.Created != nil {
+			minutes := review.Submitted.Sub(*pr.Created).Minutes()
+			prMetric.MinutesToApproval = &minutes
+		}
+		// comments don't change a reviewer's verdict
+		if review.State != "COMMENTED" {
+			latest[review.Author] = review.State
+		}
+	}
+
+	prMetric.ReviewRounds = len(rounds)
+	for _, state := range latest {
+		if state == "APPROVED" {
+			prMetric.ApprovalCount++
+		}
+	}
+	prMetric.MergedWithoutApproval = pr.Merged != nil && prMetric.ApprovalCount == 0
+}
+
+// summarize the review metrics across the pull requests
+func summarizeReviews(prs []PullRequestMetric) ReviewMetric {
+	var firstReview, approval, rounds, approvals []float64
+	summary := ReviewMetric{}
+	for _, pr := range prs {
+		if pr.MinutesToFirstReview != nil {
+			firstReview = append(firstReview, *pr.MinutesToFirstReview)
+		}
+		if pr.MinutesToApproval != nil {
+			approval = append(approval, *pr.MinutesToApproval)
+		}
+		rounds = append(rounds, float64(pr.ReviewRounds))
+		approvals = append(approvals, float64(pr.ApprovalCount))
+		if pr.MergedWithoutApproval {
+			summary.MergedWithoutApprovalCount++
+		}
+	}
+	summary.MedianMinutesToFirstReview = median(firstReview)
+	summary.MedianMinutesToApproval = median(approval)
+	summary.MedianReviewRounds = median(rounds)
+	summary.MedianApprovalCount = median(approvals)
+	return summary
+}
+
+// median of the supplied values, zero when none supplied
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// minutes the pull request was open, up to now when not merged or closed
+func minutesOpen(pr PullRequestMetric) float64 {
+	compTS := pr.MergedAt
+	if compTS == nil {
+		compTS = pr.ClosedAt
+	}
+	if compTS == nil {
+		now := time.Now().UTC()
+		compTS = &now
+	}
+	return compTS.Sub(*pr.CreatedAt).Minutes()
+}
+
+// branch protection metrics from the protection settings
+func newBranchProtectionMetric(protection scm.BranchProtection) BranchProtectionMetric {
+	metric := BranchProtectionMetric{Status: statusOf(protection.Status)}
+	settings := protection.Settings
+	if settings == nil {
+		return metric
+	}
+
+	metric.RequiredApprovingReviewCount = settings.RequiredApprovingReviewCount
+	metric.DismissStaleReviews = settings.DismissStaleReviews
+	metric.RequireCodeOwnerReviews = settings.RequireCodeOwnerReviews
+	metric.RequiredStatusChecks = settings.RequiredStatusChecks
+	metric.StrictStatusChecks = settings.StrictStatusChecks
+	metric.EnforceAdmins = settings.EnforceAdmins
+	metric.RequireLinearHistory = settings.RequireLinearHistory
+	metric.AllowForcePushes = settings.AllowForcePushes
+	metric.AllowDeletions = settings.AllowDeletions
+	return metric
+}
+
+// code quality metrics from the open security alerts
+func newCodeQualityMetric(security scm.SecurityAlerts) CodeQualityMetric {
+	quality := CodeQualityMetric{
+		CodeScanning: QualitySourceMetric{Source: sourceCodeScanning, Status: statusOf(security.CodeScanning)},
+		Dependabot:   QualitySourceMetric{Source: sourceDependabot, Status: statusOf(security.Dependabot)},
+	}
+
+	for _, alert := range security.CodeScanningAlerts {
+		quality.IssueCount++
+		switch strings.ToLower(alert.Severity) {
+		case "critical":
+			quality.BlockerCount++
+		case "high", "error":
+			quality.CriticalCount++
+		case "medium", "warning":
+			quality.MajorCount++
+		}
+	}
+
+	for _, alert := range security.DependabotAlerts {
+		switch strings.ToLower(alert.Severity) {
+		case "critical":
+			quality.DependabotCriticalCount++
+		case "high":
+			quality.DependabotHighCount++
+		case "medium", "moderate":
+			quality.DependabotModerateCount++
+		case "low":
+			quality.DependabotLowCount++
+		}
+	}
+	return quality
+}
+
+// status of the feature, unknown when not collected
+func statusOf(status scm.FeatureStatus) string {
+	if status == "" {
+		return string(scm.FeatureUnknown)
+	}
+	return string(status)
+}
+
+// build totals accumulated for a set of workflow runs
+type buildTotals struct {
+	count     int
+	timed     int
+	minutes   float64
+	completed int
+	succeeded int
+}
+
+// add the run to the totals, only completed runs count towards the duration and success rate
+func (b *buildTotals) add(run scm.WorkflowRun) {
+	b.count++
+	if run.Status != "completed" || run.Created == nil || run.Updated == nil {
+		return
+	}
+	b.timed++
+	b.minutes += run.Updated.Sub(*run.Created).Minutes()
+	switch run.Conclusion {
+	case "cancelled", "skipped":
+		// not counted as a success or failure
+		return
+	case "success":
+		b.succeeded++
+	}
+	b.completed++
+}
+
+// average build minutes of the completed runs
+func (b buildTotals) avgMinutes() float32 {
+	if b.timed == 0 {
+		return 0
+	}
+	return float32(b.minutes / float64(b.timed))
+}
+
+// percentage of the completed runs that succeeded, ignoring those cancelled or skipped
+func (b buildTotals) successPct() float32 {
+	if b.completed == 0 {
+		return 0
+	}
+	return float32(b.succeeded) * 100 / float32(b.completed)
+}
+
+// build metrics from the workflow runs, nil when workflows weren't collected or none are defined
+func newBuildMetric(workflows []scm.Workflow, runs []scm.WorkflowRun, now time.Time) *BuildMetric {
+	if len(workflows) == 0 {
+		return nil
+	}
+
+	names := make(map[int64]string)
+	for _, w := range workflows {
+		names[w.ID] = w.Name
+	}
+
+	build := &BuildMetric{}
+	month := buildTotals{}
+	byWorkflow := make(map[string]*buildTotals)
+	for _, run := range runs {
+		if run.Created == nil {
+			continue
+		}
+		age := now.Sub(*run.Created)
+		if age > scm.WorkflowRunHistory {
+			continue
+		}
+		if age <= 24*time.Hour {
+			build.BuildsTodayCount++
+		}
+		if age <= 7*24*time.Hour {
+			build.BuildsWeekCount++
+		}
+		month.add(run)
+
+		name, found := names[run.WorkflowID]
+		if !found {
+			name = run.Name
+		}
+		if byWorkflow[name] == nil {
+			byWorkflow[name] = &buildTotals{}
+		}
+		byWorkflow[name].add(run)
+	}
+
+	build.BuildsMonthCount = month.count
+	build.AvgBuildMinutesLastMonth = month.avgMinutes()
+	build.SuccessPctLastMonth = month.successPct()
+	for name, totals := range byWorkflow {
+		build.Workflows = append(build.Workflows, WorkflowBuildMetric{
+			Name:                     name,
+			BuildsMonthCount:         totals.count,
+			AvgBuildMinutesLastMonth: totals.avgMinutes(),
+			SuccessPctLastMonth:      totals.successPct(),
+		})
+	}
+	sort.Slice(build.Workflows, func(i, j int) bool {
+		return build.Workflows[i].Name < build.Workflows[j].Name
+	})
+	return build
+}
+
 // parse the topic value if found using the supplied prefix
-func parseTopic(r *github.Repository, prefix string) string {
+func parseTopic(r *scm.Repository, prefix string) string {
 	for _, topic := range r.Topics {
 		if strings.HasPrefix(topic, prefix) {
 			return strings.TrimPrefix(topic, prefix)
@@ -168,38 +568,63 @@ func parseTopic(r *github.Repository, prefix string) string {
 }
 
 // determine if the default branch is protected
-func defaultBranchProtected(r *github.Repository, defaultBr string) bool {
-	if r.Branches == nil {
-		return false
-	}
+func defaultBranchProtected(r *scm.Repository, defaultBr string) bool {
 	for _, branch := range r.Branches {
-		if extractString(branch.Name) == defaultBr {
-			return extractBool(branch.Protected)
+		if branch.Name == defaultBr {
+			return branch.Protected
 		}
 	}
 	return false
 }
 
-// extract time reference if supplied, otherwise default to nil
-func extractTime(t *gogithub.Timestamp) *time.Time {
-	if t != nil {
-		return &t.Time
+// status of each repository section from the errors of the failed sections
+func newSectionStatuses(errs map[string]error) map[string]SectionStatus {
+	statuses := make(map[string]SectionStatus)
+	for _, section := range scm.Sections {
+		status := SectionStatus{Collected: true}
+		if err, failed := errs[section]; failed {
+			status = SectionStatus{Error: err.Error()}
+		}
+		statuses[section] = status
 	}
-	return nil
+	return statuses
 }
 
-// extract string reference if supplied, otherwise default to empty string
-func extractString(s *string) string {
-	if s != nil {
-		return *s
-	}
-	return ""
-}
-
-// extract bool reference if supplied, otherwise default to false
-func extractBool(b *bool) bool {
-	if b != nil {
-		return *b
+// keep the previous figures of the failed sections
+func keepFailedSections(metrics *GitRepositoryMetric, errs map[string]error, previous *GitRepositoryMetric) {
+	for section := range errs {
+		switch section {
+		case scm.SectionBranches:
+			metrics.BranchCount = previous.BranchCount
+			metrics.BranchAges = previous.BranchAges
+			metrics.Protected = previous.Protected
+			metrics.Truncated.Branches = previous.Truncated.Branches
+		case scm.SectionContributors:
+			metrics.Truncated.Contributors = previous.Truncated.Contributors
+		case scm.SectionReleases:
+			metrics.ReleaseCount = previous.ReleaseCount
+			metrics.Truncated.Releases = previous.Truncated.Releases
+		case scm.SectionPullRequests, scm.SectionReviews:
+			metrics.PullRequests = previous.PullRequests
+			metrics.Reviews = previous.Reviews
+			metrics.Truncated.PullRequests = previous.Truncated.PullRequests
+		case scm.SectionLanguages:
+			metrics.Languages = previous.Languages
+			metrics.CodeByteCount = previous.CodeByteCount
+		case scm.SectionTopics:
+			metrics.Portfolio = previous.Portfolio
+			metrics.Product = previous.Product
+			metrics.Team = previous.Team
+		case scm.SectionBranchActivity:
+			metrics.BranchAges = previous.BranchAges
+		case scm.SectionProtection:
+			metrics.Protection = previous.Protection
+		case scm.SectionWorkflows:
+			metrics.Build = previous.Build
+			metrics.Truncated.WorkflowRuns = previous.Truncated.WorkflowRuns
+		case scm.SectionSecurity:
+			metrics.CodeQuality = previous.CodeQuality
+			metrics.Truncated.SecurityAlerts = previous.Truncated.SecurityAlerts
+		}
 	}
-	return false
 }
@@ -1,45 +1,46 @@
 package metrics
 
 import (
+	"errors"
 	"testing"
 	"time"
 
-	gogithub "github.com/google/go-github/v39/github"
 	"github.com/stretchr/testify/assert"
 
-	"github.com/day2devops/ea-metric-extractor/pkg/github"
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
 )
 
 func Test_newGitRepositoryMetric(t *testing.T) {
-	r := github.Repository{
-		ID:     int64(123),
-		Org:    "test-org",
-		Name:   "test-repo",
-		Topics: []string{"portfolio-myport", "product-myprod", "team-myteam"},
-		Detail: &gogithub.Repository{
-			ID:               gogithub.Int64(123),
-			Name:             gogithub.String("test-repo"),
-			CreatedAt:        &gogithub.Timestamp{Time: time.Now()},
-			UpdatedAt:        &gogithub.Timestamp{Time: time.Now()},
-			PushedAt:         &gogithub.Timestamp{Time: time.Now()},
-			DefaultBranch:    gogithub.String("main"),
-			AllowSquashMerge: gogithub.Bool(true),
-			AllowRebaseMerge: gogithub.Bool(false),
+	created, updated, pushed := time.Now(), time.Now(), time.Now()
+	r := scm.Repository{
+		ID:       int64(123),
+		Provider: "github",
+		Org:      "test-org",
+		Name:     "test-repo",
+		Topics:   []string{"portfolio-myport", "product-myprod", "team-myteam"},
+		Detail: scm.Detail{
+			Created:       &created,
+			Updated:       &updated,
+			Pushed:        &pushed,
+			DefaultBranch: "main",
+			Squashable:    true,
+			Rebaseable:    false,
 		},
 	}
 
-	metrics := newGitRepositoryMetric(&r)
+	metrics := newGitRepositoryMetric(&r, nil)
 
 	assert.NotNil(t, metrics)
 	assert.Equal(t, int64(123), metrics.ID)
+	assert.Equal(t, "github", metrics.Provider)
 	assert.Equal(t, "test-org", metrics.Org)
 	assert.Equal(t, "test-repo", metrics.RepositoryName)
 	assert.Equal(t, "myport", metrics.Portfolio)
 	assert.Equal(t, "myprod", metrics.Product)
 	assert.Equal(t, "myteam", metrics.Team)
-	assert.Equal(t, r.Detail.CreatedAt.Time, *metrics.Created)
-	assert.Equal(t, r.Detail.UpdatedAt.Time, *metrics.Updated)
-	assert.Equal(t, r.Detail.PushedAt.Time, *metrics.Pushed)
+	assert.Equal(t, created, *metrics.Created)
+	assert.Equal(t, updated, *metrics.Updated)
+	assert.Equal(t, pushed, *metrics.Pushed)
 	assert.Equal(t, "main", metrics.DefaultBranch)
 	assert.True(t, metrics.Squashable)
 	assert.False(t, metrics.Rebaseable)
@@ -50,17 +51,13 @@ func Test_newGitRepositoryMetric(t *testing.T) {
 }
 
 func Test_newGitRepositoryMetric_MissingGitHubData(t *testing.T) {
-	r := github.Repository{
+	r := scm.Repository{
 		ID:   int64(123),
 		Org:  "test-org",
 		Name: "test-repo",
-		Detail: &gogithub.Repository{
-			ID:   gogithub.Int64(123),
-			Name: gogithub.String("test-repo"),
-		},
 	}
 
-	metrics := newGitRepositoryMetric(&r)
+	metrics := newGitRepositoryMetric(&r, nil)
 
 	assert.NotNil(t, metrics)
 	assert.Equal(t, int64(123), metrics.ID)
@@ -82,32 +79,30 @@ func Test_newGitRepositoryMetric_MissingGitHubData(t *testing.T) {
 }
 
 func Test_newGitRepositoryMetric_WithBranchInfo(t *testing.T) {
-	r := github.Repository{
+	r := scm.Repository{
 		ID:   int64(123),
 		Org:  "test-org",
 		Name: "test-repo",
-		Detail: &gogithub.Repository{
-			ID:            gogithub.Int64(123),
-			Name:          gogithub.String("test-repo"),
-			DefaultBranch: gogithub.String("main"),
+		Detail: scm.Detail{
+			DefaultBranch: "main",
 		},
-		Branches: []*gogithub.Branch{
+		Branches: []scm.Branch{
 			{
-				Name:      gogithub.String("branch-1"),
-				Protected: gogithub.Bool(false),
+				Name:      "branch-1",
+				Protected: false,
 			},
 			{
-				Name:      gogithub.String("main"),
-				Protected: gogithub.Bool(true),
+				Name:      "main",
+				Protected: true,
 			},
 			{
-				Name:      gogithub.String("branch-2"),
-				Protected: gogithub.Bool(false),
+				Name:      "branch-2",
+				Protected: false,
 			},
 		},
 	}
 
-	metrics := newGitRepositoryMetric(&r)
+	metrics := newGitRepositoryMetric(&r, nil)
 
 	assert.NotNil(t, metrics)
 	assert.Equal(t, int64(123), metrics.ID)
@@ -126,31 +121,27 @@ func Test_newGitRepositoryMetric_WithBranchInfo(t *testing.T) {
 }
 
 func Test_newGitRepositoryMetric_WithBranchInfo_NoDefaultBranch(t *testing.T) {
-	r := github.Repository{
+	r := scm.Repository{
 		ID:   int64(123),
 		Org:  "test-org",
 		Name: "test-repo",
-		Detail: &gogithub.Repository{
-			ID:   gogithub.Int64(123),
-			Name: gogithub.String("test-repo"),
-		},
-		Branches: []*gogithub.Branch{
+		Branches: []scm.Branch{
 			{
-				Name:      gogithub.String("branch-1"),
-				Protected: gogithub.Bool(false),
+				Name:      "branch-1",
+				Protected: false,
 			},
 			{
-				Name:      gogithub.String("main"),
-				Protected: gogithub.Bool(true),
+				Name:      "main",
+				Protected: true,
 			},
 			{
-				Name:      gogithub.String("branch-2"),
-				Protected: gogithub.Bool(false),
+				Name:      "branch-2",
+				Protected: false,
 			},
 		},
 	}
 
-	metrics := newGitRepositoryMetric(&r)
+	metrics := newGitRepositoryMetric(&r, nil)
 
 	assert.NotNil(t, metrics)
 	assert.Equal(t, int64(123), metrics.ID)
@@ -169,23 +160,21 @@ func Test_newGitRepositoryMetric_WithBranchInfo_NoDefaultBranch(t *testing.T) {
 }
 
 func Test_newGitRepositoryMetric_WithReleaseInfo(t *testing.T) {
-	r := github.Repository{
+	r := scm.Repository{
 		ID:   int64(123),
 		Org:  "test-org",
 		Name: "test-repo",
-		Detail: &gogithub.Repository{
-			ID:            gogithub.Int64(123),
-			Name:          gogithub.String("test-repo"),
-			DefaultBranch: gogithub.String("main"),
+		Detail: scm.Detail{
+			DefaultBranch: "main",
 		},
-		Releases: []*gogithub.RepositoryRelease{
-			{Name: gogithub.String("release-1")},
-			{Name: gogithub.String("release-2")},
-			{Name: gogithub.String("release-3")},
+		Releases: []scm.Release{
+			{Name: "release-1"},
+			{Name: "release-2"},
+			{Name: "release-3"},
 		},
 	}
 
-	metrics := newGitRepositoryMetric(&r)
+	metrics := newGitRepositoryMetric(&r, nil)
 
 	assert.NotNil(t, metrics)
 	assert.Equal(t, int64(123), metrics.ID)
@@ -202,3 +191,377 @@ func Test_newGitRepositoryMetric_WithReleaseInfo(t *testing.T) {
 	assert.Equal(t, 3, metrics.ReleaseCount)
 	assert.NotNil(t, metrics.AsOf)
 }
+
+func Test_newGitRepositoryMetric_Truncated(t *testing.T) {
+	r := scm.Repository{
+		ID:        int64(123),
+		Org:       "test-org",
+		Name:      "test-repo",
+		Truncated: scm.Truncated{Branches: true, PullRequests: true},
+	}
+
+	metrics := newGitRepositoryMetric(&r, nil)
+
+	assert.True(t, metrics.Truncated.Branches)
+	assert.False(t, metrics.Truncated.Releases)
+	assert.True(t, metrics.Truncated.PullRequests)
+}
+
+func Test_newGitRepositoryMetric_MergePullRequests(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	twoDaysAgo := time.Now().Add(-48 * time.Hour)
+	threeDaysAgo := time.Now().Add(-72 * time.Hour)
+	now := time.Now()
+	r := scm.Repository{
+		ID:   int64(123),
+		Org:  "test-org",
+		Name: "test-repo",
+		PullRequests: []scm.PullRequest{
+			{ID: 3, State: "open", Created: &now},
+			{ID: 2, State: "closed", Created: &twoDaysAgo, Closed: &now},
+		},
+		PullRequestsSince: &since,
+	}
+	previous := GitRepositoryMetric{
+		PullRequests: []PullRequestMetric{
+			{Number: 2, Status: "open", CreatedAt: &twoDaysAgo, MinutesOpen: 10},
+			{Number: 1, Status: "open", CreatedAt: &threeDaysAgo, MinutesOpen: 10},
+			{Number: 4, Status: "closed", CreatedAt: &dayAgo, ClosedAt: &dayAgo, MinutesOpen: 0},
+		},
+		Truncated: TruncatedMetric{PullRequests: true},
+	}
+
+	metrics := newGitRepositoryMetric(&r, &previous)
+
+	assert.Equal(t, 4, len(metrics.PullRequests))
+	assert.Equal(t, int64(3), metrics.PullRequests[0].Number)
+	assert.Equal(t, int64(4), metrics.PullRequests[1].Number)
+	assert.Equal(t, int64(2), metrics.PullRequests[2].Number)
+	assert.Equal(t, "closed", metrics.PullRequests[2].Status)
+	assert.Equal(t, int64(1), metrics.PullRequests[3].Number)
+	assert.InDelta(t, 72*60, metrics.PullRequests[3].MinutesOpen, 1)
+	assert.Equal(t, float64(0), metrics.PullRequests[1].MinutesOpen)
+	assert.True(t, metrics.Truncated.PullRequests)
+}
+
+func Test_newGitRepositoryMetric_PreviousIgnoredOnFullCollection(t *testing.T) {
+	now := time.Now()
+	r := scm.Repository{
+		ID:   int64(123),
+		Org:  "test-org",
+		Name: "test-repo",
+		PullRequests: []scm.PullRequest{
+			{ID: 3, State: "open", Created: &now},
+		},
+	}
+	previous := GitRepositoryMetric{
+		PullRequests: []PullRequestMetric{{Number: 1, Status: "open", CreatedAt: &now}},
+	}
+
+	metrics := newGitRepositoryMetric(&r, &previous)
+
+	assert.Equal(t, 1, len(metrics.PullRequests))
+	assert.Equal(t, int64(3), metrics.PullRequests[0].Number)
+}
+
+func Test_newGitRepositoryMetric_ContributorsPending(t *testing.T) {
+	r := scm.Repository{
+		ID:                  int64(123),
+		Org:                 "test-org",
+		Name:                "test-repo",
+		ContributorsPending: true,
+	}
+	previous := GitRepositoryMetric{CommitCount: 42}
+
+	metrics := newGitRepositoryMetric(&r, &previous)
+	assert.Equal(t, 42, metrics.CommitCount)
+	assert.True(t, metrics.CommitsStale)
+
+	metrics = newGitRepositoryMetric(&r, nil)
+	assert.Equal(t, 0, metrics.CommitCount)
+	assert.True(t, metrics.CommitsStale)
+}
+
+func Test_newGitRepositoryMetric_FailedSections(t *testing.T) {
+	r := scm.Repository{
+		ID:       int64(123),
+		Org:      "test-org",
+		Name:     "test-repo",
+		Topics:   []string{"team-blue"},
+		Branches: []scm.Branch{{Name: "main"}},
+		SectionErrors: map[string]error{
+			scm.SectionLanguages:    errors.New("languages error"),
+			scm.SectionReleases:     errors.New("releases error"),
+			scm.SectionContributors: errors.New("contributors error"),
+		},
+	}
+	previous := GitRepositoryMetric{
+		ReleaseCount:  3,
+		CommitCount:   42,
+		CodeByteCount: 5000,
+		Languages:     map[string]int{"Go": 4000, "Bash": 1000},
+		Team:          "red",
+		Truncated:     TruncatedMetric{Contributors: true},
+	}
+
+	metrics := newGitRepositoryMetric(&r, &previous)
+	assert.Equal(t, 1, metrics.BranchCount)
+	assert.Equal(t, "blue", metrics.Team)
+	assert.Equal(t, 3, metrics.ReleaseCount)
+	assert.Equal(t, 42, metrics.CommitCount)
+	assert.True(t, metrics.CommitsStale)
+	assert.True(t, metrics.Truncated.Contributors)
+	assert.Equal(t, 5000, metrics.CodeByteCount)
+	assert.Equal(t, previous.Languages, metrics.Languages)
+	assert.Equal(t, len(scm.Sections), len(metrics.Sections))
+	assert.Equal(t, SectionStatus{Collected: true}, metrics.Sections[scm.SectionBranches])
+	assert.Equal(t, SectionStatus{Error: "languages error"}, metrics.Sections[scm.SectionLanguages])
+	assert.Equal(t, SectionStatus{Error: "releases error"}, metrics.Sections[scm.SectionReleases])
+
+	metrics = newGitRepositoryMetric(&r, nil)
+	assert.Equal(t, 0, metrics.ReleaseCount)
+	assert.Equal(t, 0, metrics.CodeByteCount)
+	assert.True(t, metrics.CommitsStale)
+	assert.Equal(t, SectionStatus{Error: "contributors error"}, metrics.Sections[scm.SectionContributors])
+}
+
+func Test_newGitRepositoryMetric_FailedOptionalSections(t *testing.T) {
+	r := scm.Repository{
+		ID:   int64(123),
+		Org:  "test-org",
+		Name: "test-repo",
+		SectionErrors: map[string]error{
+			scm.SectionBranchActivity: errors.New("branch activity error"),
+			scm.SectionProtection:     errors.New("protection error"),
+			scm.SectionWorkflows:      errors.New("workflows error"),
+			scm.SectionSecurity:       errors.New("security error"),
+		},
+	}
+	previous := GitRepositoryMetric{
+		Protection:  BranchProtectionMetric{Status: "enabled", RequiredApprovingReviewCount: 2},
+		BranchAges:  &BranchAgeMetric{Over90DaysCount: 4},
+		Build:       &BuildMetric{BuildsMonthCount: 12},
+		CodeQuality: CodeQualityMetric{CriticalCount: 1},
+		Truncated:   TruncatedMetric{WorkflowRuns: true, SecurityAlerts: true},
+	}
+
+	metrics := newGitRepositoryMetric(&r, &previous)
+	assert.Equal(t, previous.Protection, metrics.Protection)
+	assert.Equal(t, previous.BranchAges, metrics.BranchAges)
+	assert.Equal(t, previous.Build, metrics.Build)
+	assert.Equal(t, previous.CodeQuality, metrics.CodeQuality)
+	assert.True(t, metrics.Truncated.WorkflowRuns)
+	assert.True(t, metrics.Truncated.SecurityAlerts)
+	assert.Equal(t, SectionStatus{Error: "workflows error"}, metrics.Sections[scm.SectionWorkflows])
+	assert.Equal(t, SectionStatus{Error: "security error"}, metrics.Sections[scm.SectionSecurity])
+}
+
+func Test_newGitRepositoryMetric_Reviews(t *testing.T) {
+	created := time.Date(2021, 10, 1, 10, 0, 0, 0, time.UTC)
+	firstReview := created.Add(30 * time.Minute)
+	secondReview := created.Add(90 * time.Minute)
+	approved := created.Add(120 * time.Minute)
+	merged := created.Add(180 * time.Minute)
+	r := scm.Repository{
+		ID:   int64(123),
+		Org:  "test-org",
+		Name: "test-repo",
+		PullRequests: []scm.PullRequest{
+			{
+				ID: 21, Number: 1, State: "closed",
+				Author: "author", Created: &created, Merged: &merged,
+				RequestedReviewers: 1,
+			},
+			{
+				ID: 22, Number: 2, State: "closed",
+				Author: "author", Created: &created, Merged: &merged,
+			},
+		},
+		Reviews: map[int][]scm.Review{
+			1: {
+				{Author: "reviewer1", State: "CHANGES_REQUESTED", Submitted: &firstReview, CommitID: "c1"},
+				{Author: "author", State: "COMMENTED", Submitted: &created, CommitID: "c1"},
+				{Author: "reviewer2", State: "APPROVED", Submitted: &secondReview, CommitID: "c2"},
+				{Author: "reviewer1", State: "APPROVED", Submitted: &approved, CommitID: "c2"},
+				{Author: "reviewer1", State: "COMMENTED", Submitted: &merged, CommitID: "c2"},
+				{Author: "reviewer3", State: "PENDING", CommitID: "c3"},
+			},
+		},
+	}
+
+	metrics := newGitRepositoryMetric(&r, nil)
+
+	assert.Equal(t, 2, len(metrics.PullRequests))
+	pr := metrics.PullRequests[0]
+	assert.Equal(t, float64(30), *pr.MinutesToFirstReview)
+	assert.Equal(t, float64(90), *pr.MinutesToApproval)
+	assert.Equal(t, 2, pr.ReviewRounds)
+	assert.Equal(t, 2, pr.ApprovalCount)
+	assert.Equal(t, 1, pr.RequestedReviewerCount)
+	assert.False(t, pr.MergedWithoutApproval)
+
+	pr = metrics.PullRequests[1]
+	assert.Nil(t, pr.MinutesToFirstReview)
+	assert.Nil(t, pr.MinutesToApproval)
+	assert.Equal(t, 0, pr.ReviewRounds)
+	assert.True(t, pr.MergedWithoutApproval)
+
+	assert.Equal(t, float64(30), metrics.Reviews.MedianMinutesToFirstReview)
+	assert.Equal(t, float64(90), metrics.Reviews.MedianMinutesToApproval)
+	assert.Equal(t, float64(1), metrics.Reviews.MedianReviewRounds)
+	assert.Equal(t, float64(1), metrics.Reviews.MedianApprovalCount)
+	assert.Equal(t, 1, metrics.Reviews.MergedWithoutApprovalCount)
+}
+
+func Test_median(t *testing.T) {
+	assert.Equal(t, float64(0), median(nil))
+	assert.Equal(t, float64(2), median([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 3, 2}))
+}
+
+func Test_newGitRepositoryMetric_NoWorkflows(t *testing.T) {
+	r := scm.Repository{
+		ID:        int64(123),
+		Org:       "test-org",
+		Name:      "test-repo",
+		Workflows: []scm.Workflow{},
+	}
+
+	metrics := newGitRepositoryMetric(&r, nil)
+
+	assert.Nil(t, metrics.Build)
+}
+
+func Test_newBuildMetric(t *testing.T) {
+	now := time.Date(2021, 10, 31, 12, 0, 0, 0, time.UTC)
+	run := func(workflowID int64, age time.Duration, minutes int, status string, conclusion string) scm.WorkflowRun {
+		created := now.Add(-age)
+		updated := created.Add(time.Duration(minutes) * time.Minute)
+		return scm.WorkflowRun{
+			WorkflowID: workflowID,
+			Name:       "unknown",
+			Status:     status,
+			Conclusion: conclusion,
+			Created:    &created,
+			Updated:    &updated,
+		}
+	}
+	workflows := []scm.Workflow{
+		{ID: 1, Name: "ci"},
+		{ID: 2, Name: "release"},
+	}
+	runs := []scm.WorkflowRun{
+		run(1, time.Hour, 4, "completed", "success"),
+		run(1, 3*24*time.Hour, 2, "completed", "failure"),
+		run(1, 10*24*time.Hour, 6, "completed", "success"),
+		run(2, 20*24*time.Hour, 10, "completed", "cancelled"),
+		run(2, 2*time.Hour, 0, "in_progress", ""),
+		run(2, 40*24*time.Hour, 10, "completed", "success"),
+	}
+
+	build := newBuildMetric(workflows, runs, now)
+
+	if !assert.NotNil(t, build) {
+		return
+	}
+	assert.Equal(t, 2, build.BuildsTodayCount)
+	assert.Equal(t, 3, build.BuildsWeekCount)
+	assert.Equal(t, 5, build.BuildsMonthCount)
+	assert.Equal(t, float32(5.5), build.AvgBuildMinutesLastMonth)
+	assert.InDelta(t, 66.67, build.SuccessPctLastMonth, 0.01)
+	assert.Equal(t, 2, len(build.Workflows))
+	assert.Equal(t, "ci", build.Workflows[0].Name)
+	assert.Equal(t, 3, build.Workflows[0].BuildsMonthCount)
+	assert.Equal(t, float32(4), build.Workflows[0].AvgBuildMinutesLastMonth)
+	assert.InDelta(t, 66.67, build.Workflows[0].SuccessPctLastMonth, 0.01)
+	assert.Equal(t, "release", build.Workflows[1].Name)
+	assert.Equal(t, 2, build.Workflows[1].BuildsMonthCount)
+	assert.Equal(t, float32(10), build.Workflows[1].AvgBuildMinutesLastMonth)
+	assert.Equal(t, float32(0), build.Workflows[1].SuccessPctLastMonth)
+
+	assert.Nil(t, newBuildMetric(nil, runs, now))
+}
+
+func Test_newCodeQualityMetric(t *testing.T) {
+	alerts := func(severities ...string) []scm.SecurityAlert {
+		var a []scm.SecurityAlert
+		for _, severity := range severities {
+			a = append(a, scm.SecurityAlert{Severity: severity})
+		}
+		return a
+	}
+	security := scm.SecurityAlerts{
+		CodeScanning:       scm.FeatureEnabled,
+		CodeScanningAlerts: alerts("critical", "high", "error", "medium", "low"),
+		Dependabot:         scm.FeatureEnabled,
+		DependabotAlerts:   alerts("critical", "high", "medium", "low", "low"),
+	}
+
+	quality := newCodeQualityMetric(security)
+
+	assert.Equal(t, 1, quality.BlockerCount)
+	assert.Equal(t, 2, quality.CriticalCount)
+	assert.Equal(t, 1, quality.MajorCount)
+	assert.Equal(t, 5, quality.IssueCount)
+	assert.Equal(t, 1, quality.DependabotCriticalCount)
+	assert.Equal(t, 1, quality.DependabotHighCount)
+	assert.Equal(t, 1, quality.DependabotModerateCount)
+	assert.Equal(t, 2, quality.DependabotLowCount)
+	assert.Equal(t, QualitySourceMetric{Source: "github-code-scanning", Status: "enabled"}, quality.CodeScanning)
+	assert.Equal(t, QualitySourceMetric{Source: "github-dependabot", Status: "enabled"}, quality.Dependabot)
+}
+
+func Test_newCodeQualityMetric_NotEnabled(t *testing.T) {
+	quality := newCodeQualityMetric(scm.SecurityAlerts{CodeScanning: scm.FeatureNotEnabled})
+
+	assert.Equal(t, 0, quality.IssueCount)
+	assert.Equal(t, 0, quality.TestCount)
+	assert.Equal(t, float32(0), quality.TestCoveragePct)
+	assert.Equal(t, "not enabled", quality.CodeScanning.Status)
+	assert.Equal(t, "unknown", quality.Dependabot.Status)
+}
+
+func Test_newGitRepositoryMetric_Protection(t *testing.T) {
+	r := scm.Repository{
+		ID:     int64(123),
+		Org:    "test-org",
+		Name:   "test-repo",
+		Detail: scm.Detail{DefaultBranch: "main"},
+		Protection: scm.BranchProtection{
+			Status: scm.FeatureEnabled,
+			Settings: &scm.ProtectionSettings{
+				RequiredApprovingReviewCount: 2,
+				DismissStaleReviews:          true,
+				RequireCodeOwnerReviews:      true,
+				RequiredStatusChecks:         []string{"build", "test"},
+				StrictStatusChecks:           true,
+				EnforceAdmins:                true,
+				RequireLinearHistory:         true,
+				AllowForcePushes:             false,
+				AllowDeletions:               true,
+			},
+		},
+	}
+
+	metrics := newGitRepositoryMetric(&r, nil)
+
+	assert.Equal(t, BranchProtectionMetric{
+		Status:                       "enabled",
+		RequiredApprovingReviewCount: 2,
+		DismissStaleReviews:          true,
+		RequireCodeOwnerReviews:      true,
+		RequiredStatusChecks:         []string{"build", "test"},
+		StrictStatusChecks:           true,
+		EnforceAdmins:                true,
+		RequireLinearHistory:         true,
+		AllowForcePushes:             false,
+		AllowDeletions:               true,
+	}, metrics.Protection)
+}
+
+func Test_newBranchProtectionMetric_NotProtected(t *testing.T) {
+	metric := newBranchProtectionMetric(scm.BranchProtection{Status: scm.FeatureNotEnabled})
+
+	assert.Equal(t, BranchProtectionMetric{Status: "not enabled"}, metric)
+}
@@ -20,30 +20,30 @@ type MongoDataManager struct {
 }
 
 // StoreMetrics Persist the supplied metrics
-func (mdm MongoDataManager) StoreMetrics(metrics GitRepositoryMetric) error {
+func (mdm MongoDataManager) StoreMetrics(ctx context.Context, metrics GitRepositoryMetric) error {
 	glog.V(2).Infof("Writing metric data for repository %s", metrics.RepositoryName)
-	collection, err := mdm.collection("metrics")
+	collection, err := mdm.collection(ctx, "metrics")
 	if err != nil {
 		return err
 	}
 
 	filter := bson.M{"org": metrics.Org, "repositoryName": metrics.RepositoryName}
 	_, err = collection.ReplaceOne(
-		context.Background(), filter, metrics, &options.ReplaceOptions{Upsert: &[]bool{true}[0]})
+		ctx, filter, metrics, &options.ReplaceOptions{Upsert: &[]bool{true}[0]})
 	return err
 }
 
 // ReadMetrics Read the metrics for supplied repository
-func (mdm MongoDataManager) ReadMetrics(org string, repo string) (found bool, metric *GitRepositoryMetric, err error) {
+func (mdm MongoDataManager) ReadMetrics(ctx context.Context, org string, repo string) (found bool, metric *GitRepositoryMetric, err error) {
 	glog.V(2).Infof("Reading metric data for repository %s/%s from mongo", org, repo)
-	collection, err := mdm.collection("metrics")
+	collection, err := mdm.collection(ctx, "metrics")
 	if err != nil {
 		return false, nil, err
 	}
 
 	metric = &GitRepositoryMetric{}
 	filter := bson.M{"org": org, "repositoryName": repo}
-	if err = collection.FindOne(context.Background(), filter).Decode(metric); err != nil {
+	if err = collection.FindOne(ctx, filter).Decode(metric); err != nil {
 		if err == mongo.ErrNoDocuments {
 			return false, nil, nil
 		}
@@ -54,23 +54,23 @@ func (mdm MongoDataManager) ReadMetrics(org string, repo string) (found bool, me
 }
 
 // DeleteMetrics Delete the metrics for the supplied repository
-func (mdm MongoDataManager) DeleteMetrics(org string, repo string) error {
+func (mdm MongoDataManager) DeleteMetrics(ctx context.Context, org string, repo string) error {
 	glog.V(2).Infof("Deleting metric data for repository %s/%s from mongo", org, repo)
-	collection, err := mdm.collection("metrics")
+	collection, err := mdm.collection(ctx, "metrics")
 	if err != nil {
 		return err
 	}
 
 	filter := bson.M{"org": org, "repositoryName": repo}
-	_, err = collection.DeleteOne(context.Background(), filter)
+	_, err = collection.DeleteOne(ctx, filter)
 	return err
 }
 
 // ListMetrics List the known repositories with metrics that match the supplied options
-func (mdm MongoDataManager) ListMetrics(opts ListMetricOptions) ([]Key, error) {
+func (mdm MongoDataManager) ListMetrics(ctx context.Context, opts ListMetricOptions) ([]Key, error) {
 	// get collection
 	glog.V(2).Infof("Listing repository metrics found in mongo")
-	collection, err := mdm.collection("metrics")
+	collection, err := mdm.collection(ctx, "metrics")
 	if err != nil {
 		return nil, err
 	}
@@ -90,13 +90,13 @@ func (mdm MongoDataManager) ListMetrics(opts ListMetricOptions) ([]Key, error) {
 
 	// find all metrics and pull out keys
 	findOpts := options.FindOptions{Projection: bson.M{"org": 1, "repositoryName": 1}}
-	cursor, err := collection.Find(context.Background(), filter, &findOpts)
+	cursor, err := collection.Find(ctx, filter, &findOpts)
 	if err != nil {
 		return nil, err
 	}
 
 	var results []GitRepositoryMetric
-	if err = cursor.All(context.TODO(), &results); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
@@ -108,9 +108,9 @@ func (mdm MongoDataManager) ListMetrics(opts ListMetricOptions) ([]Key, error) {
 }
 
 // StoreCacheStats store the statistics for overall cache statistics
-func (mdm MongoDataManager) StoreCacheStats(org string, stats CacheStats) {
+func (mdm MongoDataManager) StoreCacheStats(ctx context.Context, org string, stats CacheStats) {
 	glog.V(2).Infof("Writing cache stats to mongo for org %s", org)
-	collection, err := mdm.collection("stats")
+	collection, err := mdm.collection(ctx, "stats")
 	if err != nil {
 		glog.Warning("Unable to connect to mongo collection: ", err)
 		return
@@ -119,16 +119,16 @@ func (mdm MongoDataManager) StoreCacheStats(org string, stats CacheStats) {
 	stats.Org = org
 	filter := bson.M{"org": org}
 	_, err = collection.ReplaceOne(
-		context.Background(), filter, stats, &options.ReplaceOptions{Upsert: &[]bool{true}[0]})
+		ctx, filter, stats, &options.ReplaceOptions{Upsert: &[]bool{true}[0]})
 	if err != nil {
 		glog.Warning("Unable to store cache stats in mongo", err)
 	}
 }
 
 // ReadCacheStats read the overall cache statistics
-func (mdm MongoDataManager) ReadCacheStats(org string) (found bool, stats *CacheStats) {
+func (mdm MongoDataManager) ReadCacheStats(ctx context.Context, org string) (found bool, stats *CacheStats) {
 	glog.V(2).Infof("Reading cache stats from mongo")
-	collection, err := mdm.collection("stats")
+	collection, err := mdm.collection(ctx, "stats")
 	if err != nil {
 		glog.Warning("Unable to connect to mongo collection: ", err)
 		return false, nil
@@ -136,7 +136,7 @@ func (mdm MongoDataManager) ReadCacheStats(org string) (found bool, stats *Cache
 
 	filter := bson.M{"org": org}
 	stats = &CacheStats{}
-	if err = collection.FindOne(context.Background(), filter).Decode(stats); err != nil {
+	if err = collection.FindOne(ctx, filter).Decode(stats); err != nil {
 		glog.Warningf("Unable to read stats from mongo for org %s: %v", org, err)
 		return false, nil
 	}
@@ -147,8 +147,8 @@ func (mdm MongoDataManager) ReadCacheStats(org string) (found bool, stats *Cache
 }
 
 // get connection to collection with supplied name
-func (mdm MongoDataManager) collection(collection string) (*mongo.Collection, error) {
-	client, err := mdm.connect()
+func (mdm MongoDataManager) collection(ctx context.Context, collection string) (*mongo.Collection, error) {
+	client, err := mdm.connect(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -156,9 +156,9 @@ func (mdm MongoDataManager) collection(collection string) (*mongo.Collection, er
 }
 
 // connect get connection to mongo database
-func (mdm MongoDataManager) connect() (*mongo.Client, error) {
+func (mdm MongoDataManager) connect(ctx context.Context) (*mongo.Client, error) {
 	if mdm.client == nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 
 		creds := options.Credential{
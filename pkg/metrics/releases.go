@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+	"github.com/day2devops/ea-metric-extractor/pkg/version"
+)
+
+// ReleaseMetric defines structure for the cadence and versioning of the releases of a repository.  The latest
+// release, cadence and version bumps only consider published releases, leaving out drafts and prereleases.
+type ReleaseMetric struct {
+	LatestVersion     string              `json:"latestVersion" bson:"latestVersion"`
+	LatestPublished   *time.Time          `json:"latestPublished" bson:"latestPublished"`
+	LatestAgeDays     *float64            `json:"latestAgeDays" bson:"latestAgeDays"`
+	MedianDaysBetween *float64            `json:"medianDaysBetween" bson:"medianDaysBetween"`
+	SemVerPct         float32             `json:"semVerPct" bson:"semVerPct"`
+	PrereleaseCount   int                 `json:"prereleaseCount" bson:"prereleaseCount"`
+	DraftCount        int                 `json:"draftCount" bson:"draftCount"`
+	DownloadCount     int                 `json:"downloadCount" bson:"downloadCount"`
+	Bumps             ReleaseBumpMetric   `json:"bumps" bson:"bumps"`
+	WindowBumps       []ReleaseBumpMetric `json:"windowBumps" bson:"windowBumps"`
+}
+
+// ReleaseBumpMetric defines structure for the semantic version bumps between consecutive releases, over a day
+// window ending now when the window is set
+type ReleaseBumpMetric struct {
+	WindowDays int `json:"windowDays,omitempty" bson:"windowDays,omitempty"`
+	Major      int `json:"major" bson:"major"`
+	Minor      int `json:"minor" bson:"minor"`
+	Patch      int `json:"patch" bson:"patch"`
+}
+
+// published release along with its semantic version, nil when the tag isn't a semantic version
+type publishedRelease struct {
+	tag       string
+	published time.Time
+	version   *version.Version
+}
+
+// kind of version bump between consecutive releases
+type versionBump int
+
+const (
+	bumpNone versionBump = iota
+	bumpMajor
+	bumpMinor
+	bumpPatch
+)
+
+// newReleaseMetric release metrics from the releases, version bumps also counted for each of the day windows
+// ending now.  Nil when the repository has no releases.
+func newReleaseMetric(releases []scm.Release, windows []int, now time.Time) *ReleaseMetric {
+	if len(releases) == 0 {
+		return nil
+	}
+
+	metric := &ReleaseMetric{}
+	tagged, semVer := 0, 0
+	var published []publishedRelease
+	for _, r := range releases {
+		metric.DownloadCount += r.Downloads
+
+		var v *version.Version
+		if tag := r.Tag; tag != "" {
+			tagged++
+			// only complete semantic versions are counted and compared for bumps, tags such as v2 or 2023.10
+			// being left out
+			if version.IsGithubSemVer(tag) {
+				semVer++
+				if parsed, err := version.FromGithubVersion(tag); err == nil {
+					v = parsed
+				}
+			}
+		}
+
+		switch {
+		case r.Draft:
+			metric.DraftCount++
+		case r.Prerelease:
+			metric.PrereleaseCount++
+		default:
+			if ts := releaseTime(r); ts != nil {
+				published = append(published, publishedRelease{tag: r.Tag, published: *ts, version: v})
+			}
+		}
+	}
+	if tagged > 0 {
+		metric.SemVerPct = float32(semVer) / float32(tagged) * 100
+	}
+
+	// cadence of the published releases, oldest first
+	sort.Slice(published, func(i, j int) bool { return published[i].published.Before(published[j].published) })
+	if len(published) > 0 {
+		latest := published[len(published)-1]
+		age := now.Sub(latest.published).Hours() / 24
+		metric.LatestVersion = latest.tag
+		metric.LatestPublished = &latest.published
+		metric.LatestAgeDays = &age
+	}
+	if len(published) > 1 {
+		var gaps []float64
+		for i := 1; i < len(published); i++ {
+			gaps = append(gaps, published[i].published.Sub(published[i-1].published).Hours()/24)
+		}
+		m := median(gaps)
+		metric.MedianDaysBetween = &m
+	}
+
+	// version bumps between consecutive semantic versions
+	for _, days := range windows {
+		metric.WindowBumps = append(metric.WindowBumps, ReleaseBumpMetric{WindowDays: days})
+	}
+	var previous *publishedRelease
+	for i := range published {
+		current := &published[i]
+		if current.version == nil {
+			continue
+		}
+		if previous != nil {
+			bump := bumpBetween(previous.version, current.version)
+			countBump(&metric.Bumps, bump)
+			for j, days := range windows {
+				if current.published.After(now.AddDate(0, 0, -days)) {
+					countBump(&metric.WindowBumps[j], bump)
+				}
+			}
+		}
+		previous = current
+	}
+	return metric
+}
+
+// publish time of the release, falling back to the creation time when not published
+func releaseTime(r scm.Release) *time.Time {
+	if r.Published != nil {
+		return r.Published
+	}
+	return r.Created
+}
+
+// kind of bump from the previous version to the current version, none when the version didn't increase
+func bumpBetween(previous *version.Version, current *version.Version) versionBump {
+	switch {
+	case current.Major() > previous.Major():
+		return bumpMajor
+	case current.CompareMajorMinor(previous) > 0:
+		return bumpMinor
+	case current.CompareMajorMinor(previous) == 0 && current.GreaterThan(previous.Version):
+		return bumpPatch
+	}
+	return bumpNone
+}
+
+// add the bump to the counts
+func countBump(m *ReleaseBumpMetric, bump versionBump) {
+	switch bump {
+	case bumpMajor:
+		m.Major++
+	case bumpMinor:
+		m.Minor++
+	case bumpPatch:
+		m.Patch++
+	}
+}
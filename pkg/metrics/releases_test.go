@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+	"github.com/day2devops/ea-metric-extractor/pkg/version"
+)
+
+func testRelease(tag string, published time.Time, downloads ...int) scm.Release {
+	release := scm.Release{Tag: tag, Published: &published}
+	for _, d := range downloads {
+		release.Downloads += d
+	}
+	return release
+}
+
+func Test_newReleaseMetric(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	draft := testRelease("v3.0.0", now)
+	draft.Draft = true
+	prerelease := testRelease("v2.1.0-rc.1", now.Add(-3*day))
+	prerelease.Prerelease = true
+	releases := []scm.Release{
+		draft,
+		testRelease("v2.0.1", now.Add(-2*day), 5),
+		prerelease,
+		testRelease("v2.0.0", now.Add(-10*day), 10, 20),
+		testRelease("nightly", now.Add(-20*day)),
+		testRelease("v1.3.0", now.Add(-40*day)),
+		testRelease("v1.2.5", now.Add(-50*day), 100),
+	}
+
+	metric := newReleaseMetric(releases, []int{7, 30}, now)
+
+	assert.Equal(t, "v2.0.1", metric.LatestVersion)
+	assert.Equal(t, now.Add(-2*day), *metric.LatestPublished)
+	assert.Equal(t, 2.0, *metric.LatestAgeDays)
+	// gaps of 10, 20, 10 and 8 days between the published releases
+	assert.Equal(t, 10.0, *metric.MedianDaysBetween)
+	assert.Equal(t, float32(6)/float32(7)*100, metric.SemVerPct)
+	assert.Equal(t, 1, metric.DraftCount)
+	assert.Equal(t, 1, metric.PrereleaseCount)
+	assert.Equal(t, 135, metric.DownloadCount)
+	assert.Equal(t, ReleaseBumpMetric{Major: 1, Minor: 1, Patch: 1}, metric.Bumps)
+	assert.Equal(t, []ReleaseBumpMetric{
+		{WindowDays: 7, Patch: 1},
+		{WindowDays: 30, Major: 1, Patch: 1},
+	}, metric.WindowBumps)
+}
+
+func Test_newReleaseMetric_Unpublished(t *testing.T) {
+	draft := testRelease("v1.0.0", time.Now())
+	draft.Draft = true
+
+	metric := newReleaseMetric([]scm.Release{draft}, nil, time.Now())
+
+	assert.Equal(t, "", metric.LatestVersion)
+	assert.Nil(t, metric.LatestAgeDays)
+	assert.Nil(t, metric.MedianDaysBetween)
+	assert.Equal(t, float32(100), metric.SemVerPct)
+	assert.Equal(t, ReleaseBumpMetric{}, metric.Bumps)
+	assert.Nil(t, metric.WindowBumps)
+}
+
+func Test_newReleaseMetric_NotSemVer(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	releases := []scm.Release{
+		testRelease("2023.10", now),
+		testRelease("v2", now.Add(-10*day)),
+		testRelease("1", now.Add(-20*day)),
+		testRelease("v1.0.0", now.Add(-30*day)),
+	}
+
+	metric := newReleaseMetric(releases, nil, now)
+
+	assert.Equal(t, float32(25), metric.SemVerPct)
+	assert.Equal(t, "2023.10", metric.LatestVersion)
+}
+
+func Test_newReleaseMetric_MixedTagBumps(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	releases := []scm.Release{
+		testRelease("2023.10", now),
+		testRelease("v1.2.0", now.Add(-5*day)),
+		testRelease("v2", now.Add(-10*day)),
+		testRelease("v1.1.1", now.Add(-15*day)),
+		testRelease("1", now.Add(-20*day)),
+		testRelease("v1.1.0", now.Add(-30*day)),
+	}
+
+	metric := newReleaseMetric(releases, []int{7}, now)
+
+	// v1.1.0 -> v1.1.1 -> v1.2.0, the incomplete versions neither bumping nor breaking the sequence
+	assert.Equal(t, float32(50), metric.SemVerPct)
+	assert.Equal(t, ReleaseBumpMetric{Minor: 1, Patch: 1}, metric.Bumps)
+	assert.Equal(t, []ReleaseBumpMetric{{WindowDays: 7, Minor: 1}}, metric.WindowBumps)
+}
+
+func Test_newReleaseMetric_NoReleases(t *testing.T) {
+	assert.Nil(t, newReleaseMetric(nil, []int{7}, time.Now()))
+}
+
+func Test_bumpBetween(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		current  string
+		expected versionBump
+	}{
+		{"major bump", "1.4.2", "2.0.0", bumpMajor},
+		{"minor bump", "1.4.2", "1.5.0", bumpMinor},
+		{"patch bump", "1.4.2", "1.4.3", bumpPatch},
+		{"same version", "1.4.2", "1.4.2", bumpNone},
+		{"backported patch", "1.5.0", "1.4.3", bumpNone},
+		{"older major", "2.0.0", "1.9.0", bumpNone},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, bumpBetween(version.MustParse(tt.previous), version.MustParse(tt.current)))
+		})
+	}
+}
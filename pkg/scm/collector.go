@@ -0,0 +1,55 @@
+package scm
+
+import (
+	"context"
+	"time"
+)
+
+// DataCollector defines methods for collecting repositories from a source control provider
+type DataCollector interface {
+	ListOrganizations(ctx context.Context) ([]string, error)
+	ListRepositories(ctx context.Context, org string, changedAfter *time.Time) ([]Repository, error)
+	GetRepository(ctx context.Context, org string, name string, opts CollectOptions) (*Repository, error)
+}
+
+// PageLimits maximum number of pages (of 100 items) collected per repository for each resource.  The
+// default of 10 pages is used when a limit is zero and the resource is collected in full when negative.
+type PageLimits struct {
+	Branches       int
+	Releases       int
+	PullRequests   int
+	WorkflowRuns   int
+	Deployments    int
+	Issues         int
+	SecurityAlerts int
+	Contributors   int
+}
+
+// CollectOptions options to use when collecting repository data
+type CollectOptions struct {
+	// PullRequestsSince restricts pull requests to those updated since the supplied time, all
+	// pull requests are collected when not supplied
+	PullRequestsSince *time.Time
+	// DeploymentsSince collects the deployments created since the supplied time, deployments aren't
+	// collected when not supplied
+	DeploymentsSince *time.Time
+	// HygieneFiles governance files to look for, not looked for when empty
+	HygieneFiles []HygieneFile
+}
+
+// HygieneFile governance file expected in a repository, present when found at any of its paths
+type HygieneFile struct {
+	Name  string   `json:"name"`
+	Paths []string `json:"paths"`
+}
+
+// DefaultHygieneFiles governance files checked when not configured
+var DefaultHygieneFiles = []HygieneFile{
+	{Name: "readme", Paths: []string{"README.md"}},
+	{Name: "license", Paths: []string{"LICENSE", "LICENSE.md"}},
+	{Name: "codeowners", Paths: []string{"CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"}},
+	{Name: "security", Paths: []string{"SECURITY.md", ".github/SECURITY.md", "docs/SECURITY.md"}},
+	{Name: "contributing", Paths: []string{"CONTRIBUTING.md", ".github/CONTRIBUTING.md", "docs/CONTRIBUTING.md"}},
+	{Name: "dependabot", Paths: []string{".github/dependabot.yml", ".github/dependabot.yaml"}},
+	{Name: "pull-request-template", Paths: []string{".github/pull_request_template.md", "pull_request_template.md", "docs/pull_request_template.md", ".github/PULL_REQUEST_TEMPLATE"}},
+}
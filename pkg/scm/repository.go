@@ -0,0 +1,223 @@
+package scm
+
+import (
+	"time"
+)
+
+// Repository provider neutral repository data the metrics are extracted from, sections a provider doesn't
+// collect are left empty
+type Repository struct {
+	ID int64
+	// Provider source control provider the repository was collected from
+	Provider string
+	Org      string
+	Name     string
+	Topics   []string
+	Changed  *time.Time
+	Detail   Detail
+	// Branches along with the activity of the branches other than the default branch by name, the activity is
+	// nil when not collected
+	Branches       []Branch
+	BranchActivity map[string]BranchActivity
+	// Protection settings of the default branch
+	Protection   BranchProtection
+	Releases     []Release
+	PullRequests []PullRequest
+	// Reviews submitted on the collected pull requests by pull request number
+	Reviews   map[int][]Review
+	Languages map[string]int
+	// Contributors along with their commit counts, pending set when the counts weren't available
+	Contributors        []Contributor
+	ContributorsPending bool
+	// Workflows defined for the builds, nil when not collected, along with the recent runs
+	Workflows    []Workflow
+	WorkflowRuns []WorkflowRun
+	Security     SecurityAlerts
+	// Deployments created since the collection option along with their statuses by deployment ID
+	Deployments        []Deployment
+	DeploymentStatuses map[int64][]DeploymentStatus
+	// Issues, nil when not collected, along with their comments by issue number
+	Issues        []Issue
+	IssueComments map[int][]IssueComment
+	// HygieneFiles presence of the governance files by name, nil when not collected
+	HygieneFiles map[string]bool
+	Truncated    Truncated
+	// PullRequestsSince set when only pull requests updated since the time were collected
+	PullRequestsSince *time.Time
+	// SectionErrors errors of the sections that failed to collect by section, nil when every section was collected
+	SectionErrors map[string]error
+}
+
+// Detail settings and timestamps of a repository
+type Detail struct {
+	Created       *time.Time
+	Updated       *time.Time
+	Pushed        *time.Time
+	DefaultBranch string
+	Squashable    bool
+	Rebaseable    bool
+	Archived      bool
+	Fork          bool
+	Template      bool
+	Private       bool
+	// Visibility public, private or internal, derived from private when empty
+	Visibility string
+}
+
+// Branch of a repository
+type Branch struct {
+	Name      string
+	Protected bool
+}
+
+// BranchActivity last commit and merge state of a branch compared with the default branch
+type BranchActivity struct {
+	LastCommit *time.Time
+	// Merged set when all the commits of the branch are in the default branch
+	Merged bool
+}
+
+// FeatureStatus availability of an optional repository feature such as code scanning
+type FeatureStatus string
+
+// Feature statuses, unknown when the status couldn't be determined (e.g. missing permissions)
+const (
+	FeatureEnabled    FeatureStatus = "enabled"
+	FeatureNotEnabled FeatureStatus = "not enabled"
+	FeatureUnknown    FeatureStatus = "unknown"
+)
+
+// BranchProtection protection settings of a branch along with whether the branch is protected, the settings
+// are only available when the status is enabled
+type BranchProtection struct {
+	Status   FeatureStatus
+	Settings *ProtectionSettings
+}
+
+// ProtectionSettings rules protecting a branch
+type ProtectionSettings struct {
+	RequiredApprovingReviewCount int
+	DismissStaleReviews          bool
+	RequireCodeOwnerReviews      bool
+	RequiredStatusChecks         []string
+	StrictStatusChecks           bool
+	EnforceAdmins                bool
+	RequireLinearHistory         bool
+	AllowForcePushes             bool
+	AllowDeletions               bool
+}
+
+// Release of a repository along with the downloads of its assets
+type Release struct {
+	ID         int64
+	Name       string
+	Tag        string
+	Draft      bool
+	Prerelease bool
+	Created    *time.Time
+	Published  *time.Time
+	Downloads  int
+}
+
+// PullRequest pull (or merge) request of a repository
+type PullRequest struct {
+	ID     int64
+	Number int
+	State  string
+	Author string
+	// RequestedReviewers number of reviewers, users or teams, asked to review
+	RequestedReviewers int
+	Created            *time.Time
+	Updated            *time.Time
+	Closed             *time.Time
+	Merged             *time.Time
+}
+
+// Review submitted on a pull request, the state being APPROVED, CHANGES_REQUESTED, COMMENTED or PENDING
+type Review struct {
+	ID        int64
+	State     string
+	Author    string
+	CommitID  string
+	Submitted *time.Time
+}
+
+// Contributor author of commits to a repository
+type Contributor struct {
+	Login   string
+	Commits int
+}
+
+// Workflow build workflow defined for a repository
+type Workflow struct {
+	ID   int64
+	Name string
+}
+
+// WorkflowRun run of a build workflow
+type WorkflowRun struct {
+	WorkflowID int64
+	Name       string
+	Status     string
+	Conclusion string
+	Created    *time.Time
+	Updated    *time.Time
+}
+
+// WorkflowRunHistory how far back workflow runs are collected for the build metrics
+const WorkflowRunHistory = 30 * 24 * time.Hour
+
+// SecurityAlerts open security alerts along with the status of the features raising them
+type SecurityAlerts struct {
+	CodeScanning       FeatureStatus
+	CodeScanningAlerts []SecurityAlert
+	Dependabot         FeatureStatus
+	DependabotAlerts   []SecurityAlert
+}
+
+// SecurityAlert open security alert, the severity being as reported by the provider
+type SecurityAlert struct {
+	Severity string
+}
+
+// Deployment of a repository to an environment
+type Deployment struct {
+	ID          int64
+	SHA         string
+	Environment string
+	Created     *time.Time
+}
+
+// DeploymentStatus status reported for a deployment
+type DeploymentStatus struct {
+	State   string
+	Created *time.Time
+}
+
+// Issue raised against a repository
+type Issue struct {
+	Number  int
+	State   string
+	Author  string
+	Labels  []string
+	Created *time.Time
+	Closed  *time.Time
+}
+
+// IssueComment comment on an issue
+type IssueComment struct {
+	Author  string
+	Created *time.Time
+}
+
+// Truncated indicates the resources only partially collected due to the page limits
+type Truncated struct {
+	Branches       bool
+	Releases       bool
+	PullRequests   bool
+	WorkflowRuns   bool
+	Deployments    bool
+	Issues         bool
+	SecurityAlerts bool
+	Contributors   bool
+}
@@ -0,0 +1,84 @@
+package scm
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// Sections of a repository collected on their own terms, a section failing to collect is recorded against the
+// repository rather than failing the repository
+const (
+	SectionBranches     = "branches"
+	SectionReleases     = "releases"
+	SectionPullRequests = "pullRequests"
+	SectionReviews      = "reviews"
+	SectionLanguages    = "languages"
+	SectionTopics       = "topics"
+	SectionContributors = "contributors"
+	// SectionBranchActivity last commits and merge states of the branches
+	SectionBranchActivity = "branchActivity"
+	// SectionProtection protection settings of the default branch
+	SectionProtection  = "protection"
+	SectionWorkflows   = "workflows"
+	SectionSecurity    = "security"
+	SectionDeployments = "deployments"
+	SectionIssues      = "issues"
+	SectionHygiene     = "hygiene"
+)
+
+// Sections all the sections of a repository collected on their own terms
+var Sections = []string{
+	SectionBranches,
+	SectionReleases,
+	SectionPullRequests,
+	SectionReviews,
+	SectionLanguages,
+	SectionTopics,
+	SectionContributors,
+	SectionBranchActivity,
+	SectionProtection,
+	SectionWorkflows,
+	SectionSecurity,
+	SectionDeployments,
+	SectionIssues,
+	SectionHygiene,
+}
+
+// SectionFailures errors of the sections of a repository that failed to collect, safe to record from concurrent
+// goroutines
+type SectionFailures struct {
+	Org    string
+	Name   string
+	mu     sync.Mutex
+	errors map[string]error
+}
+
+// Fail records the section of the repository failed to collect
+func (s *SectionFailures) Fail(section string, err error) {
+	glog.Warningf("Problem collecting %s for %s/%s: %s", section, s.Org, s.Name, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errors == nil {
+		s.errors = make(map[string]error)
+	}
+	s.errors[section] = err
+}
+
+// Failed returns the errors by section, nil when every section was collected
+func (s *SectionFailures) Failed() map[string]error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errors
+}
+
+// FailedSections names of the sections of the repository that failed to collect in name order
+func (r Repository) FailedSections() []string {
+	var sections []string
+	for section := range r.SectionErrors {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	return sections
+}
@@ -0,0 +1,33 @@
+package scm
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSectionFailures(t *testing.T) {
+	failures := &SectionFailures{Org: "testorg", Name: "testrepo"}
+	assert.Nil(t, failures.Failed())
+
+	var wg sync.WaitGroup
+	for _, section := range []string{SectionTopics, SectionBranches, SectionLanguages} {
+		wg.Add(1)
+		go func(section string) {
+			defer wg.Done()
+			failures.Fail(section, errors.New("api error"))
+		}(section)
+	}
+	wg.Wait()
+
+	r := Repository{SectionErrors: failures.Failed()}
+	assert.Equal(t, 3, len(r.SectionErrors))
+	assert.EqualError(t, r.SectionErrors[SectionBranches], "api error")
+	assert.Equal(t, []string{SectionBranches, SectionLanguages, SectionTopics}, r.FailedSections())
+}
+
+func TestFailedSections_None(t *testing.T) {
+	assert.Nil(t, Repository{}.FailedSections())
+}
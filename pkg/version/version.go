@@ -96,6 +96,13 @@ func FromGithubVersion(v string) (*Version, error) {
 	return New(Clean(v))
 }
 
+// IsGithubSemVer determines if the github version is a complete semver once the "v" is removed, v1.5.2 being
+// one where v2 or 2023.10 aren't even though FromGithubVersion coerces them into a version
+func IsGithubSemVer(v string) bool {
+	_, err := semver.StrictNewVersion(Clean(v))
+	return err == nil
+}
+
 // FromSemVer converts a semver.Version to our Version
 func FromSemVer(v *semver.Version) *Version {
 	return &Version{v}
@@ -88,3 +88,12 @@ func TestFromGithubVersion(t *testing.T) {
 	assert.Equal(t, uint64(4), v.Minor())
 	assert.Equal(t, uint64(5), v.Patch())
 }
+
+func TestIsGithubSemVer(t *testing.T) {
+	for _, v := range []string{"v1.5.2", "1.5.2", "v2.1.0-rc.1", "1.0.0+build.5"} {
+		assert.True(t, IsGithubSemVer(v), v)
+	}
+	for _, v := range []string{"1", "v2", "2023.10", "v1.2.3.4", "nightly", "vv1.2.3", ""} {
+		assert.False(t, IsGithubSemVer(v), v)
+	}
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// debouncer delays calls by key until no further calls for the key arrive for the delay, only the latest call
+// being made.  Calls for the same key never run at the same time.
+type debouncer struct {
+	delay   time.Duration
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	seq     map[string]int
+	lastSeq int
+	running map[string]bool
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// construct a debouncer delaying calls for the supplied duration
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{
+		delay:   delay,
+		timers:  make(map[string]*time.Timer),
+		seq:     make(map[string]int),
+		running: make(map[string]bool),
+	}
+}
+
+// trigger the call for the key after the delay, replacing any call still waiting for the key
+func (d *debouncer) trigger(key string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	if t, found := d.timers[key]; found {
+		t.Stop()
+	}
+	// sequence numbers are never reused, so a call replaced before its entry was evicted can't be made
+	d.lastSeq++
+	d.seq[key] = d.lastSeq
+	d.schedule(key, d.seq[key], fn)
+}
+
+// schedule the call after the delay, the lock must be held
+func (d *debouncer) schedule(key string, seq int, fn func()) {
+	d.timers[key] = time.AfterFunc(d.delay, func() {
+		d.fire(key, seq, fn)
+	})
+}
+
+// make the call unless replaced since scheduled, waiting for another delay while a call for the key is running
+func (d *debouncer) fire(key string, seq int, fn func()) {
+	d.mu.Lock()
+	if d.closed || d.seq[key] != seq {
+		d.mu.Unlock()
+		return
+	}
+	if d.running[key] {
+		d.schedule(key, seq, fn)
+		d.mu.Unlock()
+		return
+	}
+	delete(d.timers, key)
+	d.running[key] = true
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.running, key)
+		if d.seq[key] == seq {
+			// nothing further triggered for the key, so it no longer needs tracking
+			delete(d.seq, key)
+		}
+		d.mu.Unlock()
+		d.wg.Done()
+	}()
+	fn()
+}
+
+// stop the calls still waiting and wait for the running calls to finish, returning the number of calls dropped
+func (d *debouncer) stop() int {
+	d.mu.Lock()
+	d.closed = true
+	dropped := 0
+	for _, t := range d.timers {
+		if t.Stop() {
+			dropped++
+		}
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+	return dropped
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebouncer_BurstCalledOnce(t *testing.T) {
+	d := newDebouncer(20 * time.Millisecond)
+	var calls, last int32
+	for i := int32(1); i <= 5; i++ {
+		i := i
+		d.trigger("testorg/testrepo", func() {
+			atomic.AddInt32(&calls, 1)
+			atomic.StoreInt32(&last, i)
+		})
+	}
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, d.stop())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&last))
+}
+
+func TestDebouncer_EvictsFiredKeys(t *testing.T) {
+	d := newDebouncer(5 * time.Millisecond)
+	var calls int32
+	d.trigger("testorg/repo1", func() { atomic.AddInt32(&calls, 1) })
+	d.trigger("testorg/repo2", func() { atomic.AddInt32(&calls, 1) })
+
+	assert.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.seq) == 0 && len(d.timers) == 0 && len(d.running) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// keys fire again once evicted
+	d.trigger("testorg/repo1", func() { atomic.AddInt32(&calls, 1) })
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 3 }, time.Second, 5*time.Millisecond)
+	d.stop()
+}
+
+func TestDebouncer_KeysIndependent(t *testing.T) {
+	d := newDebouncer(10 * time.Millisecond)
+	var calls int32
+	d.trigger("testorg/repo1", func() { atomic.AddInt32(&calls, 1) })
+	d.trigger("testorg/repo2", func() { atomic.AddInt32(&calls, 1) })
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 2 }, time.Second, 5*time.Millisecond)
+	d.stop()
+}
+
+func TestDebouncer_WaitsForRunningCall(t *testing.T) {
+	d := newDebouncer(5 * time.Millisecond)
+	var running, overlapped, calls int32
+	call := func() {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(30 * time.Millisecond)
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&running, -1)
+	}
+
+	d.trigger("testorg/testrepo", call)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&running) == 1 }, time.Second, time.Millisecond)
+	d.trigger("testorg/testrepo", call)
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 2 }, time.Second, 5*time.Millisecond)
+	d.stop()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlapped))
+}
+
+func TestDebouncer_StopDropsPending(t *testing.T) {
+	d := newDebouncer(time.Hour)
+	called := false
+	d.trigger("testorg/testrepo", func() { called = true })
+
+	assert.Equal(t, 1, d.stop())
+	d.trigger("testorg/testrepo", func() { called = true })
+	assert.False(t, called)
+}
@@ -0,0 +1,251 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	gogithub "github.com/google/go-github/v39/github"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/metrics"
+	"github.com/day2devops/ea-metric-extractor/pkg/scm"
+)
+
+// maxPayloadBytes largest webhook payload accepted, GitHub caps payloads at 25MB
+const maxPayloadBytes = 25 << 20
+
+// Handler receives GitHub webhook events, updating the metrics of a repository once events for the repository
+// stop arriving for the debounce delay and deleting the metrics of deleted repositories, renamed or transferred
+// repositories and repositories excluded by the repository filter of the options
+type Handler struct {
+	ctx       context.Context
+	secret    []byte
+	orgs      []string
+	processor metrics.Processor
+	options   metrics.Options
+	debouncer *debouncer
+}
+
+// NewHandler construct a webhook handler verifying event signatures with the secret and ignoring events for
+// repositories outside the organizations, accepting any organization when none are supplied.  Metric updates
+// run with the supplied context and options.
+func NewHandler(ctx context.Context, secret []byte, orgs []string, processor metrics.Processor, options metrics.Options, debounce time.Duration) *Handler {
+	return &Handler{
+		ctx:       ctx,
+		secret:    secret,
+		orgs:      orgs,
+		processor: processor,
+		options:   options,
+		debouncer: newDebouncer(debounce),
+	}
+}
+
+// ServeHTTP verifies and handles a GitHub webhook event
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// only accept events signed with the secret using SHA-256
+	signature := r.Header.Get(gogithub.SHA256SignatureHeader)
+	if signature == "" {
+		http.Error(w, "missing signature", http.StatusUnauthorized)
+		return
+	}
+	payload, err := gogithub.ValidatePayloadFromBody(r.Header.Get("Content-Type"), http.MaxBytesReader(w, r.Body, maxPayloadBytes), signature, h.secret)
+	if err != nil {
+		glog.Warningf("Rejected webhook delivery %s: %s", gogithub.DeliveryID(r), err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventType := gogithub.WebHookType(r)
+	glog.V(2).Infof("Received %s webhook delivery %s", eventType, gogithub.DeliveryID(r))
+	if eventType == "ping" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if !supportedEvent(eventType) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	event, err := gogithub.ParseWebHook(eventType, payload)
+	if err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	// the repository and the changes made to it are only partially exposed by the parsed events
+	var details eventDetails
+	if err := json.Unmarshal(payload, &details); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	org, repo, deleted := repositoryOf(event)
+
+	// the metrics recorded under the previous name or owner of a moved repository are no longer updated, even
+	// when moved out of the organizations handled
+	moved := false
+	if e, ok := event.(*gogithub.RepositoryEvent); ok {
+		oldOrg, oldRepo, found := details.previousName(e.GetAction(), org, repo)
+		if found && h.acceptsOrg(oldOrg) {
+			glog.V(2).Infof("Repository %s/%s %s to %s/%s", oldOrg, oldRepo, e.GetAction(), org, repo)
+			h.debouncer.trigger(oldOrg+"/"+oldRepo, func() { h.delete(oldOrg, oldRepo) })
+			moved = true
+		}
+	}
+
+	if repo == "" || !h.acceptsOrg(org) {
+		glog.V(2).Infof("Ignoring %s event for repository %s/%s", eventType, org, repo)
+		status := http.StatusNoContent
+		if moved {
+			status = http.StatusAccepted
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	// excluded repositories have their metrics removed, as when updating the organization
+	if !deleted && !h.options.Filter.Includes(details.repository(org, repo)) {
+		glog.V(2).Infof("Repository excluded by filters: %s/%s", org, repo)
+		deleted = true
+	}
+
+	if deleted {
+		h.debouncer.trigger(org+"/"+repo, func() { h.delete(org, repo) })
+	} else {
+		h.debouncer.trigger(org+"/"+repo, func() { h.update(org, repo) })
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Close stops the metric updates still waiting for the debounce delay and waits for running updates to finish
+func (h *Handler) Close() {
+	if dropped := h.debouncer.stop(); dropped > 0 {
+		glog.Warningf("Dropped %d pending repository metric updates", dropped)
+	}
+}
+
+// determine if events for repositories of the organization are handled
+func (h *Handler) acceptsOrg(org string) bool {
+	if len(h.orgs) == 0 {
+		return true
+	}
+	for _, o := range h.orgs {
+		if strings.EqualFold(o, org) {
+			return true
+		}
+	}
+	return false
+}
+
+// update the repository metrics, limiting the time spent when a repository timeout is set
+func (h *Handler) update(org string, repo string) {
+	ctx := h.ctx
+	if h.options.RepoTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.options.RepoTimeout)
+		defer cancel()
+	}
+	if err := h.processor.Repository(ctx, org, repo, h.options); err != nil {
+		glog.Errorf("Error updating metrics for repository %s/%s: %s", org, repo, err)
+	}
+}
+
+// delete the metrics of the deleted repository
+func (h *Handler) delete(org string, repo string) {
+	if err := h.processor.DeleteRepository(h.ctx, org, repo); err != nil {
+		glog.Errorf("Error deleting metrics for repository %s/%s: %s", org, repo, err)
+	}
+}
+
+// determine if the event type changes repository metrics
+func supportedEvent(eventType string) bool {
+	switch eventType {
+	case "push", "pull_request", "release", "repository", "create", "delete":
+		return true
+	}
+	return false
+}
+
+// organization and name of the repository the event is for, along with whether the repository was deleted
+func repositoryOf(event interface{}) (org string, repo string, deleted bool) {
+	switch e := event.(type) {
+	case *gogithub.PushEvent:
+		return e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(), false
+	case *gogithub.PullRequestEvent:
+		return e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(), false
+	case *gogithub.ReleaseEvent:
+		return e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(), false
+	case *gogithub.CreateEvent:
+		return e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(), false
+	case *gogithub.DeleteEvent:
+		return e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(), false
+	case *gogithub.RepositoryEvent:
+		return e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(), e.GetAction() == "deleted"
+	}
+	return "", "", false
+}
+
+// eventDetails repository of an event along with the changes of a renamed or transferred repository
+type eventDetails struct {
+	Repository *gogithub.Repository `json:"repository"`
+	Changes    struct {
+		Repository struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+		Owner struct {
+			From struct {
+				Organization *gogithub.Organization `json:"organization"`
+				User         *gogithub.User         `json:"user"`
+			} `json:"from"`
+		} `json:"owner"`
+	} `json:"changes"`
+}
+
+// organization and name of the repository before it was renamed or transferred, false for other actions or when
+// the previous name isn't known
+func (d eventDetails) previousName(action string, org string, repo string) (string, string, bool) {
+	switch action {
+	case "renamed":
+		if from := d.Changes.Repository.Name.From; from != "" && from != repo {
+			return org, from, true
+		}
+	case "transferred":
+		from := d.Changes.Owner.From.Organization.GetLogin()
+		if from == "" {
+			from = d.Changes.Owner.From.User.GetLogin()
+		}
+		if from != "" && !strings.EqualFold(from, org) {
+			return from, repo, true
+		}
+	}
+	return "", "", false
+}
+
+// the repository of the event as the repository filter sees it when listing the organization
+func (d eventDetails) repository(org string, repo string) scm.Repository {
+	r := d.Repository
+	if r == nil {
+		r = &gogithub.Repository{}
+	}
+	return scm.Repository{
+		Org:    org,
+		Name:   repo,
+		Topics: r.Topics,
+		Detail: scm.Detail{
+			Archived:   r.GetArchived(),
+			Fork:       r.GetFork(),
+			Template:   r.GetIsTemplate(),
+			Private:    r.GetPrivate(),
+			Visibility: r.GetVisibility(),
+		},
+	}
+}
@@ -0,0 +1,271 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nyarly/spies"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/day2devops/ea-metric-extractor/pkg/metrics"
+)
+
+const testSecret = "testsecret"
+
+func TestServeHTTP_MethodNotAllowed(t *testing.T) {
+	handler, _ := newTestHandler()
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServeHTTP_MissingSignature(t *testing.T) {
+	handler, processor := newTestHandler()
+	defer handler.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(pushPayload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, 0, len(processor.CallsTo("Repository")))
+}
+
+func TestServeHTTP_InvalidSignature(t *testing.T) {
+	handler, processor := newTestHandler()
+	defer handler.Close()
+
+	req := signedRequest("push", pushPayload, "wrongsecret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, 0, len(processor.CallsTo("Repository")))
+}
+
+func TestServeHTTP_Ping(t *testing.T) {
+	handler, _ := newTestHandler()
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("ping", `{"zen":"Keep it logically awesome."}`, testSecret))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServeHTTP_UnsupportedEvent(t *testing.T) {
+	handler, _ := newTestHandler()
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("star", `{"action":"created"}`, testSecret))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestServeHTTP_InvalidPayload(t *testing.T) {
+	handler, _ := newTestHandler()
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("push", `{"repository":`, testSecret))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServeHTTP_OtherOrg(t *testing.T) {
+	handler, processor := newTestHandler()
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("push", `{"repository":{"name":"testrepo","owner":{"login":"otherorg"}}}`, testSecret))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 0, len(processor.CallsTo("Repository")))
+}
+
+func TestServeHTTP_AnyOrg(t *testing.T) {
+	processor := &ProcessorSpy{Spy: spies.NewSpy()}
+	processor.MatchMethod("Repository", spies.AnyArgs, nil)
+	handler := NewHandler(context.Background(), []byte(testSecret), nil, processor, metrics.Options{}, time.Millisecond)
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("push", `{"repository":{"name":"testrepo","owner":{"login":"otherorg"}}}`, testSecret))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Eventually(t, func() bool { return len(processor.CallsTo("Repository")) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "otherorg", processor.CallsTo("Repository")[0].PassedArgs().String(0))
+}
+
+func TestServeHTTP_PushDebounced(t *testing.T) {
+	handler, processor := newTestHandler()
+	defer handler.Close()
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedRequest("push", pushPayload, testSecret))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	}
+
+	assert.Eventually(t, func() bool { return len(processor.CallsTo("Repository")) == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	calls := processor.CallsTo("Repository")
+	assert.Equal(t, 1, len(calls))
+	assert.Equal(t, "testorg", calls[0].PassedArgs().String(0))
+	assert.Equal(t, "testrepo", calls[0].PassedArgs().String(1))
+	assert.Equal(t, time.Minute, calls[0].PassedArgs().Get(2).(metrics.Options).RepoTimeout)
+}
+
+func TestServeHTTP_RepositoryDeleted(t *testing.T) {
+	handler, processor := newTestHandler()
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("repository", `{"action":"deleted","repository":{"name":"testrepo","owner":{"login":"TestOrg"}}}`, testSecret))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Eventually(t, func() bool { return len(processor.CallsTo("DeleteRepository")) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, len(processor.CallsTo("Repository")))
+	assert.Equal(t, "TestOrg", processor.CallsTo("DeleteRepository")[0].PassedArgs().String(0))
+	assert.Equal(t, "testrepo", processor.CallsTo("DeleteRepository")[0].PassedArgs().String(1))
+}
+
+func TestServeHTTP_RepositoryRenamed(t *testing.T) {
+	handler, processor := newTestHandler()
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("repository", `{"action":"renamed","changes":{"repository":{"name":{"from":"oldrepo"}}},"repository":{"name":"testrepo","owner":{"login":"testorg"}}}`, testSecret))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Eventually(t, func() bool {
+		return len(processor.CallsTo("DeleteRepository")) == 1 && len(processor.CallsTo("Repository")) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "oldrepo", processor.CallsTo("DeleteRepository")[0].PassedArgs().String(1))
+	assert.Equal(t, "testrepo", processor.CallsTo("Repository")[0].PassedArgs().String(1))
+}
+
+func TestServeHTTP_RepositoryTransferred(t *testing.T) {
+	handler, processor := newTestHandler()
+	defer handler.Close()
+
+	// transferred out of the organization, only the previous record is removed
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("repository", `{"action":"transferred","changes":{"owner":{"from":{"organization":{"login":"testorg"}}}},"repository":{"name":"outbound","owner":{"login":"otherorg"}}}`, testSecret))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	// transferred into the organization, the previous owner isn't handled
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("repository", `{"action":"transferred","changes":{"owner":{"from":{"user":{"login":"someone"}}}},"repository":{"name":"inbound","owner":{"login":"testorg"}}}`, testSecret))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	assert.Eventually(t, func() bool {
+		return len(processor.CallsTo("DeleteRepository")) == 1 && len(processor.CallsTo("Repository")) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "testorg", processor.CallsTo("DeleteRepository")[0].PassedArgs().String(0))
+	assert.Equal(t, "outbound", processor.CallsTo("DeleteRepository")[0].PassedArgs().String(1))
+	assert.Equal(t, "inbound", processor.CallsTo("Repository")[0].PassedArgs().String(1))
+}
+
+func TestServeHTTP_RepositoryTransferredWithinOrgs(t *testing.T) {
+	processor := &ProcessorSpy{Spy: spies.NewSpy()}
+	processor.MatchMethod("Repository", spies.AnyArgs, nil)
+	processor.MatchMethod("DeleteRepository", spies.AnyArgs, nil)
+	handler := NewHandler(context.Background(), []byte(testSecret), []string{"testorg", "otherorg"}, processor, metrics.Options{}, time.Millisecond)
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("repository", `{"action":"transferred","changes":{"owner":{"from":{"organization":{"login":"otherorg"}}}},"repository":{"name":"testrepo","owner":{"login":"testorg"}}}`, testSecret))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Eventually(t, func() bool {
+		return len(processor.CallsTo("DeleteRepository")) == 1 && len(processor.CallsTo("Repository")) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "otherorg", processor.CallsTo("DeleteRepository")[0].PassedArgs().String(0))
+	assert.Equal(t, "testorg", processor.CallsTo("Repository")[0].PassedArgs().String(0))
+}
+
+func TestServeHTTP_ExcludedByFilter(t *testing.T) {
+	processor := &ProcessorSpy{Spy: spies.NewSpy()}
+	processor.MatchMethod("Repository", spies.AnyArgs, nil)
+	processor.MatchMethod("DeleteRepository", spies.AnyArgs, nil)
+	options := metrics.Options{Filter: metrics.RepositoryFilter{RequiredTopics: []string{"production"}, Archived: metrics.SelectExclude}}
+	handler := NewHandler(context.Background(), []byte(testSecret), []string{"testorg"}, processor, options, time.Millisecond)
+	defer handler.Close()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("push", `{"repository":{"name":"included","topics":["production"],"owner":{"login":"testorg"}}}`, testSecret))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("push", `{"repository":{"name":"archived","topics":["production"],"archived":true,"owner":{"login":"testorg"}}}`, testSecret))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	// excluded repositories have their metrics removed rather than updated
+	assert.Eventually(t, func() bool {
+		return len(processor.CallsTo("DeleteRepository")) == 1 && len(processor.CallsTo("Repository")) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "included", processor.CallsTo("Repository")[0].PassedArgs().String(1))
+	assert.Equal(t, "archived", processor.CallsTo("DeleteRepository")[0].PassedArgs().String(1))
+}
+
+func TestClose_DropsPending(t *testing.T) {
+	processor := &ProcessorSpy{Spy: spies.NewSpy()}
+	handler := NewHandler(context.Background(), []byte(testSecret), []string{"testorg"}, processor, metrics.Options{}, time.Hour)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest("push", pushPayload, testSecret))
+	handler.Close()
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, 0, len(processor.CallsTo("Repository")))
+}
+
+const pushPayload = `{"ref":"refs/heads/main","repository":{"name":"testrepo","owner":{"login":"testorg"}}}`
+
+func newTestHandler() (*Handler, *ProcessorSpy) {
+	processor := &ProcessorSpy{Spy: spies.NewSpy()}
+	processor.MatchMethod("Repository", spies.AnyArgs, nil)
+	processor.MatchMethod("DeleteRepository", spies.AnyArgs, nil)
+	options := metrics.Options{RepoTimeout: time.Minute}
+	return NewHandler(context.Background(), []byte(testSecret), []string{"testorg"}, processor, options, 20*time.Millisecond), processor
+}
+
+func signedRequest(eventType string, payload string, secret string) *http.Request {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+type ProcessorSpy struct {
+	*spies.Spy
+	metrics.Processor
+}
+
+func (ps *ProcessorSpy) Repository(ctx context.Context, org string, repo string, options metrics.Options) error {
+	res := ps.Called(org, repo, options)
+	return res.Error(0)
+}
+
+func (ps *ProcessorSpy) DeleteRepository(ctx context.Context, org string, repo string) error {
+	res := ps.Called(org, repo)
+	return res.Error(0)
+}